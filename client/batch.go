@@ -0,0 +1,264 @@
+package mindctrl
+
+import (
+	"context"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"reflect"
+	"sync"
+)
+
+// Batch executes several operations over a transport or a pool at
+// once. The requests of the operations are published together in as
+// few MQTT messages as the websocket frame size allows, instead of
+// one message per operation, which saves round trips through the
+// broker when many small calls are made. The server replies to every
+// request with its own response, and the results are delivered back
+// to the individual operations:
+//
+//	first := mindctrl.GetTab(1)
+//	second := mindctrl.GetTab(2)
+//	err := mindctrl.NewBatch().Add(first, second).Execute(transport)
+//	tab, err := first.Result()
+//
+// Any mix of operations can be added to a batch. The operations are
+// started when they are added, so they cannot be changed afterwards,
+// and finished when the batch is executed. The interceptors of the
+// transport still see every call on its own. Interceptors that hold
+// calls back, like one that serializes the calls, do not block the
+// batch; the calls that reach the transport in time are published
+// together, and the others on their own.
+//
+// Before the first batch, the transport checks with
+// info.get_capabilities whether the server accepts batch packets.
+// Calls in a batch sent to servers predating batch packets are
+// executed individually instead. Over a pool, the whole batch is sent
+// to the same server.
+//
+type Batch struct {
+	calls    []batchCall
+	executed bool
+}
+
+// Call added to a batch.
+//
+type batchCall struct {
+	op     *GenericOperation
+	method string
+	input  interface{}
+	output interface{}
+}
+
+// Operation that can be added to a batch. Every operation in this
+// package implements the interface.
+//
+type Batchable interface {
+	enqueue(batch *Batch)
+}
+
+// Create a new empty batch.
+//
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Add the given operations to the batch. The function returns the
+// batch itself.
+//
+func (batch *Batch) Add(ops ...Batchable) *Batch {
+	if batch.executed {
+		panic("batch already executed")
+	}
+
+	for _, op := range ops {
+		op.enqueue(batch)
+	}
+
+	return batch
+}
+
+// Return the number of operations in the batch.
+//
+func (batch *Batch) Len() int {
+	return len(batch.calls)
+}
+
+// Execute the operations in the batch over the given transport or
+// pool and wait until all of them are finished. The results can then
+// be retrieved from the operations as usual. The function returns the
+// first error that prevents a call from completing, like a timeout
+// or the loss of the server; errors reported by the server for
+// individual calls are only available from the operations.
+//
+func (batch *Batch) Execute(transport Executor) error {
+	return batch.ExecuteContext(context.Background(), transport)
+}
+
+// Execute the operations in the batch like [Batch.Execute]. If the
+// given context is cancelled or its deadline passes before all calls
+// are finished, the remaining calls are abandoned and fail with the
+// context error.
+//
+func (batch *Batch) ExecuteContext(ctx context.Context, transport Executor) error {
+	if batch.executed {
+		panic("batch already executed")
+	}
+
+	batch.executed = true
+
+	if len(batch.calls) == 0 {
+		return nil
+	}
+
+	transport.batch(ctx, batch.calls)
+
+	for _, call := range batch.calls {
+		if err := call.op.doGetError(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add a call of the given operation to the batch. The operation is
+// started at once.
+//
+func (batch *Batch) enqueue(op *GenericOperation, method string, input interface{}, output interface{}) {
+	if batch.executed {
+		panic("batch already executed")
+	} else if op.started == true {
+		panic("operation already started")
+	} else {
+		op.started = true
+		op.method = method
+		batch.calls = append(batch.calls, batchCall{op: op, method: method, input: input, output: output})
+	}
+}
+
+// Slot of a call in a batch. The slot is settled at most once,
+// either when the request of the call is written, or when the call
+// returns without writing one.
+//
+type batchSlot struct {
+	batch *codec.Batch
+	once  sync.Once
+}
+
+// Return the input to be passed to net/rpc for the call.
+//
+func (slot *batchSlot) wrap(input interface{}) interface{} {
+	return &codec.Batched{Batch: slot.batch, Input: input}
+}
+
+// Settle the call in the batch.
+//
+func (slot *batchSlot) settle() {
+	slot.once.Do(slot.batch.Settle)
+}
+
+// Execute the given calls as a batch and wait until all of them are
+// finished. The calls go through the interceptors concurrently, each
+// with an innermost invoker bound to its slot in the batch; the
+// requests are held back by the codec until every call has written
+// its request or returned without writing one. The calls are simply
+// executed concurrently if the server does not accept batch packets,
+// or if the transport replays a cassette.
+//
+func (transport *Transport) batch(ctx context.Context, calls []batchCall) {
+	var held *codec.Batch
+	group := sync.WaitGroup{}
+
+	if c, ok := transport.codec.(*codec.Codec); ok && transport.isBatchable(ctx) {
+		held = c.NewBatch(len(calls))
+	}
+
+	for _, call := range calls {
+		group.Add(1)
+
+		go func(call batchCall) {
+			defer group.Done()
+
+			if held == nil {
+				call.op.doFinish(transport.invoker(ctx, call.method, call.input, call.output))
+			} else {
+				slot := &batchSlot{batch: held}
+				invoker := chainInterceptors(transport.interceptors, func(ctx context.Context, method string, input interface{}, output interface{}) error {
+					return transport.invokeBatched(ctx, slot, method, input, output)
+				})
+
+				err := invoker(ctx, call.method, call.input, call.output)
+				slot.settle()
+				call.op.doFinish(err)
+			}
+		}(call)
+	}
+
+	group.Wait()
+}
+
+// Call a remote method synchronously as part of a batch. It is the
+// innermost invoker of the interceptor chain of a batched call. The
+// input is passed to the codec wrapped with the batch, and the slot
+// of the call is settled once the request is written.
+//
+func (transport *Transport) invokeBatched(ctx context.Context, slot *batchSlot, method string, args interface{}, reply interface{}) error {
+	shadow := newShadowReply(reply)
+	call, abandon := transport.goAbandonable(method, slot.wrap(args), shadow)
+	slot.settle()
+
+	select {
+	case <-call.Done:
+		reflect.ValueOf(reply).Elem().Set(shadow.Elem())
+		return transport.translate(call.Error)
+	case <-ctx.Done():
+		abandon()
+		return ctx.Err()
+	}
+}
+
+// Check if the server accepts batch packets. The capabilities of the
+// server are fetched with info.get_capabilities before the first
+// batch, and kept for the lifetime of the transport. Servers that
+// predate the method predate batch packets as well. If the call
+// itself fails, like when it times out, the function returns false
+// and the check is repeated before the next batch.
+//
+func (transport *Transport) isBatchable(ctx context.Context) bool {
+	transport.mutex.Lock()
+	capabilities := transport.capabilities
+	transport.mutex.Unlock()
+
+	if capabilities == nil {
+		op := GetCapabilities()
+
+		if result, err := op.ExecuteContext(ctx, transport); op.doGetError() != nil {
+			return false
+		} else if err != nil {
+			capabilities = &protocol.Capabilities{}
+		} else {
+			capabilities = result
+		}
+
+		transport.mutex.Lock()
+		transport.capabilities = capabilities
+		transport.mutex.Unlock()
+	}
+
+	return capabilities.Batch
+}
+
+// Execute the given calls as a batch on one of the servers. The whole
+// batch is sent to the same server and takes a single slot on it. The
+// calls fail together if no server can take the batch.
+//
+func (pool *Pool) batch(ctx context.Context, calls []batchCall) {
+	if member, transport, err := pool.acquire(ctx); err != nil {
+		for _, call := range calls {
+			call.op.doFinish(err)
+		}
+	} else {
+		defer pool.release(member)
+		transport.batch(ctx, calls)
+	}
+}
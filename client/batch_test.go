@@ -0,0 +1,205 @@
+package mindctrl_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/broker"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Return the requests of the given method among the given requests.
+//
+func filterRequests(requests []mindctrltest.Request, method string) []mindctrltest.Request {
+	filtered := make([]mindctrltest.Request, 0)
+
+	for _, request := range requests {
+		if request.Method == method {
+			filtered = append(filtered, request)
+		}
+	}
+
+	return filtered
+}
+
+func TestBatch(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	first := server.AddTab(protocol.Tab{Url: "https://example.com/first"})
+	second := server.AddTab(protocol.Tab{Url: "https://example.com/second"})
+
+	ops := []*mindctrl.GetTabOperation{mindctrl.GetTab(first.Id), mindctrl.GetTab(second.Id), mindctrl.GetTab(1000)}
+	batch := mindctrl.NewBatch().Add(ops[0], ops[1], ops[2])
+
+	if err := batch.Execute(transport); err != nil {
+		t.Fatalf("cannot execute batch: %v", err)
+	}
+
+	if tab, err := ops[0].Result(); err != nil || tab.Url != first.Url {
+		t.Errorf("first call returns %+v and %v, expected %s", tab, err, first.Url)
+	}
+
+	if tab, err := ops[1].Result(); err != nil || tab.Url != second.Url {
+		t.Errorf("second call returns %+v and %v, expected %s", tab, err, second.Url)
+	}
+
+	if _, err := ops[2].Result(); errors.Is(err, mindctrl.ErrExecution) == false {
+		t.Errorf("third call fails with %v, expected %v", err, mindctrl.ErrExecution)
+	}
+
+	for _, request := range filterRequests(server.Requests(), protocol.GetTabMethod) {
+		if request.Batched == false {
+			t.Errorf("request %+v not sent in a batch packet", request)
+		}
+	}
+}
+
+func TestBatchWithoutCapabilities(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	server.Handle(protocol.GetCapabilitiesMethod, nil)
+
+	first := mindctrl.Ping()
+	second := mindctrl.Ping()
+
+	if err := mindctrl.NewBatch().Add(first, second).Execute(transport); err != nil {
+		t.Fatalf("cannot execute batch: %v", err)
+	} else if err := first.Result(); err != nil {
+		t.Errorf("first call fails with %v", err)
+	} else if err := second.Result(); err != nil {
+		t.Errorf("second call fails with %v", err)
+	}
+
+	for _, request := range filterRequests(server.Requests(), protocol.PingMethod) {
+		if request.Batched {
+			t.Errorf("request %+v sent in a batch packet to a server predating batches", request)
+		}
+	}
+}
+
+func TestBatchWithInterceptor(t *testing.T) {
+	var seen int32
+	mutex := sync.Mutex{}
+
+	count := func(ctx context.Context, method string, input interface{}, output interface{}, invoke mindctrl.Invoker) error {
+		atomic.AddInt32(&seen, 1)
+		return invoke(ctx, method, input, output)
+	}
+
+	serialize := func(ctx context.Context, method string, input interface{}, output interface{}, invoke mindctrl.Invoker) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return invoke(ctx, method, input, output)
+	}
+
+	_, transport := startTransport(t, nil, nil, count, serialize)
+	batch := mindctrl.NewBatch()
+
+	for index := 0; index < 5; index++ {
+		batch.Add(mindctrl.Ping())
+	}
+
+	within(t, 5*time.Second, func() {
+		if err := batch.Execute(transport); err != nil {
+			t.Errorf("cannot execute batch: %v", err)
+		}
+	})
+
+	if seen := atomic.LoadInt32(&seen); seen != 6 {
+		t.Errorf("interceptor sees %d calls, expected 5 pings and the capability check", seen)
+	}
+}
+
+func TestBatchRunsConcurrently(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	arrived := sync.WaitGroup{}
+	arrived.Add(2)
+
+	server.Handle(protocol.PingMethod, func(input json.RawMessage) interface{} {
+		arrived.Done()
+		arrived.Wait()
+		return protocol.PingOutput{GenericOutput: protocol.GenericOutput{Success: true}}
+	})
+
+	within(t, 5*time.Second, func() {
+		if err := mindctrl.NewBatch().Add(mindctrl.Ping(), mindctrl.Ping()).Execute(transport); err != nil {
+			t.Errorf("cannot execute batch: %v", err)
+		}
+	})
+}
+
+func TestBatchOverPool(t *testing.T) {
+	_, url := startBroker(t, nil)
+	first := startServer(t, url, "first")
+	second := startServer(t, url, "second")
+	pool := startPool(t, url, nil)
+	waitServers(t, pool, "first", "second")
+
+	if err := mindctrl.NewBatch().Add(mindctrl.Ping(), mindctrl.Ping(), mindctrl.Ping()).Execute(pool); err != nil {
+		t.Fatalf("cannot execute batch over pool: %v", err)
+	}
+
+	firstCount := len(filterRequests(first.Requests(), protocol.PingMethod))
+	secondCount := len(filterRequests(second.Requests(), protocol.PingMethod))
+
+	if firstCount+secondCount != 3 || (firstCount != 0 && secondCount != 0) {
+		t.Errorf("servers receive %d and %d calls, expected all calls on one server", firstCount, secondCount)
+	}
+}
+
+func TestBatchFitsBrokerLimit(t *testing.T) {
+	_, url := startBroker(t, &broker.Options{MaxPacketSize: 32768})
+	server, err := mindctrltest.NewServer(url, "test", &mindctrltest.Options{SigningSecret: "secret"})
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	options := &mindctrl.Options{
+		SigningSecret:      "secret",
+		WebsocketFrameSize: 32768,
+		MqttResponseTopic:  "replies/" + strings.Repeat("x", 1000),
+		RequestTimeout:     5 * time.Second,
+	}
+
+	transport, err := server.NewTransport(options)
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+
+	// The ampersands are escaped in the request packets, and escaped
+	// again when the batch packets are signed.
+
+	batch := mindctrl.NewBatch()
+	ops := make([]*mindctrl.LoadTabOperation, 0)
+
+	for i := 0; i < 50; i++ {
+		op := mindctrl.LoadTab(1, "https://example.com/?"+strings.Repeat("a=b&", 250))
+		batch.Add(op)
+		ops = append(ops, op)
+	}
+
+	if err := batch.Execute(transport); err != nil {
+		t.Fatalf("cannot execute batch: %v", err)
+	}
+
+	for index, op := range ops {
+		if _, err := op.Result(); err != nil {
+			t.Errorf("call %d fails with %v", index, err)
+		}
+	}
+
+	if requests := filterRequests(server.Requests(), protocol.LoadTabMethod); len(requests) != len(ops) {
+		t.Errorf("server receives %d calls, expected %d", len(requests), len(ops))
+	}
+}
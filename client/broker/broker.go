@@ -0,0 +1,333 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/eclipse/paho.golang/packets"
+	"net"
+	"net/http"
+	"net/url"
+	"nhooyr.io/websocket"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Broker is a minimal in-process MQTT v5 broker. The broker is safe
+// for concurrent use by multiple goroutines; it can serve multiple
+// listeners at the same time.
+//
+// The sessions of the connected clients are indexed by client ID,
+// while the retained messages are indexed by topic name. Both are
+// protected by the mutex.
+//
+type Broker struct {
+	options   *Options
+	mutex     sync.Mutex
+	sessions  map[string]*session
+	retained  map[string]*packets.Publish
+	listeners map[net.Listener]struct{}
+	servers   map[*http.Server]struct{}
+	assigned  uint64
+	closed    bool
+	wait      sync.WaitGroup
+}
+
+// Delivery records a session that should receive a published
+// message, together with the QoS level of the delivery and whether
+// the retain flag should be kept.
+//
+type delivery struct {
+	target *session
+	qos    byte
+	retain bool
+}
+
+// Create a new broker with the given options. The broker does not
+// accept any connection until one of the serve functions is called.
+//
+func NewBroker(options *Options) *Broker {
+	return &Broker{
+		options:   options,
+		sessions:  make(map[string]*session),
+		retained:  make(map[string]*packets.Publish),
+		listeners: make(map[net.Listener]struct{}),
+		servers:   make(map[*http.Server]struct{}),
+	}
+}
+
+// Accept plain MQTT connections from the given listener and serve
+// them in their own goroutines. The function blocks until the
+// listener fails or the broker is closed, in which case the error
+// [ErrBrokerClosed] is returned. The listener is closed when the
+// function returns.
+//
+func (broker *Broker) ServeTcp(listener net.Listener) error {
+	broker.mutex.Lock()
+
+	if broker.closed {
+		broker.mutex.Unlock()
+		listener.Close()
+		return ErrBrokerClosed
+	}
+
+	broker.listeners[listener] = struct{}{}
+	broker.mutex.Unlock()
+
+	defer func() {
+		broker.mutex.Lock()
+		delete(broker.listeners, listener)
+		broker.mutex.Unlock()
+		listener.Close()
+	}()
+
+	for {
+		if conn, err := listener.Accept(); err != nil {
+			if broker.isClosed() {
+				return ErrBrokerClosed
+			} else {
+				return err
+			}
+		} else {
+			go broker.ServeConn(conn)
+		}
+	}
+}
+
+// Accept MQTT over websocket connections from the given listener
+// and serve them in their own goroutines. The function blocks until
+// the listener fails or the broker is closed, in which case the
+// error [ErrBrokerClosed] is returned. The listener is closed when
+// the function returns.
+//
+func (broker *Broker) ServeWebsocket(listener net.Listener) error {
+	server := &http.Server{Handler: broker}
+
+	broker.mutex.Lock()
+
+	if broker.closed {
+		broker.mutex.Unlock()
+		listener.Close()
+		return ErrBrokerClosed
+	}
+
+	broker.servers[server] = struct{}{}
+	broker.mutex.Unlock()
+
+	defer func() {
+		broker.mutex.Lock()
+		delete(broker.servers, server)
+		broker.mutex.Unlock()
+	}()
+
+	if err := server.Serve(listener); errors.Is(err, http.ErrServerClosed) {
+		return ErrBrokerClosed
+	} else {
+		return err
+	}
+}
+
+// Upgrade the given HTTP request to a websocket connection with the
+// "mqtt" subprotocol and serve the connection. The function allows
+// the broker to be mounted on any HTTP server. Requests from origins
+// not accepted by the broker are refused with status 403.
+//
+func (broker *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	acceptOptions := &websocket.AcceptOptions{
+		Subprotocols:       []string{"mqtt"},
+		InsecureSkipVerify: true,
+	}
+
+	if broker.isOriginAccepted(r.Header.Get("Origin")) == false {
+		http.Error(w, "origin not accepted", http.StatusForbidden)
+	} else if socket, err := websocket.Accept(w, r, acceptOptions); err != nil {
+		return
+	} else if socket.Subprotocol() != "mqtt" {
+		socket.Close(websocket.StatusPolicyViolation, "mqtt subprotocol required")
+	} else {
+		socket.SetReadLimit(broker.options.getMaxPacketSize() + 5)
+		broker.ServeConn(websocket.NetConn(r.Context(), socket, websocket.MessageBinary))
+	}
+}
+
+// Return if websocket connections are accepted from the given origin.
+// Programs other than browsers send no origin, while web extensions
+// send an origin with their own scheme; both are always accepted. Web
+// pages are only accepted when the host of their origin matches one
+// of the patterns in the options. Note that unlike the default check
+// of the websocket library, pages of the same host as the broker are
+// not accepted either, or any page could reach a broker listening on
+// the loopback interface by DNS rebinding.
+//
+func (broker *Broker) isOriginAccepted(origin string) bool {
+	if origin == "" {
+		return true
+	} else if parsed, err := url.Parse(origin); err != nil {
+		return false
+	} else if parsed.Scheme == "moz-extension" || parsed.Scheme == "chrome-extension" {
+		return true
+	} else {
+		for _, pattern := range broker.options.getWebsocketOrigins() {
+			if matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(parsed.Host)); err == nil && matched {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Serve a single MQTT connection. The function blocks until the
+// connection is closed, and returns nil if the client disconnects
+// normally. The connection is closed when the function returns.
+//
+func (broker *Broker) ServeConn(conn net.Conn) error {
+	broker.mutex.Lock()
+
+	if broker.closed {
+		broker.mutex.Unlock()
+		conn.Close()
+		return ErrBrokerClosed
+	}
+
+	broker.wait.Add(1)
+	broker.mutex.Unlock()
+
+	defer broker.wait.Done()
+
+	client := newSession(broker, conn)
+	return client.serve()
+}
+
+// Close the broker. All listeners are closed and all clients are
+// disconnected. The function waits until every connection is closed.
+//
+func (broker *Broker) Close() error {
+	broker.mutex.Lock()
+
+	if broker.closed {
+		broker.mutex.Unlock()
+		return ErrBrokerClosed
+	}
+
+	broker.closed = true
+	listeners := broker.listeners
+	servers := broker.servers
+	sessions := broker.sessions
+	broker.listeners = make(map[net.Listener]struct{})
+	broker.servers = make(map[*http.Server]struct{})
+	broker.sessions = make(map[string]*session)
+	broker.mutex.Unlock()
+
+	for listener := range listeners {
+		listener.Close()
+	}
+
+	for server := range servers {
+		server.Close()
+	}
+
+	for _, client := range sessions {
+		client.kick(packets.DisconnectServerShuttingDown)
+	}
+
+	broker.wait.Wait()
+	return nil
+}
+
+func (broker *Broker) isClosed() bool {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	return broker.closed
+}
+
+// Register the given session under its client ID. An existing
+// session with the same client ID is taken over: its connection
+// is closed after the new session is registered. Return false if
+// the broker is closed.
+//
+// If the session comes without a client ID, a unique one will be
+// assigned to it.
+//
+func (broker *Broker) register(client *session) bool {
+	broker.mutex.Lock()
+
+	if broker.closed {
+		broker.mutex.Unlock()
+		return false
+	}
+
+	if client.id == "" {
+		broker.assigned++
+		client.id = fmt.Sprintf("mindctrl-broker-%d", broker.assigned)
+		client.assigned = true
+	}
+
+	previous := broker.sessions[client.id]
+	broker.sessions[client.id] = client
+	broker.mutex.Unlock()
+
+	if previous != nil {
+		previous.kick(packets.DisconnectSessionTakenOver)
+	}
+
+	return true
+}
+
+// Unregister the given session, unless it is already taken over by
+// another session with the same client ID.
+//
+func (broker *Broker) unregister(client *session) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	if broker.sessions[client.id] == client {
+		delete(broker.sessions, client.id)
+	}
+}
+
+// Publish the given message to every matching subscription. If the
+// message is marked for retention, it will replace the previously
+// retained message of the same topic; an empty payload will remove
+// the retained message instead.
+//
+func (broker *Broker) publish(sender *session, message *packets.Publish) {
+	broker.mutex.Lock()
+
+	if message.Retain && len(message.Payload) == 0 {
+		delete(broker.retained, message.Topic)
+	} else if message.Retain {
+		broker.retained[message.Topic] = message
+	}
+
+	deliveries := make([]delivery, 0)
+
+	for _, client := range broker.sessions {
+		if target, found := client.match(sender, message); found {
+			deliveries = append(deliveries, target)
+		}
+	}
+
+	broker.mutex.Unlock()
+
+	for _, target := range deliveries {
+		target.target.deliver(message, target.qos, target.retain)
+	}
+}
+
+// Return the retained messages that match the given topic filter.
+//
+func (broker *Broker) lookup(filter string) []*packets.Publish {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	messages := make([]*packets.Publish, 0)
+
+	for topic, message := range broker.retained {
+		if matchTopic(filter, topic) {
+			messages = append(messages, message)
+		}
+	}
+
+	return messages
+}
@@ -0,0 +1,232 @@
+package broker_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/kmchan2018/mindctrl/client/broker"
+	"net"
+	"net/http"
+	"nhooyr.io/websocket"
+	"testing"
+	"time"
+)
+
+// Start a broker with the given options that accepts both websocket
+// and plain connections on random ports of the loopback interface.
+// Return the broker together with the addresses of the listeners.
+//
+func startBroker(t *testing.T, options *broker.Options) (*broker.Broker, string, string) {
+	t.Helper()
+
+	b := broker.NewBroker(options)
+	websocketListener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		websocketListener.Close()
+		t.Fatalf("cannot listen: %v", err)
+	}
+
+	go b.ServeWebsocket(websocketListener)
+	go b.ServeTcp(tcpListener)
+	t.Cleanup(func() { b.Close() })
+	return b, websocketListener.Addr().String(), tcpListener.Addr().String()
+}
+
+// Connect a MQTT client to the broker at the given plain address with
+// the given credentials. The router receives the messages delivered
+// to the client.
+//
+func connectClient(t *testing.T, address string, id string, username string, password string, router paho.Router) (*paho.Client, *paho.Connack, error) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", address)
+
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+
+	if router == nil {
+		router = paho.NewSingleHandlerRouter(func(*paho.Publish) {})
+	}
+
+	client := paho.NewClient(paho.ClientConfig{Conn: conn, Router: router})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	connect := &paho.Connect{ClientID: id, KeepAlive: 1, CleanStart: true}
+
+	if username != "" || password != "" {
+		connect.UsernameFlag = true
+		connect.PasswordFlag = true
+		connect.Username = username
+		connect.Password = []byte(password)
+	}
+
+	ack, err := client.Connect(ctx, connect)
+
+	if err == nil {
+		t.Cleanup(func() { client.Disconnect(&paho.Disconnect{ReasonCode: 0}) })
+	}
+
+	return client, ack, err
+}
+
+// Open a websocket connection to the broker at the given address with
+// the given origin, and return the HTTP status of the handshake.
+//
+func dialWebsocket(t *testing.T, address string, origin string) int {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	header := http.Header{}
+
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+
+	options := &websocket.DialOptions{Subprotocols: []string{"mqtt"}, HTTPHeader: header}
+	socket, response, err := websocket.Dial(ctx, fmt.Sprintf("ws://%s", address), options)
+
+	if err == nil {
+		socket.Close(websocket.StatusNormalClosure, "")
+	}
+
+	if response == nil {
+		t.Fatalf("no response to websocket handshake: %v", err)
+	}
+
+	return response.StatusCode
+}
+
+func TestWebsocketOrigins(t *testing.T) {
+	_, address, _ := startBroker(t, &broker.Options{WebsocketOrigins: []string{"*.example.com"}})
+
+	cases := []struct {
+		origin   string
+		accepted bool
+	}{
+		{"", true},
+		{"moz-extension://0d3f5a2e-1c4b-4f6e-9a7d-2b8c6e1f0a93", true},
+		{"chrome-extension://abcdefghijklmnopabcdefghijklmnop", true},
+		{"https://app.example.com", true},
+		{"https://example.org", false},
+		{"http://" + address, false},
+		{"null", false},
+	}
+
+	for _, c := range cases {
+		if status := dialWebsocket(t, address, c.origin); c.accepted && status != http.StatusSwitchingProtocols {
+			t.Errorf("origin %q refused with status %d", c.origin, status)
+		} else if c.accepted == false && status != http.StatusForbidden {
+			t.Errorf("origin %q answered with status %d, expected %d", c.origin, status, http.StatusForbidden)
+		}
+	}
+}
+
+func TestWebPagesRefusedByDefault(t *testing.T) {
+	_, address, _ := startBroker(t, nil)
+
+	if status := dialWebsocket(t, address, "https://example.com"); status != http.StatusForbidden {
+		t.Errorf("web page answered with status %d, expected %d", status, http.StatusForbidden)
+	} else if status := dialWebsocket(t, address, "moz-extension://0d3f5a2e-1c4b-4f6e-9a7d-2b8c6e1f0a93"); status != http.StatusSwitchingProtocols {
+		t.Errorf("web extension refused with status %d", status)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	_, _, address := startBroker(t, &broker.Options{Username: "user", Password: "secret"})
+
+	if _, ack, err := connectClient(t, address, "wrong", "user", "wrong", nil); err == nil {
+		t.Errorf("client connected with wrong password")
+	} else if ack == nil || ack.ReasonCode != 0x86 {
+		t.Errorf("client refused without reason code 0x86: %v", err)
+	}
+
+	if _, _, err := connectClient(t, address, "anonymous", "", "", nil); err == nil {
+		t.Errorf("client connected without credentials")
+	}
+
+	if _, _, err := connectClient(t, address, "right", "user", "secret", nil); err != nil {
+		t.Errorf("client refused with right credentials: %v", err)
+	}
+}
+
+func TestPublishAndRetain(t *testing.T) {
+	_, _, address := startBroker(t, nil)
+	received := make(chan *paho.Publish, 10)
+	router := paho.NewSingleHandlerRouter(func(message *paho.Publish) { received <- message })
+
+	publisher, _, err := connectClient(t, address, "publisher", "", "", nil)
+
+	if err != nil {
+		t.Fatalf("cannot connect publisher: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := publisher.Publish(ctx, &paho.Publish{Topic: "statuses/a", QoS: 1, Retain: true, Payload: []byte("retained")}); err != nil {
+		t.Fatalf("cannot publish: %v", err)
+	}
+
+	subscriber, _, err := connectClient(t, address, "subscriber", "", "", router)
+
+	if err != nil {
+		t.Fatalf("cannot connect subscriber: %v", err)
+	} else if _, err := subscriber.Subscribe(ctx, &paho.Subscribe{Subscriptions: map[string]paho.SubscribeOptions{"statuses/+": {QoS: 1}}}); err != nil {
+		t.Fatalf("cannot subscribe: %v", err)
+	} else if _, err := publisher.Publish(ctx, &paho.Publish{Topic: "statuses/b", QoS: 1, Payload: []byte("live")}); err != nil {
+		t.Fatalf("cannot publish: %v", err)
+	}
+
+	for _, expected := range []string{"retained", "live"} {
+		select {
+		case message := <-received:
+			if string(message.Payload) != expected {
+				t.Errorf("received %q, expected %q", message.Payload, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %q not received", expected)
+		}
+	}
+}
+
+func TestNoPersistentSession(t *testing.T) {
+	_, _, address := startBroker(t, nil)
+	conn, err := net.Dial("tcp", address)
+
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+
+	client := paho.NewClient(paho.ClientConfig{Conn: conn, Router: paho.NewSingleHandlerRouter(func(*paho.Publish) {})})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	expiry := uint32(60)
+	connect := &paho.Connect{ClientID: "persistent", KeepAlive: 1, CleanStart: false, Properties: &paho.ConnectProperties{SessionExpiryInterval: &expiry}}
+	ack, err := client.Connect(ctx, connect)
+
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+
+	t.Cleanup(func() { client.Disconnect(&paho.Disconnect{ReasonCode: 0}) })
+
+	if ack.SessionPresent {
+		t.Errorf("broker reports a session present")
+	}
+
+	if ack.Properties == nil || ack.Properties.SessionExpiryInterval == nil || *ack.Properties.SessionExpiryInterval != 0 {
+		t.Errorf("broker does not report a session expiry interval of zero")
+	}
+}
@@ -0,0 +1,20 @@
+package broker
+
+import (
+	"errors"
+)
+
+// Error reported by [Broker] to indicate that the broker is closed.
+//
+var ErrBrokerClosed = errors.New("broker closed")
+
+// Error reported by [Broker] to indicate that a client violates the
+// MQTT protocol, like sending a packet other than CONNECT as its
+// first packet.
+//
+var ErrProtocolViolation = errors.New("protocol violation")
+
+// Error reported by [Broker] to indicate that a client cannot be
+// authenticated.
+//
+var ErrNotAuthorized = errors.New("not authorized")
@@ -0,0 +1,26 @@
+// Package broker provides a minimal in-process MQTT v5 broker that
+// is sufficient for Mindctrl web extension and clients.
+//
+// The broker accepts MQTT connections over websocket, which are
+// required by the web extension, and over plain TCP. It supports
+// QoS 0, 1 and 2, wildcard subscriptions, retained messages, will
+// messages and optional username/password authentication.
+//
+// Sessions are not persisted: every connection starts with a clean
+// session, and the session is discarded as soon as the connection
+// is closed, even if the client asks for a session expiry interval.
+// The broker reports a session expiry interval of zero to every
+// client, so the codec does not assume a session against the broker
+// even when its persistent session option is enabled; calls in
+// flight fail as soon as the connection is lost, and responses
+// published in the meantime are lost.
+//
+// Since browsers let any web page open websocket connections to any
+// host, including the loopback interface, websocket connections from
+// web pages are refused unless their origin is explicitly accepted in
+// the options; see [Options]. The broker does not authenticate
+// clients unless a username and password are set in the options,
+// which is recommended whenever the broker is reachable from other
+// hosts.
+//
+package broker
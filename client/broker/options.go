@@ -0,0 +1,106 @@
+package broker
+
+import (
+	"crypto/subtle"
+	"time"
+)
+
+// Options for creating a new broker.
+//
+// The 'Username' and 'Password' fields contains the credentials
+// that clients must present when connecting to the broker. By
+// default, they are both empty meaning no authentication is needed.
+//
+// The 'MaxPacketSize' field contains the maximum size of MQTT
+// packets accepted by the broker. Clients sending larger packets
+// are disconnected. The default is 16MB, which matches the default
+// websocket frame size of the codec.
+//
+// The 'WebsocketOrigins' field contains the host patterns of the web
+// pages that may connect over websocket, like "example.com" or
+// "*.example.com". Connections without the Origin header, which come
+// from programs other than browsers, and connections from web
+// extensions are always accepted. By default, no web page is accepted,
+// so that pages visited by the browser cannot talk to the broker.
+//
+// The 'WriteTimeout' field contains the maximum duration to wait
+// for a packet to be written to a client. Clients that are too slow
+// to receive packets are disconnected. The default is 10 seconds.
+//
+// The 'OnConnect' and 'OnDisconnect' fields contain optional hooks
+// invoked after a client is connected and after a client is gone.
+// The error passed to the 'OnDisconnect' hook is nil if the client
+// disconnects normally. They are invoked from the goroutine that
+// serves the client and therefore should return quickly.
+//
+type Options struct {
+	Username         string                         // username required from clients
+	Password         string                         // password required from clients
+	MaxPacketSize    int64                          // maximum size of MQTT packets
+	WebsocketOrigins []string                       // origin patterns accepted for websocket connections
+	WriteTimeout     time.Duration                  // maximum duration to write a packet to a client
+	OnConnect        func(client string)            // hook invoked after a client is connected
+	OnDisconnect     func(client string, err error) // hook invoked after a client is gone
+}
+
+func (options *Options) isAuthenticationRequired() bool {
+	if options == nil {
+		return false
+	} else {
+		return options.Username != "" || options.Password != ""
+	}
+}
+
+func (options *Options) isAuthenticated(username string, password []byte) bool {
+	if options.isAuthenticationRequired() == false {
+		return true
+	} else {
+		usernameMatched := subtle.ConstantTimeCompare([]byte(username), []byte(options.Username))
+		passwordMatched := subtle.ConstantTimeCompare(password, []byte(options.Password))
+		return usernameMatched&passwordMatched == 1
+	}
+}
+
+func (options *Options) getMaxPacketSize() int64 {
+	if options == nil {
+		return 16 * 1024 * 1024
+	} else if options.MaxPacketSize <= 0 {
+		return 16 * 1024 * 1024
+	} else {
+		return options.MaxPacketSize
+	}
+}
+
+func (options *Options) getWebsocketOrigins() []string {
+	if options == nil {
+		return nil
+	} else {
+		return options.WebsocketOrigins
+	}
+}
+
+func (options *Options) getWriteTimeout() time.Duration {
+	if options == nil {
+		return 10 * time.Second
+	} else if options.WriteTimeout <= 0 {
+		return 10 * time.Second
+	} else {
+		return options.WriteTimeout
+	}
+}
+
+func (options *Options) getOnConnect() func(string) {
+	if options == nil {
+		return nil
+	} else {
+		return options.OnConnect
+	}
+}
+
+func (options *Options) getOnDisconnect() func(string, error) {
+	if options == nil {
+		return nil
+	} else {
+		return options.OnDisconnect
+	}
+}
@@ -0,0 +1,133 @@
+package broker
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/eclipse/paho.golang/packets"
+	"io"
+)
+
+// Read a MQTT packet from the given reader. Packets larger than the
+// given limit are rejected.
+//
+// The function works like [packets.ReadPacket], except that it also
+// restores the retain and duplicate flags of PUBLISH packets, and
+// returns the topic filters of SUBSCRIBE packets in the order they
+// appear in the packet. The order is needed to produce the reason
+// codes in the SUBACK packet, but it is lost when the packet is
+// decoded into [packets.Subscribe].
+//
+func readPacket(reader io.Reader, limit int64) (*packets.ControlPacket, []string, error) {
+	header := [1]byte{}
+
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, nil, err
+	} else if length, err := readLength(reader); err != nil {
+		return nil, nil, err
+	} else if int64(length) > limit {
+		return nil, nil, fmt.Errorf("%w: packet of %d bytes exceeds the limit", ErrProtocolViolation, length)
+	} else if packet := packets.NewControlPacket(header[0] >> 4); packet == nil {
+		return nil, nil, fmt.Errorf("%w: unknown packet type %d", ErrProtocolViolation, header[0]>>4)
+	} else {
+		body := make([]byte, length)
+
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return nil, nil, err
+		}
+
+		packet.Flags = header[0] & 0x0F
+
+		if publish, ok := packet.Content.(*packets.Publish); ok {
+			publish.QoS = (packet.Flags & 0x06) >> 1
+			publish.Retain = (packet.Flags & 0x01) != 0
+			publish.Duplicate = (packet.Flags & 0x08) != 0
+		}
+
+		if err := packet.Content.Unpack(bytes.NewBuffer(body)); err != nil {
+			return nil, nil, fmt.Errorf("%w: malformed %s packet: %s", ErrProtocolViolation, packet.PacketType(), err)
+		} else if packet.Type == packets.SUBSCRIBE {
+			if filters, err := readFilters(body); err != nil {
+				return nil, nil, fmt.Errorf("%w: malformed SUBSCRIBE packet: %s", ErrProtocolViolation, err)
+			} else {
+				return packet, filters, nil
+			}
+		} else {
+			return packet, nil, nil
+		}
+	}
+}
+
+// Read a variable byte integer from the given reader. It is used to
+// encode the remaining length of MQTT packets.
+//
+func readLength(reader io.Reader) (int, error) {
+	digit := [1]byte{}
+	length := 0
+
+	for shift := 0; shift < 28; shift += 7 {
+		if _, err := io.ReadFull(reader, digit[:]); err != nil {
+			return 0, err
+		}
+
+		length |= int(digit[0]&0x7F) << shift
+
+		if digit[0]&0x80 == 0 {
+			return length, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: malformed remaining length", ErrProtocolViolation)
+}
+
+// Read the topic filters from the body of a SUBSCRIBE packet in the
+// order they appear in the packet. The body consists of the packet
+// identifier, the properties and a list of topic filters, each
+// followed by a byte of subscription options.
+//
+func readFilters(body []byte) ([]string, error) {
+	reader := bytes.NewReader(body)
+
+	if _, err := reader.Seek(2, io.SeekStart); err != nil {
+		return nil, err
+	} else if length, err := readLength(reader); err != nil {
+		return nil, err
+	} else if _, err := reader.Seek(int64(length), io.SeekCurrent); err != nil {
+		return nil, err
+	}
+
+	filters := make([]string, 0)
+
+	for reader.Len() > 0 {
+		size := [2]byte{}
+
+		if _, err := io.ReadFull(reader, size[:]); err != nil {
+			return nil, err
+		}
+
+		filter := make([]byte, int(size[0])<<8|int(size[1]))
+
+		if _, err := io.ReadFull(reader, filter); err != nil {
+			return nil, err
+		} else if _, err := reader.ReadByte(); err != nil {
+			return nil, err
+		} else {
+			filters = append(filters, string(filter))
+		}
+	}
+
+	return filters, nil
+}
+
+// Encode the given packet into a single buffer, so that it can be
+// written to the connection by a single write. This matters for
+// websocket connections, where every write produces a message.
+//
+func encodePacket(packet packets.Packet) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+
+	if _, err := packet.WriteTo(buffer); err != nil {
+		return nil, err
+	} else {
+		return buffer.Bytes(), nil
+	}
+}
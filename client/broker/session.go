@@ -0,0 +1,514 @@
+package broker
+
+import (
+	"fmt"
+	"github.com/eclipse/paho.golang/packets"
+	"net"
+	"sync"
+	"time"
+)
+
+// Session is the state of a connected client. The subscriptions and
+// the packet identifiers in use are protected by the mutex, since
+// they are accessed by the goroutine serving the client as well as
+// the goroutines of other clients publishing messages to it. Writes
+// to the connection are serialized by a separate mutex so that slow
+// writes do not block the session state.
+//
+// The will message is only accessed by the goroutine serving the
+// client.
+//
+type session struct {
+	broker        *Broker
+	conn          net.Conn
+	id            string
+	assigned      bool
+	keepAlive     time.Duration
+	will          *packets.Publish
+	mutex         sync.Mutex
+	subscriptions map[string]packets.SubOptions
+	inflight      map[uint16]struct{}
+	received      map[uint16]struct{}
+	nextId        uint16
+	writer        sync.Mutex
+}
+
+// Create a new session for the given connection.
+//
+func newSession(broker *Broker, conn net.Conn) *session {
+	return &session{
+		broker:        broker,
+		conn:          conn,
+		subscriptions: make(map[string]packets.SubOptions),
+		inflight:      make(map[uint16]struct{}),
+		received:      make(map[uint16]struct{}),
+	}
+}
+
+// Serve the client until the connection is closed. The will message
+// of the client is published unless the client disconnects normally
+// or the broker is closed.
+//
+func (client *session) serve() error {
+	defer client.conn.Close()
+
+	if err := client.handshake(); err != nil {
+		return err
+	}
+
+	if hook := client.broker.options.getOnConnect(); hook != nil {
+		hook(client.id)
+	}
+
+	err := client.loop()
+	client.broker.unregister(client)
+
+	if client.will != nil && client.broker.isClosed() == false {
+		client.broker.publish(client, client.will)
+	}
+
+	if hook := client.broker.options.getOnDisconnect(); hook != nil {
+		hook(client.id, err)
+	}
+
+	return err
+}
+
+// Wait for the CONNECT packet from the client, authenticate the
+// client and register the session with the broker. The client
+// must send the CONNECT packet within 10 seconds.
+//
+func (client *session) handshake() error {
+	client.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	if packet, _, err := readPacket(client.conn, client.broker.options.getMaxPacketSize()); err != nil {
+		return err
+	} else if connect, ok := packet.Content.(*packets.Connect); ok == false {
+		return fmt.Errorf("%w: expecting CONNECT packet but got %s", ErrProtocolViolation, packet.PacketType())
+	} else if connect.ProtocolName != "MQTT" || connect.ProtocolVersion != 5 {
+		client.refuse(0x84)
+		return fmt.Errorf("%w: unsupported protocol version %d", ErrProtocolViolation, connect.ProtocolVersion)
+	} else if client.broker.options.isAuthenticated(connect.Username, connect.Password) == false {
+		client.refuse(0x86)
+		return ErrNotAuthorized
+	} else if connect.WillFlag && isValidTopic(connect.WillTopic) == false {
+		client.refuse(0x90)
+		return fmt.Errorf("%w: invalid will topic %q", ErrProtocolViolation, connect.WillTopic)
+	} else {
+		if connect.WillFlag {
+			client.will = &packets.Publish{
+				Topic:      connect.WillTopic,
+				Payload:    connect.WillMessage,
+				QoS:        connect.WillQOS,
+				Retain:     connect.WillRetain,
+				Properties: forwardProperties(connect.WillProperties),
+			}
+		}
+
+		client.id = connect.ClientID
+		client.keepAlive = time.Duration(connect.KeepAlive) * time.Second
+
+		if client.broker.register(client) == false {
+			client.refuse(0x88)
+			return ErrBrokerClosed
+		}
+
+		// Sessions are not kept after the connection is closed,
+		// whatever the client asks for. The session expiry interval
+		// in the CONNACK packet tells the client so, such that it
+		// does not wait for responses to be delivered to a resumed
+		// session.
+
+		available := byte(1)
+		unavailable := byte(0)
+		maximum := uint32(client.broker.options.getMaxPacketSize())
+		expiry := uint32(0)
+
+		connack := &packets.Connack{
+			ReasonCode:     0,
+			SessionPresent: false,
+			Properties: &packets.Properties{
+				SessionExpiryInterval: &expiry,
+				RetainAvailable:       &available,
+				WildcardSubAvailable:  &available,
+				SubIDAvailable:        &unavailable,
+				SharedSubAvailable:    &unavailable,
+				MaximumPacketSize:     &maximum,
+			},
+		}
+
+		if client.assigned {
+			connack.Properties.AssignedClientID = client.id
+		}
+
+		return client.write(connack)
+	}
+}
+
+// Process packets from the client until the connection is closed.
+// The function returns nil if the client disconnects normally.
+//
+// If the client specifies a keep alive interval, the connection is
+// closed when no packet is received for one and a half times the
+// interval.
+//
+func (client *session) loop() error {
+	for {
+		if client.keepAlive > 0 {
+			client.conn.SetReadDeadline(time.Now().Add(client.keepAlive * 3 / 2))
+		} else {
+			client.conn.SetReadDeadline(time.Time{})
+		}
+
+		packet, filters, err := readPacket(client.conn, client.broker.options.getMaxPacketSize())
+
+		if err != nil {
+			return err
+		}
+
+		switch content := packet.Content.(type) {
+		case *packets.Publish:
+			err = client.handlePublish(content)
+		case *packets.Puback:
+			client.release(content.PacketID)
+		case *packets.Pubrec:
+			err = client.handlePubrec(content)
+		case *packets.Pubrel:
+			err = client.handlePubrel(content)
+		case *packets.Pubcomp:
+			client.release(content.PacketID)
+		case *packets.Subscribe:
+			err = client.handleSubscribe(content, filters)
+		case *packets.Unsubscribe:
+			err = client.handleUnsubscribe(content)
+		case *packets.Pingreq:
+			err = client.write(&packets.Pingresp{})
+		case *packets.Disconnect:
+			if content.ReasonCode != packets.DisconnectDisconnectWithWillMessage {
+				client.will = nil
+			}
+
+			return nil
+		default:
+			client.kick(packets.DisconnectProtocolError)
+			return fmt.Errorf("%w: unexpected %s packet", ErrProtocolViolation, packet.PacketType())
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Handle a PUBLISH packet from the client. The message is routed to
+// the matching subscriptions before it is acknowledged.
+//
+// For QoS 2 messages, the packet identifier is remembered until the
+// PUBREL packet is received, so that a duplicate PUBLISH packet is
+// acknowledged again but not routed again.
+//
+func (client *session) handlePublish(publish *packets.Publish) error {
+	if isValidTopic(publish.Topic) == false {
+		client.kick(packets.DisconnectTopicNameInvalid)
+		return fmt.Errorf("%w: invalid topic %q", ErrProtocolViolation, publish.Topic)
+	} else if publish.Properties != nil && publish.Properties.TopicAlias != nil {
+		client.kick(packets.DisconnectTopicAliasInvalid)
+		return fmt.Errorf("%w: topic alias not supported", ErrProtocolViolation)
+	} else if publish.QoS > 2 {
+		client.kick(packets.DisconnectMalformedPacket)
+		return fmt.Errorf("%w: invalid QoS level %d", ErrProtocolViolation, publish.QoS)
+	}
+
+	message := &packets.Publish{
+		Topic:      publish.Topic,
+		Payload:    publish.Payload,
+		QoS:        publish.QoS,
+		Retain:     publish.Retain,
+		Properties: forwardProperties(publish.Properties),
+	}
+
+	switch publish.QoS {
+	case 0:
+		client.broker.publish(client, message)
+		return nil
+	case 1:
+		client.broker.publish(client, message)
+		return client.write(&packets.Puback{PacketID: publish.PacketID, Properties: &packets.Properties{}})
+	default:
+		if client.receive(publish.PacketID) {
+			client.broker.publish(client, message)
+		}
+
+		return client.write(&packets.Pubrec{PacketID: publish.PacketID, Properties: &packets.Properties{}})
+	}
+}
+
+// Handle a PUBREC packet from the client, which acknowledges the
+// receipt of a QoS 2 message delivered to the client.
+//
+func (client *session) handlePubrec(pubrec *packets.Pubrec) error {
+	if pubrec.ReasonCode >= 0x80 {
+		client.release(pubrec.PacketID)
+		return nil
+	} else {
+		return client.write(&packets.Pubrel{PacketID: pubrec.PacketID, Properties: &packets.Properties{}})
+	}
+}
+
+// Handle a PUBREL packet from the client, which completes the
+// delivery of a QoS 2 message from the client.
+//
+func (client *session) handlePubrel(pubrel *packets.Pubrel) error {
+	client.mutex.Lock()
+	delete(client.received, pubrel.PacketID)
+	client.mutex.Unlock()
+
+	return client.write(&packets.Pubcomp{PacketID: pubrel.PacketID, Properties: &packets.Properties{}})
+}
+
+// Handle a SUBSCRIBE packet from the client. Retained messages that
+// match the new subscriptions are delivered after the SUBACK packet,
+// subject to the retain handling option of the subscriptions.
+//
+func (client *session) handleSubscribe(subscribe *packets.Subscribe, filters []string) error {
+	reasons := make([]byte, 0, len(filters))
+	fresh := make([]string, 0, len(filters))
+
+	client.mutex.Lock()
+
+	for _, filter := range filters {
+		options := subscribe.Subscriptions[filter]
+
+		if isValidFilter(filter) == false {
+			reasons = append(reasons, packets.SubackTopicFilterinvalid)
+		} else if isSharedFilter(filter) {
+			reasons = append(reasons, packets.SubackSharedSubscriptionnotsupported)
+		} else if options.QoS > 2 {
+			reasons = append(reasons, packets.SubackUnspecifiederror)
+		} else {
+			_, existed := client.subscriptions[filter]
+			client.subscriptions[filter] = options
+			reasons = append(reasons, options.QoS)
+
+			if options.RetainHandling == 0 || (options.RetainHandling == 1 && existed == false) {
+				fresh = append(fresh, filter)
+			}
+		}
+	}
+
+	client.mutex.Unlock()
+
+	suback := &packets.Suback{
+		PacketID:   subscribe.PacketID,
+		Reasons:    reasons,
+		Properties: &packets.Properties{},
+	}
+
+	if err := client.write(suback); err != nil {
+		return err
+	}
+
+	for _, filter := range fresh {
+		options := subscribe.Subscriptions[filter]
+
+		for _, message := range client.broker.lookup(filter) {
+			if message.QoS < options.QoS {
+				client.deliver(message, message.QoS, true)
+			} else {
+				client.deliver(message, options.QoS, true)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Handle an UNSUBSCRIBE packet from the client.
+//
+func (client *session) handleUnsubscribe(unsubscribe *packets.Unsubscribe) error {
+	reasons := make([]byte, 0, len(unsubscribe.Topics))
+
+	client.mutex.Lock()
+
+	for _, filter := range unsubscribe.Topics {
+		if _, found := client.subscriptions[filter]; found {
+			delete(client.subscriptions, filter)
+			reasons = append(reasons, 0x00)
+		} else {
+			reasons = append(reasons, 0x11)
+		}
+	}
+
+	client.mutex.Unlock()
+
+	unsuback := &packets.Unsuback{
+		PacketID:   unsubscribe.PacketID,
+		Reasons:    reasons,
+		Properties: &packets.Properties{},
+	}
+
+	return client.write(unsuback)
+}
+
+// Check if the given message published by the sender should be
+// delivered to the client. When multiple subscriptions match the
+// message, it is delivered once with the highest QoS level among
+// the subscriptions.
+//
+func (client *session) match(sender *session, message *packets.Publish) (delivery, bool) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	result := delivery{target: client}
+	found := false
+
+	for filter, options := range client.subscriptions {
+		if options.NoLocal && client == sender {
+			continue
+		} else if matchTopic(filter, message.Topic) == false {
+			continue
+		}
+
+		qos := options.QoS
+
+		if message.QoS < qos {
+			qos = message.QoS
+		}
+
+		if found == false || qos > result.qos {
+			result.qos = qos
+		}
+
+		if options.RetainAsPublished && message.Retain {
+			result.retain = true
+		}
+
+		found = true
+	}
+
+	return result, found
+}
+
+// Deliver the given message to the client with the given QoS level.
+// The message is dropped if no packet identifier is available.
+//
+func (client *session) deliver(message *packets.Publish, qos byte, retain bool) {
+	publish := &packets.Publish{
+		Topic:      message.Topic,
+		Payload:    message.Payload,
+		QoS:        qos,
+		Retain:     retain,
+		Properties: message.Properties,
+	}
+
+	if qos > 0 {
+		if id, ok := client.allocate(); ok {
+			publish.PacketID = id
+		} else {
+			return
+		}
+	}
+
+	client.write(publish)
+}
+
+// Allocate a packet identifier for a message delivered with QoS 1
+// or 2. The identifier is in use until the delivery is completed.
+//
+func (client *session) allocate() (uint16, bool) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	for i := 0; i < 65535; i++ {
+		client.nextId++
+
+		if client.nextId == 0 {
+			client.nextId = 1
+		}
+
+		if _, found := client.inflight[client.nextId]; found == false {
+			client.inflight[client.nextId] = struct{}{}
+			return client.nextId, true
+		}
+	}
+
+	return 0, false
+}
+
+// Release the given packet identifier after the delivery of a message
+// is completed.
+//
+func (client *session) release(id uint16) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	delete(client.inflight, id)
+}
+
+// Remember the identifier of a QoS 2 message from the client. Return
+// false if the message is a duplicate.
+//
+func (client *session) receive(id uint16) bool {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if _, found := client.received[id]; found {
+		return false
+	} else {
+		client.received[id] = struct{}{}
+		return true
+	}
+}
+
+// Write the given packet to the client. The connection is closed if
+// the packet cannot be written within the write timeout.
+//
+func (client *session) write(packet packets.Packet) error {
+	if data, err := encodePacket(packet); err != nil {
+		return err
+	} else {
+		client.writer.Lock()
+		defer client.writer.Unlock()
+
+		client.conn.SetWriteDeadline(time.Now().Add(client.broker.options.getWriteTimeout()))
+
+		if _, err := client.conn.Write(data); err != nil {
+			client.conn.Close()
+			return err
+		} else {
+			return nil
+		}
+	}
+}
+
+// Refuse the connection with the given reason code.
+//
+func (client *session) refuse(reason byte) {
+	client.write(&packets.Connack{ReasonCode: reason, Properties: &packets.Properties{}})
+}
+
+// Disconnect the client with the given reason code.
+//
+func (client *session) kick(reason byte) {
+	client.write(&packets.Disconnect{ReasonCode: reason, Properties: &packets.Properties{}})
+	client.conn.Close()
+}
+
+// Return the properties of a published message that are forwarded
+// to the subscribers. Properties that only concern the connection
+// between the publisher and the broker, like topic alias, are
+// dropped.
+//
+func forwardProperties(properties *packets.Properties) *packets.Properties {
+	if properties == nil {
+		return &packets.Properties{}
+	} else {
+		return &packets.Properties{
+			PayloadFormat:   properties.PayloadFormat,
+			MessageExpiry:   properties.MessageExpiry,
+			ContentType:     properties.ContentType,
+			ResponseTopic:   properties.ResponseTopic,
+			CorrelationData: properties.CorrelationData,
+			User:            properties.User,
+		}
+	}
+}
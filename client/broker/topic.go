@@ -0,0 +1,70 @@
+package broker
+
+import (
+	"strings"
+)
+
+// Return if the given topic name is valid for publishing. Topic
+// names must not be empty and must not contain wildcards.
+//
+func isValidTopic(topic string) bool {
+	return topic != "" && strings.ContainsAny(topic, "+#\x00") == false
+}
+
+// Return if the given topic filter is valid for subscribing. The
+// multi-level wildcard "#" must occupy the last level by itself,
+// and the single-level wildcard "+" must occupy a level by itself.
+//
+func isValidFilter(filter string) bool {
+	if filter == "" || strings.Contains(filter, "\x00") {
+		return false
+	}
+
+	levels := strings.Split(filter, "/")
+
+	for index, level := range levels {
+		if strings.Contains(level, "#") {
+			if level != "#" || index != len(levels)-1 {
+				return false
+			}
+		} else if strings.Contains(level, "+") {
+			if level != "+" {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Return if the given topic filter is a shared subscription, which
+// is not supported by the broker.
+//
+func isSharedFilter(filter string) bool {
+	return strings.HasPrefix(filter, "$share/")
+}
+
+// Return if the given topic name matches the given topic filter.
+// As required by the MQTT specification, topics starting with "$"
+// are not matched by filters starting with a wildcard.
+//
+func matchTopic(filter string, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	if strings.HasPrefix(topic, "$") && (filterLevels[0] == "+" || filterLevels[0] == "#") {
+		return false
+	}
+
+	for index, level := range filterLevels {
+		if level == "#" {
+			return true
+		} else if index >= len(topicLevels) {
+			return false
+		} else if level != "+" && level != topicLevels[index] {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
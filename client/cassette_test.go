@@ -0,0 +1,124 @@
+package mindctrl_test
+
+import (
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"path/filepath"
+	"testing"
+)
+
+// Record a cassette of a tabs.find call and two tabs.load calls made
+// against a fake server, and return its path. The server is closed
+// before the function returns, so that replaying transports cannot
+// reach it.
+//
+func recordCassette(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	server, err := mindctrltest.Start("test", nil)
+
+	if err != nil {
+		t.Fatalf("cannot start server: %v", err)
+	}
+
+	defer server.Close()
+
+	server.AddTab(protocol.Tab{Url: "https://example.com/"})
+	transport, err := server.NewTransport(&mindctrl.Options{RecordFile: path})
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	defer transport.Close()
+
+	if _, err := mindctrl.FindTabs().SetUrl(true, "*://example.com/*").Execute(transport); err != nil {
+		t.Fatalf("cannot find tabs: %v", err)
+	} else if _, err := mindctrl.LoadTab(1, "https://example.com/first").Execute(transport); err != nil {
+		t.Fatalf("cannot load tab: %v", err)
+	} else if _, err := mindctrl.LoadTab(1, "https://example.com/second").Execute(transport); err != nil {
+		t.Fatalf("cannot load tab: %v", err)
+	}
+
+	return path
+}
+
+// Create a new transport replaying the cassette at the given path.
+//
+func replayCassette(t *testing.T, path string, strict bool) *mindctrl.Transport {
+	t.Helper()
+
+	transport, err := mindctrl.NewTransport("", "", "", &mindctrl.Options{ReplayFile: path, ReplayStrict: strict})
+
+	if err != nil {
+		t.Fatalf("cannot replay cassette: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+	return transport
+}
+
+func TestRecordCassette(t *testing.T) {
+	path := recordCassette(t)
+	interactions, err := codec.ReadCassette(path)
+
+	if err != nil {
+		t.Fatalf("cannot read cassette: %v", err)
+	} else if len(interactions) != 3 {
+		t.Fatalf("cassette contains %d interactions, expected 3", len(interactions))
+	}
+
+	expected := []string{protocol.FindTabsMethod, protocol.LoadTabMethod, protocol.LoadTabMethod}
+
+	for index, interaction := range interactions {
+		if interaction.Method != expected[index] {
+			t.Errorf("interaction %d calls %s, expected %s", index, interaction.Method, expected[index])
+		}
+	}
+}
+
+func TestReplayCassette(t *testing.T) {
+	transport := replayCassette(t, recordCassette(t), false)
+
+	if tabs, err := mindctrl.FindTabs().SetUrl(true, "*://example.com/*").Execute(transport); err != nil {
+		t.Errorf("cannot replay tabs.find: %v", err)
+	} else if len(tabs) != 1 || tabs[0].Url != "https://example.com/" {
+		t.Errorf("replayed %+v, expected the recorded tab", tabs)
+	}
+
+	if tab, err := mindctrl.LoadTab(1, "https://example.com/second").Execute(transport); err != nil {
+		t.Errorf("cannot replay tabs.load: %v", err)
+	} else if tab.Url != "https://example.com/second" {
+		t.Errorf("replayed tab at %s, expected the exact match", tab.Url)
+	}
+
+	if tab, err := mindctrl.LoadTab(1, "https://example.com/third").Execute(transport); err != nil {
+		t.Errorf("cannot replay tabs.load: %v", err)
+	} else if tab.Url != "https://example.com/first" {
+		t.Errorf("replayed tab at %s, expected the first response of the method", tab.Url)
+	}
+
+	if _, err := mindctrl.GetCurrentTab().Execute(transport); errors.Is(err, codec.ErrNotRecorded) == false {
+		t.Errorf("unrecorded method fails with %v, expected %v", err, codec.ErrNotRecorded)
+	}
+}
+
+func TestReplayCassetteStrictly(t *testing.T) {
+	transport := replayCassette(t, recordCassette(t), true)
+
+	if _, err := mindctrl.LoadTab(1, "https://example.com/first").Execute(transport); err != nil {
+		t.Errorf("cannot replay tabs.load: %v", err)
+	}
+
+	if _, err := mindctrl.LoadTab(1, "https://example.com/first").Execute(transport); errors.Is(err, codec.ErrNotRecorded) == false {
+		t.Errorf("repeated call fails with %v, expected %v", err, codec.ErrNotRecorded)
+	}
+
+	if _, err := mindctrl.LoadTab(1, "https://example.com/third").Execute(transport); errors.Is(err, codec.ErrNotRecorded) == false {
+		t.Errorf("inexact call fails with %v, expected %v", err, codec.ErrNotRecorded)
+	}
+}
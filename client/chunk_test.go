@@ -0,0 +1,232 @@
+package mindctrl_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/broker"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Start a server on a broker of its own that answers every call with
+// the given output split into chunks, and connect a new transport with
+// the given options to it. The chunks are published in the order
+// returned by the given function, which may also drop or repeat them.
+//
+func startChunkedServer(t *testing.T, output interface{}, options *mindctrl.Options, arrange func(chunks [][]byte) [][]byte) *mindctrl.Transport {
+	t.Helper()
+
+	_, url := startBroker(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	connection, err := codec.Dial(ctx, url, nil)
+
+	if err != nil {
+		t.Fatalf("cannot connect to broker: %v", err)
+	}
+
+	var mqtt *paho.Client
+
+	respond := func(received *paho.Publish) {
+		input := json.RawMessage{}
+		request := protocol.RequestPacket{Input: &input}
+
+		if err := json.Unmarshal(received.Payload, &request); err != nil {
+			return
+		}
+
+		encoded, _ := json.Marshal(output)
+		response := &protocol.ResponsePacket{Type: "response", Id: request.Id, Method: request.Method, Client: request.Client, Server: request.Server, Output: encoded}
+		message, _ := json.Marshal(response)
+		chunks := [][]byte{}
+
+		for _, chunk := range protocol.SplitResponse(response, message, request.ChunkSize, func(packet []byte) int { return len(packet) }) {
+			encoded, _ := json.Marshal(chunk)
+			chunks = append(chunks, encoded)
+		}
+
+		for _, chunk := range arrange(chunks) {
+			mqtt.Publish(context.Background(), &paho.Publish{
+				Topic:      received.Properties.ResponseTopic,
+				QoS:        received.QoS,
+				Payload:    chunk,
+				Properties: &paho.PublishProperties{CorrelationData: received.Properties.CorrelationData},
+			})
+		}
+	}
+
+	mqtt = paho.NewClient(paho.ClientConfig{
+		Conn: connection,
+		Router: paho.NewSingleHandlerRouter(func(received *paho.Publish) {
+			go respond(received)
+		}),
+	})
+
+	if _, err := mqtt.Connect(ctx, &paho.Connect{ClientID: "chunked", KeepAlive: 1, CleanStart: true}); err != nil {
+		t.Fatalf("cannot connect to broker: %v", err)
+	}
+
+	t.Cleanup(func() { mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0}) })
+
+	if _, err := mqtt.Subscribe(ctx, &paho.Subscribe{Subscriptions: map[string]paho.SubscribeOptions{protocol.GetServerRpcTopic("test"): {QoS: 1}}}); err != nil {
+		t.Fatalf("cannot subscribe: %v", err)
+	} else if _, err := mqtt.Publish(ctx, &paho.Publish{Topic: protocol.GetServerStatusTopic("test"), QoS: 1, Payload: []byte("alive"), Retain: true}); err != nil {
+		t.Fatalf("cannot publish status: %v", err)
+	}
+
+	transport, err := mindctrl.NewTransport(url, "client", "test", options)
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+	return transport
+}
+
+// Return the output of info.get_browser with a browser name long
+// enough to be split into several chunks. The name is random so that
+// compression cannot make it fit in a single message.
+//
+func getLargeBrowserInfo() protocol.GetBrowserInfoOutput {
+	name := make([]byte, 40000)
+	rand.New(rand.NewSource(1)).Read(name)
+
+	output := protocol.GetBrowserInfoOutput{}
+	output.Success = true
+	output.Result.Name = hex.EncodeToString(name)
+	output.Result.Version = "1.0"
+	return output
+}
+
+func TestChunkedResponse(t *testing.T) {
+	expected := getLargeBrowserInfo()
+	count := 0
+
+	transport := startChunkedServer(t, expected, &mindctrl.Options{ChunkSize: protocol.MinimumChunkSize}, func(chunks [][]byte) [][]byte {
+		count = len(chunks)
+		return chunks
+	})
+
+	if info, err := mindctrl.GetBrowserInfo().Execute(transport); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if *info != expected.Result {
+		t.Errorf("reassembled response differs from the original")
+	}
+
+	if count < 10 {
+		t.Errorf("response split into %d chunks, expected more", count)
+	}
+}
+
+func TestChunksOutOfOrder(t *testing.T) {
+	expected := getLargeBrowserInfo()
+
+	transport := startChunkedServer(t, expected, &mindctrl.Options{ChunkSize: protocol.MinimumChunkSize}, func(chunks [][]byte) [][]byte {
+		arranged := make([][]byte, 0, len(chunks)+1)
+
+		for index := len(chunks) - 1; index >= 0; index-- {
+			arranged = append(arranged, chunks[index])
+
+			if index == len(chunks)-2 {
+				arranged = append(arranged, chunks[len(chunks)-1])
+			}
+		}
+
+		return arranged
+	})
+
+	if info, err := mindctrl.GetBrowserInfo().Execute(transport); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if *info != expected.Result {
+		t.Errorf("reassembled response differs from the original")
+	}
+}
+
+func TestChunkLost(t *testing.T) {
+	options := &mindctrl.Options{ChunkSize: protocol.MinimumChunkSize, ChunkTimeout: 200 * time.Millisecond}
+
+	transport := startChunkedServer(t, getLargeBrowserInfo(), options, func(chunks [][]byte) [][]byte {
+		return append(chunks[:1:1], chunks[2:]...)
+	})
+
+	within(t, 10*time.Second, func() {
+		if _, err := mindctrl.GetBrowserInfo().Execute(transport); errors.Is(err, codec.ErrIncompleteResponse) == false {
+			t.Errorf("call fails with %v, expected %v", err, codec.ErrIncompleteResponse)
+		}
+	})
+}
+
+func TestChunksFitBrokerLimit(t *testing.T) {
+	_, url := startBroker(t, &broker.Options{MaxPacketSize: protocol.MinimumChunkSize})
+	server, err := mindctrltest.NewServer(url, "test", &mindctrltest.Options{SigningSecret: "secret"})
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	options := &mindctrl.Options{
+		ChunkSize:         protocol.MinimumChunkSize,
+		MqttResponseTopic: "replies/" + strings.Repeat("x", 1000),
+		RequestTimeout:    5 * time.Second,
+	}
+
+	transport, err := server.NewTransport(options)
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+	expected := getLargeBrowserInfo()
+
+	server.Handle(protocol.GetBrowserInfoMethod, func(input json.RawMessage) interface{} {
+		return expected
+	})
+
+	if info, err := mindctrl.GetBrowserInfo().Execute(transport); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if *info != expected.Result {
+		t.Errorf("reassembled response differs from the original")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	serverOptions := &mindctrltest.Options{CompressionThreshold: 1}
+	options := &mindctrl.Options{Compression: protocol.GzipEncoding, CompressionThreshold: 1}
+	server, transport := startTransport(t, serverOptions, options)
+	expected := getLargeBrowserInfo()
+	url := "https://example.com/" + strings.Repeat("path/", 100)
+
+	server.Handle(protocol.GetBrowserInfoMethod, func(input json.RawMessage) interface{} {
+		return expected
+	})
+
+	if info, err := mindctrl.GetBrowserInfo().Execute(transport); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if *info != expected.Result {
+		t.Errorf("decompressed output differs from the original")
+	}
+
+	mindctrl.LoadTab(1, url).Execute(transport)
+	requests := server.Requests()
+	input := protocol.LoadTabInput{}
+
+	if err := json.Unmarshal(requests[len(requests)-1].Input, &input); err != nil {
+		t.Errorf("cannot decode decompressed input: %v", err)
+	} else if input.Url != url {
+		t.Errorf("decompressed input differs from the original")
+	}
+}
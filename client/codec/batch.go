@@ -0,0 +1,258 @@
+package codec
+
+import (
+	"encoding/json"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"sync"
+	"time"
+)
+
+// Time a batch waits for its unsettled calls once none of its calls
+// has written a request or settled. Calls can be held up before they
+// reach the codec, for example by an interceptor that serializes the
+// calls or limits their concurrency while waiting for the responses
+// of the calls already written; the batch is then published without
+// them rather than waiting forever.
+//
+const batchLinger = 20 * time.Millisecond
+
+// Batch collects requests written to the codec so that they can be
+// published together as [protocol.BatchPacket] messages instead of
+// one message per request.
+//
+// A batch is created for a known number of calls. Requests are held
+// back while any of the calls is unsettled; the calls are settled by
+// [Batch.Settle] once their requests are written or they are known
+// to write none. The held requests are then published in as few
+// messages as the websocket frame size allows. If the batch makes no
+// progress for a short while, the held requests are published without
+// waiting for the unsettled calls. Requests written after the batch
+// is published are published on their own.
+//
+type Batch struct {
+	codec     *Codec
+	mutex     sync.Mutex
+	unsettled int
+	held      []heldRequest
+	published bool
+	timer     *time.Timer
+}
+
+// Request held back by a batch. The size is the room the encoded
+// request packet takes in the message of a batch packet; see
+// [Codec.getBatchedSize].
+//
+type heldRequest struct {
+	seq    uint64
+	method string
+	packet protocol.RequestPacket
+	size   int
+}
+
+// Batched wraps the input of a call that belongs to a batch. The
+// wrapper is passed to net/rpc in place of the input, so that the
+// codec can tell which batch the request belongs to.
+//
+type Batched struct {
+	Batch *Batch      // batch of the call
+	Input interface{} // actual input of the call
+}
+
+// Create a new batch for the given number of calls.
+//
+func (codec *Codec) NewBatch(size int) *Batch {
+	return &Batch{codec: codec, unsettled: size}
+}
+
+// Settle a call of the batch. The held requests are published when
+// every call is settled.
+//
+func (batch *Batch) Settle() {
+	batch.mutex.Lock()
+	batch.unsettled--
+
+	if batch.published {
+		batch.mutex.Unlock()
+	} else if batch.unsettled > 0 {
+		batch.linger()
+		batch.mutex.Unlock()
+	} else {
+		batch.publish()
+	}
+}
+
+// Publish the held requests if the batch has made no progress since
+// the timer is armed.
+//
+func (batch *Batch) expire() {
+	batch.mutex.Lock()
+
+	if batch.published || len(batch.held) == 0 {
+		batch.mutex.Unlock()
+	} else {
+		batch.publish()
+	}
+}
+
+// Arm the timer again after the batch has made progress. The caller
+// must hold the mutex.
+//
+func (batch *Batch) linger() {
+	if batch.timer == nil {
+		batch.timer = time.AfterFunc(batchLinger, batch.expire)
+	} else {
+		batch.timer.Reset(batchLinger)
+	}
+}
+
+// Publish the held requests. The caller must hold the mutex, which is
+// released by the function.
+//
+func (batch *Batch) publish() {
+	held := batch.held
+	batch.held = nil
+	batch.published = true
+
+	if batch.timer != nil {
+		batch.timer.Stop()
+	}
+
+	batch.mutex.Unlock()
+	batch.codec.publishBatch(held)
+}
+
+// Hold back the given request until the batch is published. The
+// function returns false if the batch is already published, in which
+// case the request should be published on its own.
+//
+func (batch *Batch) hold(seq uint64, packet *protocol.RequestPacket, size int) bool {
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+
+	if batch.published {
+		return false
+	} else {
+		batch.held = append(batch.held, heldRequest{seq: seq, method: packet.Method, packet: *packet, size: size})
+		batch.linger()
+		return true
+	}
+}
+
+// Publish the given requests as batch packets. Consecutive requests
+// are packed into one packet as long as the whole PUBLISH packet fits
+// into a websocket frame; a request too large for any frame is sent in
+// a packet of its own. Requests that cannot be published fail with the
+// publish error.
+//
+// The room taken by everything besides the requests, like the brackets
+// of the array, the signature and the MQTT headers and properties, is
+// measured with an empty batch packet. Each request then takes its own
+// size plus a comma.
+//
+func (codec *Codec) publishBatch(held []heldRequest) {
+	limit := int(codec.options.getWebsocketFrameSize())
+	envelope := codec.getBatchEnvelope()
+	start := 0
+	size := envelope
+
+	for index, request := range held {
+		if index > start && size+1+request.size > limit {
+			codec.publishChunk(held[start:index])
+			start = index
+			size = envelope
+		}
+
+		if index > start {
+			size++
+		}
+
+		size += request.size
+	}
+
+	if start < len(held) {
+		codec.publishChunk(held[start:])
+	}
+}
+
+// Publish the given requests as a single batch packet. The response
+// topic is set as usual, but no correlation data is given, since the
+// packet carries several requests; the server is expected to use the
+// request id as the correlation data of every response instead.
+//
+func (codec *Codec) publishChunk(chunk []heldRequest) {
+	batch := make(protocol.BatchPacket, 0, len(chunk))
+
+	for _, request := range chunk {
+		batch = append(batch, request.packet)
+	}
+
+	if mPacket, err := json.Marshal(batch); err != nil {
+		codec.fail(chunk, err)
+	} else if mPacket, err = codec.sign(mPacket); err != nil {
+		codec.fail(chunk, err)
+	} else if mqtt := codec.current(); mqtt == nil {
+		codec.fail(chunk, ErrReconnecting)
+	} else {
+		publishPacket := &paho.Publish{
+			Topic:   protocol.GetServerRpcTopic(codec.server),
+			QoS:     codec.options.getMqttQoS(),
+			Retain:  false,
+			Payload: mPacket,
+			Properties: &paho.PublishProperties{
+				ResponseTopic: codec.options.getMqttResponseTopic(codec.name),
+			},
+		}
+
+		if _, err := mqtt.Publish(codec.ctx, publishPacket); err != nil {
+			codec.fail(chunk, err)
+		}
+	}
+}
+
+// Return the size of an empty batch packet as published, including the
+// signature and the MQTT headers and properties.
+//
+func (codec *Codec) getBatchEnvelope() int {
+	topic := protocol.GetServerRpcTopic(codec.server)
+	responseTopic := codec.options.getMqttResponseTopic(codec.name)
+	overhead := 16 + len(topic) + len(responseTopic)
+
+	if signed, err := codec.sign([]byte("[]")); err != nil {
+		return 2 + overhead
+	} else {
+		return len(signed) + overhead
+	}
+}
+
+// Return the room the given encoded request packet takes in the message
+// of a batch packet. When signing is enabled, the message is wrapped in
+// a signed packet as a JSON string, in which some characters of the
+// request are escaped.
+//
+func (codec *Codec) getBatchedSize(packet []byte) int {
+	if codec.options.getSignatureAlgorithm() == "" {
+		return len(packet)
+	} else if escaped, err := json.Marshal(string(packet)); err != nil {
+		return len(packet)
+	} else {
+		return len(escaped) - 2
+	}
+}
+
+// Mark the given requests as failed with the given error, unless they
+// are already completed in the meantime.
+//
+func (codec *Codec) fail(chunk []heldRequest, err error) {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	for _, request := range chunk {
+		if _, found := codec.inflight[request.seq]; found {
+			codec.failures = append(codec.failures, failure{seq: request.seq, method: request.method, err: err})
+			delete(codec.inflight, request.seq)
+		}
+	}
+
+	codec.notify()
+}
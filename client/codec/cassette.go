@@ -0,0 +1,152 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Interaction is a single request/response pair recorded in a
+// cassette. A cassette is a JSONL file where each line contains a
+// single interaction, in the order the responses are received.
+//
+// The params and the result are stored as they appear in the request
+// and the response packets, so that a cassette can be inspected and
+// edited by hand, or attached to bug reports.
+//
+type Interaction struct {
+	Time   time.Time       `json:"time"`   // time when the response is received
+	Method string          `json:"method"` // method being called
+	Params json.RawMessage `json:"params"` // input of the call
+	Result json.RawMessage `json:"result"` // output of the call
+}
+
+// Return the key of the interaction, which consists of the method
+// and the normalized params. See [NormalizeParams] for details.
+//
+func (interaction *Interaction) Key() string {
+	return fmt.Sprintf("%s %s", interaction.Method, NormalizeParams(interaction.Params))
+}
+
+// Normalize the given params so that semantically identical params
+// produce the same string. The params are decoded and re-encoded,
+// which sorts object keys and removes insignificant whitespace. An
+// absent or null params is normalized to an empty object, since the
+// server treats them the same way. Params that are not valid JSON
+// are returned unchanged.
+//
+func NormalizeParams(params json.RawMessage) string {
+	var decoded interface{}
+
+	if len(params) == 0 {
+		return "{}"
+	} else if err := json.Unmarshal(params, &decoded); err != nil {
+		return string(params)
+	} else if decoded == nil {
+		return "{}"
+	} else if encoded, err := json.Marshal(decoded); err != nil {
+		return string(params)
+	} else {
+		return string(encoded)
+	}
+}
+
+// Read all interactions from the cassette at the given path.
+//
+func ReadCassette(path string) ([]Interaction, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	interactions := make([]Interaction, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		interaction := Interaction{}
+
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("invalid interaction at line %d of cassette %s: %w", line, path, err)
+		} else {
+			interactions = append(interactions, interaction)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	} else {
+		return interactions, nil
+	}
+}
+
+// Recorder appends interactions to a cassette. The recorder is safe
+// for concurrent use by multiple goroutines.
+//
+type recorder struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// Open the cassette at the given path for recording. The cassette
+// is truncated unless the append flag is set.
+//
+func openRecorder(path string, append bool) (*recorder, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+
+	if append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	if file, err := os.OpenFile(path, flags, 0644); err != nil {
+		return nil, err
+	} else {
+		return &recorder{file: file}, nil
+	}
+}
+
+// Append the given interaction to the cassette. Each interaction is
+// written with a single write, so that the cassette stays readable
+// up to the last complete line if the program is killed.
+//
+func (recorder *recorder) record(interaction Interaction) error {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.file == nil {
+		return ErrClosed
+	} else if encoded, err := json.Marshal(interaction); err != nil {
+		return err
+	} else {
+		_, err := recorder.file.Write(append(encoded, '\n'))
+		return err
+	}
+}
+
+// Close the cassette. Further interactions are dropped.
+//
+func (recorder *recorder) close() error {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.file == nil {
+		return nil
+	} else {
+		err := recorder.file.Close()
+		recorder.file = nil
+		return err
+	}
+}
@@ -0,0 +1,148 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"net/rpc"
+	"strconv"
+	"time"
+)
+
+// Assembly collects the chunks of a chunked response until all of
+// them arrive. Missing chunks are nil. The size is the total size of
+// the chunks received so far.
+//
+type assembly struct {
+	chunks   [][]byte
+	received int
+	size     int
+	deadline time.Time
+}
+
+// Process a chunk packet received from the broker. The chunk is kept
+// until every chunk of the response arrives, at which point the
+// response is reassembled and processed like a response received
+// whole. The function returns the same values as [Codec.receive].
+//
+// Like whole responses, chunks are identified by the Correlation Data
+// property if present, and by the id in the chunk packet otherwise.
+// Chunks addressed to other clients or servers are ignored.
+//
+// When signing is enabled, every chunk is signed on its own, so the
+// reassembled response is not verified again.
+//
+// Chunks of calls that are no longer in flight are discarded. If the
+// chunk would take the total size of the chunks kept over the chunk
+// memory limit, the call fails with [ErrResponseTooLarge] and its
+// chunks are discarded.
+//
+func (codec *Codec) receiveChunk(received *paho.Publish, response *rpc.Response) (bool, error) {
+	chunk := protocol.ChunkPacket{}
+
+	if err := json.Unmarshal(received.Payload, &chunk); err != nil {
+		return false, nil
+	} else if chunk.Client != codec.name || chunk.Server != codec.server {
+		return false, nil
+	}
+
+	id := getCorrelationData(received)
+
+	if id == "" {
+		id = chunk.Id
+	}
+
+	seq, err := strconv.ParseUint(id, 10, 64)
+
+	if err != nil {
+		return false, nil
+	} else if codec.isTracked(seq) == false {
+		codec.discard(seq)
+		return false, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(chunk.Data)
+
+	if err != nil || chunk.Count <= 0 || chunk.Index < 0 || chunk.Index >= chunk.Count {
+		return false, nil
+	}
+
+	pending, found := codec.assemblies[seq]
+
+	if found == false {
+		pending = &assembly{
+			chunks:   make([][]byte, chunk.Count),
+			deadline: time.Now().Add(codec.options.getChunkTimeout()),
+		}
+
+		codec.assemblies[seq] = pending
+	}
+
+	if len(pending.chunks) != chunk.Count || pending.chunks[chunk.Index] != nil {
+		return false, nil
+	} else if codec.buffered+len(data) > codec.options.getChunkMemoryLimit() {
+		codec.abandon(seq, ErrResponseTooLarge)
+		return false, nil
+	}
+
+	pending.chunks[chunk.Index] = data
+	pending.received++
+	pending.size += len(data)
+	codec.buffered += len(data)
+
+	if pending.received < len(pending.chunks) {
+		return false, nil
+	}
+
+	message := bytes.Join(pending.chunks, nil)
+	codec.discard(seq)
+
+	return codec.process(&paho.Publish{
+		Topic:      received.Topic,
+		Payload:    message,
+		Properties: received.Properties,
+	}, response)
+}
+
+// Discard the chunks kept for the given call.
+//
+func (codec *Codec) discard(seq uint64) {
+	if pending, found := codec.assemblies[seq]; found {
+		codec.buffered -= pending.size
+		delete(codec.assemblies, seq)
+	}
+}
+
+// Discard the chunks kept for the given call, and mark the call as
+// failed with the given error if it is still in flight.
+//
+func (codec *Codec) abandon(seq uint64, err error) {
+	codec.discard(seq)
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	if entry, found := codec.inflight[seq]; found {
+		codec.failures = append(codec.failures, failure{seq: seq, method: entry.method, err: err})
+		delete(codec.inflight, seq)
+	}
+}
+
+// Mark every call whose chunked response is not reassembled before
+// the chunk timeout as failed with the error [ErrIncompleteResponse].
+//
+func (codec *Codec) expireChunks(now time.Time) {
+	for seq, pending := range codec.assemblies {
+		if pending.deadline.After(now) == false {
+			codec.abandon(seq, ErrIncompleteResponse)
+		}
+	}
+}
+
+func (codec *Codec) isTracked(seq uint64) bool {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+	_, found := codec.inflight[seq]
+	return found
+}
@@ -2,27 +2,95 @@ package codec
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
+	"fmt"
 	"github.com/eclipse/paho.golang/paho"
 	"github.com/kmchan2018/mindctrl/client/protocol"
 	"net/rpc"
-	"nhooyr.io/websocket"
 	"strconv"
+	"sync"
 	"time"
 )
 
 // Implementation of net/rpc client codec for communicating with
-// the mindctrl browser extension ("server") via websocket
-// connection to an intermediate MQTT broker.
+// the mindctrl browser extension ("server") via an intermediate
+// MQTT broker. The broker can be reached by websocket, plain TCP
+// or TLS connection depending on the url scheme.
+//
+// Note that net/rpc writes requests and reads responses in separate
+// goroutines. Fields shared by both sides are protected by the
+// mutex, while the backlog, the response and the chunks of chunked
+// responses are only accessed by the reading side.
 //
 type Codec struct {
 	ctx         context.Context
-	mqtt        *paho.Client
+	cancel      context.CancelFunc
+	url         string
 	name        string
 	server      string
+	clientId    string
+	options     *Options
 	channel     chan *paho.Publish
+	lost        chan disconnection
+	backlog     []*paho.Publish
 	response    protocol.ResponsePacket
+	assemblies  map[uint64]*assembly
+	buffered    int
+	mutex       sync.Mutex
+	mqtt        *paho.Client
+	inflight    map[uint64]outstanding
+	failures    []failure
+	outage      error
+	wakeup      chan struct{}
+	status      Status
 	invalidated bool
+	session     bool
+	recorder    *recorder
+	guard       *protocol.ReplayGuard
+	done        chan struct{}
+	err         error
+}
+
+// Outstanding records an in-flight call that is waiting for its
+// response. The deadline is zero if the call never times out. The
+// params are only kept when the codec is recording a cassette.
+//
+type outstanding struct {
+	method   string
+	params   json.RawMessage
+	deadline time.Time
+}
+
+// Disconnection records the loss of a connection to the broker.
+// The connection is recorded so that late notifications from
+// replaced connections can be ignored.
+//
+type disconnection struct {
+	mqtt *paho.Client
+	err  error
+}
+
+// Failure records an in-flight call that cannot be completed due
+// to loss of the server or the broker connection, or because no
+// response is received in time. It will be reported to net/rpc as
+// an error response.
+//
+type failure struct {
+	seq    uint64
+	method string
+	err    error
+}
+
+// Abandonable wraps the input of a call that its caller may abandon
+// before the response arrives, like a call controlled by a context.
+// The wrapper is passed to net/rpc in place of the input, so that the
+// codec can remember the call and give it up with [Codec.Abandon].
+//
+type Abandonable struct {
+	Input   interface{} // actual input of the call
+	seq     uint64
+	written bool
 }
 
 // Create a new net/rpc client codec by connecting to the MQTT
@@ -30,52 +98,168 @@ type Codec struct {
 // alive message from the server.
 //
 func NewCodec(url string, name string, server string, options *Options) (*Codec, error) {
-	ctx := context.TODO()
+	return NewCodecContext(context.Background(), url, name, server, options)
+}
+
+// Create a new net/rpc client codec like [NewCodec]. The given
+// context controls the whole connection phase, including dialing
+// the broker, connecting, subscribing and waiting for the alive
+// message. If the context is cancelled or its deadline passes
+// before the phase completes, the function will give up and
+// return the context error.
+//
+// Note that the context does not control the lifetime of the
+// resulting codec.
+//
+// If a cassette is configured in the options, the codec records
+// every request/response pair to the cassette; see [Interaction]
+// for details.
+//
+// If an Ed25519 signing key of the wrong size is configured in the
+// options, the function fails with the error [ErrInvalidSigningKey].
+// If an Ed25519 signing key is configured in the options without
+// the public key of the server, the function fails with the error
+// [ErrMissingServerKey] unless verification is explicitly skipped.
+// If a capability token is configured that is not bound to the
+// signing key, the function fails with the error [ErrUnboundToken].
+//
+func NewCodecContext(ctx context.Context, url string, name string, server string, options *Options) (*Codec, error) {
+	var recorder *recorder
+
+	if options.getSignatureAlgorithm() == protocol.Ed25519Signature && len(options.getSigningKey()) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidSigningKey
+	} else if options.getSignatureAlgorithm() == protocol.Ed25519Signature && len(options.getVerifyingKey()) == 0 && options.getSkipServerVerify() == false {
+		return nil, ErrMissingServerKey
+	} else if token := options.getToken(); token != "" && isBoundToken(token, options) == false {
+		return nil, ErrUnboundToken
+	}
+
+	if path := options.getRecordFile(); path != "" {
+		if opened, err := openRecorder(path, options.getRecordAppend()); err != nil {
+			return nil, err
+		} else {
+			recorder = opened
+		}
+	}
+
+	lifetime, cancel := context.WithCancel(context.Background())
+
+	codec := &Codec{
+		ctx:         lifetime,
+		cancel:      cancel,
+		url:         url,
+		name:        name,
+		server:      server,
+		clientId:    options.getMqttClientId(name),
+		options:     options,
+		channel:     make(chan *paho.Publish, options.getMqttMessageBuffer()),
+		lost:        make(chan disconnection, 8),
+		assemblies:  make(map[uint64]*assembly),
+		inflight:    make(map[uint64]outstanding),
+		wakeup:      make(chan struct{}, 1),
+		invalidated: false,
+		recorder:    recorder,
+		guard:       protocol.NewReplayGuard(options.getSignatureWindow()),
+		done:        make(chan struct{}),
+	}
+
+	if mqtt, err := codec.connect(ctx, false); err != nil {
+		cancel()
+
+		if recorder != nil {
+			recorder.close()
+		}
 
-	socketOptions := &websocket.DialOptions{
-		Subprotocols: []string{"mqtt"},
+		return nil, err
+	} else {
+		codec.mqtt = mqtt
+		return codec, nil
 	}
+}
 
-	if socket, _, err := websocket.Dial(ctx, url, socketOptions); err != nil {
+// Connect to the MQTT broker, subscribe to the relevant topics and
+// wait for the alive message from the server.
+//
+// When the reconnecting flag is set, any dead status message is
+// ignored and the function keeps waiting for a fresh alive message,
+// since the server may be restarting. Moreover, any persistent
+// session on the broker is resumed instead of being discarded. If
+// the broker has no session to resume, like when the session has
+// expired or the broker keeps no session at all, the calls kept in
+// flight for the session are failed with [ErrConnectionLost].
+//
+func (codec *Codec) connect(ctx context.Context, reconnecting bool) (*paho.Client, error) {
+	if connection, err := dial(ctx, codec.url, codec.options); err != nil {
 		return nil, err
 	} else {
-		socket.SetReadLimit(options.getWebsocketFrameSize())
+		var mqtt *paho.Client
 
-		connection := websocket.NetConn(ctx, socket, websocket.MessageBinary)
-		channel := make(chan *paho.Publish, options.getMqttMessageBuffer())
-		topic1 := protocol.GetClientTopic(name)
-		topic2 := protocol.GetServerStatusTopic(server)
+		topic1 := protocol.GetClientTopic(codec.name)
+		topic2 := protocol.GetServerStatusTopic(codec.server)
+		topic3 := codec.options.getMqttResponseTopic(codec.name)
 
-		mqtt := paho.NewClient(paho.ClientConfig{
+		mqtt = paho.NewClient(paho.ClientConfig{
 			Conn: connection,
 			Router: paho.NewSingleHandlerRouter(func(m *paho.Publish) {
-				channel <- m
+				select {
+				case codec.channel <- m:
+				case <-codec.ctx.Done():
+				}
 			}),
+			OnClientError: func(err error) {
+				codec.disconnect(mqtt, err)
+			},
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				codec.disconnect(mqtt, ErrConnectionLost)
+			},
 		})
 
 		connectPacket := &paho.Connect{
-			KeepAlive:    options.getMqttKeepAlive(),
-			ClientID:     protocol.GenerateMqttClientId(name),
+			KeepAlive:    codec.options.getMqttKeepAlive(),
+			ClientID:     codec.clientId,
 			CleanStart:   true,
-			UsernameFlag: options.getPahoUsernameFlag(),
-			PasswordFlag: options.getPahoPasswordFlag(),
-			Username:     options.getPahoUsername(),
-			Password:     options.getPahoPassword(),
+			UsernameFlag: codec.options.getPahoUsernameFlag(),
+			PasswordFlag: codec.options.getPahoPasswordFlag(),
+			Username:     codec.options.getPahoUsername(),
+			Password:     codec.options.getPahoPassword(),
+		}
+
+		// When persistent session is enabled, the broker keeps the
+		// session for the configured duration after the connection
+		// is lost, and delivers any response queued in the meantime
+		// when the session is resumed. The session is only resumed
+		// on reconnection; a new codec always starts with a clean
+		// session so that stale responses from the previous owner
+		// of the client ID are not mistaken for new ones.
+
+		if expiry := codec.options.getMqttSessionExpiry(); expiry > 0 {
+			connectPacket.CleanStart = (reconnecting == false)
+			connectPacket.Properties = &paho.ConnectProperties{SessionExpiryInterval: &expiry}
 		}
 
 		subscribePacket := &paho.Subscribe{
 			Subscriptions: map[string]paho.SubscribeOptions{
-				topic1: {},
-				topic2: {},
+				topic1: {QoS: codec.options.getMqttQoS()},
+				topic2: {QoS: codec.options.getMqttQoS()},
 			},
 		}
 
-		if ack, err := mqtt.Connect(ctx, connectPacket); err != nil {
-			socket.Close(websocket.StatusNormalClosure, "connection error")
-			return nil, err
-		} else if ack.ReasonCode != 0 {
-			socket.Close(websocket.StatusNormalClosure, "connection error")
+		if topic3 != topic1 {
+			subscribePacket.Subscriptions[topic3] = paho.SubscribeOptions{QoS: codec.options.getMqttQoS()}
+		}
+
+		if ack, err := mqtt.Connect(ctx, connectPacket); ack != nil && ack.ReasonCode != 0 {
+			connection.Close()
+			return nil, getConnackError(ack)
+		} else if err != nil {
+			connection.Close()
 			return nil, err
+		} else {
+			codec.session = hasSessionExpiry(connectPacket, ack)
+
+			if reconnecting && ack.SessionPresent == false {
+				codec.failInflight(ErrConnectionLost)
+			}
 		}
 
 		if _, err := mqtt.Subscribe(ctx, subscribePacket); err != nil {
@@ -95,39 +279,107 @@ func NewCodec(url string, name string, server string, options *Options) (*Codec,
 		//
 		// The timer exists to cap the waiting time to 5 seconds if
 		// no status message is coming.
+		//
+		// Other messages received in the meantime, like responses
+		// delivered from a resumed session, are kept in the backlog
+		// for processing later.
 
-		codec := &Codec{ctx: ctx, mqtt: mqtt, name: name, server: server, channel: channel, invalidated: false}
 		timer := time.NewTimer(5 * time.Second)
 
 		for {
 			select {
-			case received := <-channel:
+			case received := <-codec.channel:
 				if protocol.IsServerStatusTopic(received.Topic) {
 					if protocol.IsAliveStatusMessage(received.Payload) {
 						timer.Stop()
-						return codec, nil
-					} else if protocol.IsDeadStatusMessage(received.Payload) {
+						codec.beat()
+						return mqtt, nil
+					} else if protocol.IsDeadStatusMessage(received.Payload) && reconnecting == false {
 						timer.Stop()
+						mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
 						return nil, ErrServerDead
 					}
+				} else {
+					codec.backlog = append(codec.backlog, received)
+				}
+
+			case lost := <-codec.lost:
+				if lost.mqtt == mqtt {
+					timer.Stop()
+					return nil, lost.err
 				}
 
 			case <-timer.C:
+				mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
 				return nil, ErrServerDead
+
+			case <-ctx.Done():
+				timer.Stop()
+				mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
+				return nil, ctx.Err()
+
+			case <-codec.ctx.Done():
+				timer.Stop()
+				mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
+				return nil, ErrClosed
 			}
 		}
 	}
 }
 
+// Report the loss of the given connection to the broker. The
+// notification is dropped if the queue is full, since the reader
+// only cares about the current connection and a lost connection
+// cannot be reported more than a few times.
+//
+func (codec *Codec) disconnect(mqtt *paho.Client, err error) {
+	select {
+	case codec.lost <- disconnection{mqtt: mqtt, err: err}:
+		return
+	default:
+		return
+	}
+}
+
 // Submit a RPC request to the server for execution by publishing a
 // message to the server topic.
 //
 // Note that if the codec is invalidated by previous dead status
 // message, the function will do nothing and return the error
-// [ErrServerDead] directly.
+// [ErrServerDead] directly. Similarly, if the codec is reconnecting
+// to the server, the function will return the error
+// [ErrReconnecting] directly, and if the codec is closed, the
+// function will return the error [ErrClosed] directly.
+//
+// If a request timeout is configured in the options, the call will
+// fail with the error [ErrTimeout] when no response is received
+// before the timeout.
+//
+// If the input is wrapped in [Batched], the request is held back and
+// published later together with the other requests of the batch. If
+// the input is wrapped in [Abandonable], possibly around [Batched],
+// the call can be given up later with [Codec.Abandon].
+//
+// If signing is enabled in the options, the request is wrapped in a
+// [protocol.SignedPacket] before it is published.
 //
 func (codec *Codec) WriteRequest(request *rpc.Request, input interface{}) error {
-	if codec.invalidated == false {
+	var batch *Batch
+
+	if abandonable, ok := input.(*Abandonable); ok {
+		abandonable.seq = request.Seq
+		abandonable.written = true
+		input = abandonable.Input
+	}
+
+	if batched, ok := input.(*Batched); ok {
+		batch = batched.Batch
+		input = batched.Input
+	}
+
+	if codec.ctx.Err() != nil {
+		return ErrClosed
+	} else if codec.isInvalidated() == false {
 		packet := &protocol.RequestPacket{}
 		packet.Type = "request"
 		packet.Method = request.ServiceMethod
@@ -135,18 +387,52 @@ func (codec *Codec) WriteRequest(request *rpc.Request, input interface{}) error
 		packet.Client = codec.name
 		packet.Server = codec.server
 		packet.Input = input
+		packet.Accept = protocol.AcceptedEncodings
+		packet.ChunkSize = codec.options.getChunkSize()
+		packet.Token = codec.options.getToken()
+
+		var params json.RawMessage
+
+		if codec.recorder != nil || codec.options.getCompression() != "" {
+			params, _ = json.Marshal(input)
+		}
+
+		if encoding := codec.options.getCompression(); encoding != "" && len(params) >= codec.options.getCompressionThreshold() {
+			if compressed, err := protocol.CompressBody(encoding, params); err != nil {
+				return err
+			} else {
+				packet.Input = compressed
+				packet.Encoding = encoding
+			}
+		}
 
 		if mPacket, err := json.Marshal(packet); err != nil {
 			return err
+		} else if signed, err := codec.sign(mPacket); err != nil {
+			return err
+		} else if mqtt := codec.track(request.Seq, request.ServiceMethod, params); mqtt == nil {
+			return ErrReconnecting
+		} else if batch != nil && batch.hold(request.Seq, packet, codec.getBatchedSize(mPacket)) {
+			return nil
 		} else {
+			// The routing information is carried twice: in the
+			// JSON packet for older extensions, and in the MQTT v5
+			// Response Topic and Correlation Data properties for
+			// newer extensions and generic MQTT tooling.
+
 			publishPacket := &paho.Publish{
 				Topic:   protocol.GetServerRpcTopic(codec.server),
-				QoS:     0,
+				QoS:     codec.options.getMqttQoS(),
 				Retain:  false,
-				Payload: mPacket,
+				Payload: signed,
+				Properties: &paho.PublishProperties{
+					ResponseTopic:   codec.options.getMqttResponseTopic(codec.name),
+					CorrelationData: []byte(packet.Id),
+				},
 			}
 
-			if _, err := codec.mqtt.Publish(codec.ctx, publishPacket); err != nil {
+			if _, err := mqtt.Publish(codec.ctx, publishPacket); err != nil {
+				codec.untrack(request.Seq)
 				return err
 			} else {
 				return nil
@@ -161,58 +447,259 @@ func (codec *Codec) WriteRequest(request *rpc.Request, input interface{}) error
 // the RPC response and cache the "body" part for the subsequent
 // [Codec.ReadResponseBody] calls.
 //
-// The function will also check for status messages. If any "dead"
-// status message is received, the codec will be invalidated and
-// the error [ErrServerDead] is returned.
+// The function will also check for status messages and loss of the
+// broker connection. If any "dead" status message is received or
+// the connection is lost, the codec will be invalidated and the
+// error [ErrServerDead] or [ErrConnectionLost] is returned, unless
+// automatic reconnection is enabled in the options. In that case,
+// the calls in flight will fail and the codec will reconnect to
+// the server in the background.
+//
+// Calls that are timed out are reported as failed with the error
+// [ErrTimeout]. Any response that arrives after the call is timed
+// out is discarded.
+//
+// If the heartbeat watchdog is enabled in the options, the server
+// is also considered dead when no alive message is received for the
+// configured number of heartbeat intervals.
+//
+// Once the function returns an error, the codec is terminated: the
+// channel returned by [Codec.Done] is closed, the error is recorded
+// for [Codec.Err] and the OnDisconnect hook in the options is
+// invoked. The error is [ErrClosed] if the codec is closed.
 //
 func (codec *Codec) ReadResponseHeader(response *rpc.Response) error {
-	if codec.invalidated == false {
-		for received := range codec.channel {
-			//
-			// A will that contains the text "dead" will be posted to the
-			// status topic when the server disconnects. After receiving
-			// the will, no more message is expected from the server. In
-			// this situation, the codec can only be closed. Any other
-			// operations will fail with io.EOF as error.
-			//
-
-			if protocol.IsServerStatusTopic(received.Topic) {
-				if protocol.IsDeadStatusMessage(received.Payload) {
-					codec.invalidated = true
-					return ErrServerDead
-				} else {
-					continue
-				}
-			}
+	if err := codec.readResponseHeader(response); err != nil {
+		codec.terminate(err)
+		return err
+	} else {
+		return nil
+	}
+}
 
-			//
-			// Apart from the special case above, the message should be
-			// the response to an outstanding request.
-			//
+// Receive a RPC response from the server. See
+// [Codec.ReadResponseHeader] for details.
+//
+func (codec *Codec) readResponseHeader(response *rpc.Response) error {
+	for codec.isInvalidated() == false {
+		if codec.ctx.Err() != nil {
+			return ErrClosed
+		}
 
-			if err := json.Unmarshal(received.Payload, &codec.response); err != nil {
-				continue
-			} else if id, err := strconv.ParseUint(codec.response.Id, 10, 64); err != nil {
+		if failed, found := codec.popFailure(); found {
+			response.ServiceMethod = failed.method
+			response.Seq = failed.seq
+			response.Error = failed.err.Error()
+			return nil
+		}
+
+		if codec.outage != nil {
+			if err := codec.reconnect(); err != nil {
+				return err
+			} else {
 				continue
+			}
+		}
+
+		if received, found := codec.popBacklog(); found {
+			if done, err := codec.receive(received, response); err != nil {
+				return err
+			} else if done {
+				return nil
 			} else {
-				if codec.response.Type != "response" {
-					continue
-				} else if codec.response.Client != codec.name {
-					continue
-				} else if codec.response.Server != codec.server {
-					continue
+				continue
+			}
+		}
+
+		stop, expiry := codec.watchDeadline()
+		stopWatchdog, watchdog := codec.watchHeartbeat()
+
+		select {
+		case <-expiry:
+			stopWatchdog()
+			codec.expire(time.Now())
+
+		case <-watchdog:
+			stop()
+
+			if codec.isHeartbeatStopped(time.Now()) {
+				if err := codec.recover(ErrServerDead); err != nil {
+					return err
 				}
+			}
 
-				response.ServiceMethod = codec.response.Method
-				response.Seq = id
+		case <-codec.wakeup:
+			stop()
+			stopWatchdog()
+
+		case <-codec.ctx.Done():
+			stop()
+			stopWatchdog()
+			return ErrClosed
+
+		case received := <-codec.channel:
+			stop()
+			stopWatchdog()
+
+			if done, err := codec.receive(received, response); err != nil {
+				return err
+			} else if done {
 				return nil
 			}
+
+		case lost := <-codec.lost:
+			stop()
+			stopWatchdog()
+
+			if lost.mqtt == codec.current() {
+				if err := codec.recover(ErrConnectionLost); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
 	return ErrServerDead
 }
 
+// Process a message received from the broker. The function returns
+// true if the message is the response to an outstanding request,
+// in which case the response header is filled in. It returns an
+// error if the server is found dead and the codec cannot recover.
+//
+func (codec *Codec) receive(received *paho.Publish, response *rpc.Response) (bool, error) {
+	//
+	// A will that contains the text "dead" will be posted to the
+	// status topic when the server disconnects. After receiving
+	// the will, no more message is expected from the server. In
+	// this situation, the codec can only be closed or reconnected
+	// to the restarted server.
+	//
+
+	if protocol.IsServerStatusTopic(received.Topic) {
+		if protocol.IsAliveStatusMessage(received.Payload) {
+			codec.beat()
+			return false, nil
+		} else if protocol.IsDeadStatusMessage(received.Payload) {
+			return false, codec.recover(ErrServerDead)
+		} else {
+			return false, nil
+		}
+	}
+
+	//
+	// When signing is enabled, every other message must be a
+	// signed packet with a valid and fresh signature. The wrapped
+	// message is processed in place of the signed packet.
+	//
+
+	if payload, ok := codec.verify(received.Payload); ok == false {
+		return false, nil
+	} else {
+		return codec.process(&paho.Publish{
+			Topic:      received.Topic,
+			Payload:    payload,
+			Properties: received.Properties,
+		}, response)
+	}
+}
+
+// Process a message other than status messages received from the
+// broker, after its signature is verified. The function returns the
+// same values as [Codec.receive].
+//
+func (codec *Codec) process(received *paho.Publish, response *rpc.Response) (bool, error) {
+	//
+	// The message should be the response to an outstanding request
+	// from this client. If the response carries the MQTT v5
+	// Correlation Data property, the property identifies the
+	// request. Otherwise, the id in the JSON packet is used as a
+	// fallback for older extensions. The routing fields in the
+	// JSON packet are checked in either case.
+	//
+	// Responses too large for a single message arrive as chunk
+	// packets instead, which are reassembled before processing.
+	//
+
+	codec.response = protocol.ResponsePacket{}
+
+	if err := json.Unmarshal(received.Payload, &codec.response); err != nil {
+		return false, nil
+	} else if codec.response.Type == "chunk" {
+		return codec.receiveChunk(received, response)
+	} else if codec.response.Type != "response" {
+		return false, nil
+	} else if codec.response.Client != codec.name {
+		return false, nil
+	} else if codec.response.Server != codec.server {
+		return false, nil
+	} else if id, err := strconv.ParseUint(getResponseId(received, &codec.response), 10, 64); err != nil {
+		return false, nil
+	} else if entry, found := codec.untrack(id); found == false {
+		return false, nil
+	} else {
+		response.ServiceMethod = codec.response.Method
+		response.Seq = id
+		codec.complete(entry, response)
+		return true, nil
+	}
+}
+
+// Sign the given message if signing is enabled in the options.
+// Otherwise, the message is returned as it is.
+//
+func (codec *Codec) sign(message []byte) ([]byte, error) {
+	if algorithm := codec.options.getSignatureAlgorithm(); algorithm == "" {
+		return message, nil
+	} else {
+		return protocol.SignMessage(message, algorithm, codec.options.getSigningKey())
+	}
+}
+
+// Check if the given capability token is bound to the Ed25519 signing
+// key in the given options. The size of the key must be checked
+// beforehand.
+//
+func isBoundToken(token string, options *Options) bool {
+	if options.getSignatureAlgorithm() != protocol.Ed25519Signature {
+		return false
+	} else if claims, err := protocol.ParseToken(token); err != nil {
+		return false
+	} else {
+		return claims.Binds(ed25519.PrivateKey(options.getSigningKey()).Public().(ed25519.PublicKey))
+	}
+}
+
+// Verify the signature of the given message and return the message
+// wrapped inside. The function returns false if signing is enabled in
+// the options and the message is not signed, the signature is invalid
+// or the message is stale or replayed. Signed messages are unwrapped
+// without verification when no key to verify them is configured,
+// which is only possible when verification is explicitly skipped in
+// the options.
+//
+func (codec *Codec) verify(message []byte) ([]byte, bool) {
+	algorithm := codec.options.getSignatureAlgorithm()
+	key := codec.options.getVerifyingKey()
+	packet, signed := protocol.OpenMessage(message)
+
+	if algorithm == "" || len(key) == 0 {
+		if signed {
+			return []byte(packet.Payload), true
+		} else {
+			return message, true
+		}
+	} else if signed == false {
+		return nil, false
+	} else if packet.Verify(algorithm, key) == false {
+		return nil, false
+	} else if codec.guard.Check(packet, time.Now()) == false {
+		return nil, false
+	} else {
+		return []byte(packet.Payload), true
+	}
+}
+
 // Decode the result from the previous RPC response to the given
 // output object.
 //
@@ -221,7 +708,7 @@ func (codec *Codec) ReadResponseHeader(response *rpc.Response) error {
 // [ErrServerDead] directly.
 //
 func (codec *Codec) ReadResponseBody(output interface{}) error {
-	if codec.invalidated == false {
+	if codec.isInvalidated() == false {
 		if output == nil {
 			return nil
 		} else {
@@ -239,8 +726,314 @@ func (codec *Codec) ReadResponseBody(output interface{}) error {
 // Close the connection to the intermediate MQTT broker and
 // free any resources used by the codec.
 //
+// The codec is terminated with the error [ErrClosed] before the
+// connection is closed, so that the pending read unblocks and any
+// further request fails with [ErrClosed]. Messages still delivered
+// by the broker connection afterwards are discarded. Closing the
+// codec more than once has no further effect.
+//
 func (codec *Codec) Close() error {
-	err := codec.mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
-	close(codec.channel)
-	return err
+	codec.mutex.Lock()
+	mqtt := codec.mqtt
+	codec.mqtt = nil
+	codec.cancel()
+	codec.mutex.Unlock()
+
+	codec.terminate(ErrClosed)
+
+	if mqtt != nil {
+		return mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
+	} else {
+		return nil
+	}
+}
+
+// Return a channel that is closed when the codec is terminated,
+// either because it is closed or because the server or the broker
+// connection is lost and cannot be recovered.
+//
+func (codec *Codec) Done() <-chan struct{} {
+	return codec.done
+}
+
+// Return the error that terminated the codec, or nil if the codec
+// is still usable. The error is [ErrClosed] if the codec is closed.
+//
+func (codec *Codec) Err() error {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+	return codec.err
+}
+
+// Terminate the codec with the given error. Only the first call
+// has effect; it records the error, closes the done channel and
+// invokes the OnDisconnect hook in the options.
+//
+func (codec *Codec) terminate(err error) {
+	codec.mutex.Lock()
+
+	if codec.err != nil {
+		codec.mutex.Unlock()
+		return
+	}
+
+	codec.err = err
+	codec.status.Alive = false
+	close(codec.done)
+	codec.mutex.Unlock()
+
+	if codec.recorder != nil {
+		codec.recorder.close()
+	}
+
+	if hook := codec.options.getOnDisconnect(); hook != nil {
+		hook(err)
+	}
+}
+
+// Return the MQTT v5 Correlation Data property of the given message,
+// or an empty string if the property is absent.
+//
+func getCorrelationData(received *paho.Publish) string {
+	if received.Properties == nil {
+		return ""
+	} else {
+		return string(received.Properties.CorrelationData)
+	}
+}
+
+// Return the id of the request answered by the given response, which
+// is the Correlation Data property of the message if present, or the
+// id in the JSON packet otherwise.
+//
+func getResponseId(received *paho.Publish, packet *protocol.ResponsePacket) string {
+	if correlation := getCorrelationData(received); correlation != "" {
+		return correlation
+	} else {
+		return packet.Id
+	}
+}
+
+// Check if the broker keeps the session after the connection is lost,
+// which is the case when the given CONNECT packet asks for a session
+// expiry interval and the given CONNACK packet does not reduce it to
+// zero.
+//
+func hasSessionExpiry(connect *paho.Connect, ack *paho.Connack) bool {
+	if connect.Properties == nil || connect.Properties.SessionExpiryInterval == nil || *connect.Properties.SessionExpiryInterval == 0 {
+		return false
+	} else if ack.Properties == nil || ack.Properties.SessionExpiryInterval == nil {
+		return true
+	} else {
+		return *ack.Properties.SessionExpiryInterval > 0
+	}
+}
+
+// Return the error for the given CONNACK packet that refuses the
+// connection, which names the reason code together with the reason
+// string from the broker, if any.
+//
+func getConnackError(ack *paho.Connack) error {
+	if ack.Properties != nil && ack.Properties.ReasonString != "" {
+		return fmt.Errorf("%w: reason code 0x%02x (%s)", ErrConnectionRefused, ack.ReasonCode, ack.Properties.ReasonString)
+	} else {
+		return fmt.Errorf("%w: reason code 0x%02x", ErrConnectionRefused, ack.ReasonCode)
+	}
+}
+
+// Give up the call of the given input, which must be passed to net/rpc
+// before. The call is no longer tracked, and it is reported to net/rpc
+// as failed with the error [ErrAbandoned] at once, so that neither the
+// codec nor net/rpc keeps the call if the server never answers. Any
+// response that arrives afterwards is discarded.
+//
+func (codec *Codec) Abandon(input *Abandonable) {
+	if input.written {
+		codec.mutex.Lock()
+
+		if entry, found := codec.inflight[input.seq]; found {
+			codec.failures = append(codec.failures, failure{seq: input.seq, method: entry.method, err: ErrAbandoned})
+			delete(codec.inflight, input.seq)
+		}
+
+		codec.mutex.Unlock()
+		codec.notify()
+	}
+}
+
+// Mark every call in flight as failed with the given error.
+//
+func (codec *Codec) failInflight(err error) {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	for seq, entry := range codec.inflight {
+		codec.failures = append(codec.failures, failure{seq: seq, method: entry.method, err: err})
+	}
+
+	codec.inflight = make(map[uint64]outstanding)
+}
+
+func (codec *Codec) isInvalidated() bool {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+	return codec.invalidated
+}
+
+func (codec *Codec) invalidate() {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+	codec.invalidated = true
+	codec.status.Alive = false
+}
+
+func (codec *Codec) current() *paho.Client {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+	return codec.mqtt
+}
+
+func (codec *Codec) track(seq uint64, method string, params json.RawMessage) *paho.Client {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	if codec.mqtt != nil {
+		if timeout := codec.options.getRequestTimeout(); timeout > 0 {
+			codec.inflight[seq] = outstanding{method: method, params: params, deadline: time.Now().Add(timeout)}
+			codec.notify()
+		} else {
+			codec.inflight[seq] = outstanding{method: method, params: params}
+		}
+	}
+
+	return codec.mqtt
+}
+
+func (codec *Codec) untrack(seq uint64) (outstanding, bool) {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	if entry, found := codec.inflight[seq]; found {
+		delete(codec.inflight, seq)
+		return entry, true
+	} else {
+		return outstanding{}, false
+	}
+}
+
+// Complete the call of the current response. A compressed output is
+// decompressed first, so that both the cassette and the caller get
+// plain JSON; the call fails if the output cannot be decompressed.
+//
+func (codec *Codec) complete(entry outstanding, response *rpc.Response) {
+	if codec.response.Encoding == "" {
+		codec.record(entry)
+	} else if output, err := protocol.DecompressBody(codec.response.Encoding, codec.response.Output); err != nil {
+		response.Error = fmt.Sprintf("cannot decompress result: %s", err)
+		codec.response.Output = nil
+	} else {
+		codec.response.Output = output
+		codec.response.Encoding = ""
+		codec.record(entry)
+	}
+}
+
+// Record the current response together with the request of the
+// given call to the cassette, if the codec is recording one. Failure
+// to record does not fail the call.
+//
+func (codec *Codec) record(entry outstanding) {
+	if codec.recorder != nil {
+		codec.recorder.record(Interaction{
+			Time:   time.Now(),
+			Method: entry.method,
+			Params: entry.params,
+			Result: codec.response.Output,
+		})
+	}
+}
+
+func (codec *Codec) popBacklog() (*paho.Publish, bool) {
+	if len(codec.backlog) > 0 {
+		received := codec.backlog[0]
+		codec.backlog = codec.backlog[1:]
+		return received, true
+	} else {
+		return nil, false
+	}
+}
+
+func (codec *Codec) popFailure() (failure, bool) {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	if len(codec.failures) > 0 {
+		failed := codec.failures[0]
+		codec.failures = codec.failures[1:]
+		return failed, true
+	} else {
+		return failure{}, false
+	}
+}
+
+// Wake up the reader so that it can watch for the deadline of the
+// newly submitted call.
+//
+func (codec *Codec) notify() {
+	select {
+	case codec.wakeup <- struct{}{}:
+		return
+	default:
+		return
+	}
+}
+
+// Start a timer for the earliest deadline among the calls in flight
+// and the chunked responses being reassembled. The function returns
+// a function that stops the timer and the channel of the timer. The
+// channel is nil if nothing can time out.
+//
+func (codec *Codec) watchDeadline() (func(), <-chan time.Time) {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	earliest := time.Time{}
+
+	for _, pending := range codec.assemblies {
+		if earliest.IsZero() || pending.deadline.Before(earliest) {
+			earliest = pending.deadline
+		}
+	}
+
+	for _, entry := range codec.inflight {
+		if entry.deadline.IsZero() {
+			continue
+		} else if earliest.IsZero() || entry.deadline.Before(earliest) {
+			earliest = entry.deadline
+		}
+	}
+
+	if earliest.IsZero() {
+		return func() {}, nil
+	} else {
+		timer := time.NewTimer(time.Until(earliest))
+		return func() { timer.Stop() }, timer.C
+	}
+}
+
+// Mark every call in flight whose deadline has passed as failed
+// with the error [ErrTimeout], and every call whose chunked response
+// is overdue as failed with the error [ErrIncompleteResponse].
+//
+func (codec *Codec) expire(now time.Time) {
+	codec.expireChunks(now)
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	for seq, entry := range codec.inflight {
+		if entry.deadline.IsZero() == false && entry.deadline.After(now) == false {
+			codec.failures = append(codec.failures, failure{seq: seq, method: entry.method, err: ErrTimeout})
+			delete(codec.inflight, seq)
+		}
+	}
 }
@@ -0,0 +1,306 @@
+package codec
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"nhooyr.io/websocket"
+	"time"
+)
+
+// Open a network connection to the intermediate MQTT broker at the
+// given url, in the same way as the codec does. Only the options
+// about the connection itself, like TLS options and websocket frame
+// size, are relevant. The function allows other MQTT clients, like
+// test servers, to share the support of url schemes with the codec.
+//
+// The given context controls the dialing only; it does not control
+// the lifetime of the resulting connection.
+//
+func Dial(ctx context.Context, url string, options *Options) (net.Conn, error) {
+	return dial(ctx, url, options)
+}
+
+// Open a network connection to the intermediate MQTT broker at the
+// given url. The function supports the following url schemes:
+//
+//   - ws and wss for MQTT over websocket (optionally secured by TLS)
+//   - mqtt and tcp for plain MQTT over TCP
+//   - mqtts and ssl for MQTT over TLS
+//
+// When the url does not specify a port, the standard MQTT ports 1883
+// and 8883 are used for plain and secure MQTT connections respectively.
+//
+// The given context controls the dialing only; it does not control
+// the lifetime of the resulting connection.
+//
+func dial(ctx context.Context, url string, options *Options) (net.Conn, error) {
+	if parsed, err := neturl.Parse(url); err != nil {
+		return nil, err
+	} else if parsed.Host == "" {
+		return nil, fmt.Errorf("%w: url %q does not contain a host", ErrInvalidUrl, url)
+	} else {
+		switch parsed.Scheme {
+		case "ws":
+			return dialWebsocket(ctx, url, nil, options)
+		case "wss":
+			if options.hasTlsConfig() == false {
+				return dialWebsocket(ctx, url, nil, options)
+			} else if config, err := options.getTlsConfig(parsed.Hostname()); err != nil {
+				return nil, err
+			} else {
+				return dialWebsocket(ctx, url, config, options)
+			}
+		case "mqtt", "tcp":
+			return dialTcp(ctx, withDefaultPort(parsed, "1883"))
+		case "mqtts", "ssl":
+			if config, err := options.getTlsConfig(parsed.Hostname()); err != nil {
+				return nil, err
+			} else {
+				return dialTls(ctx, withDefaultPort(parsed, "8883"), config)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported scheme %q in url %q; expecting ws, wss, mqtt, mqtts, tcp or ssl", ErrInvalidUrl, parsed.Scheme, url)
+		}
+	}
+}
+
+// Open a websocket connection to the broker with the "mqtt"
+// subprotocol and wrap it as a network connection. If the TLS
+// config is given, it will be used for the secure connection
+// instead of the default one.
+//
+func dialWebsocket(ctx context.Context, url string, config *tls.Config, options *Options) (net.Conn, error) {
+	socketOptions := &websocket.DialOptions{
+		Subprotocols: []string{"mqtt"},
+	}
+
+	if config != nil {
+		socketOptions.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: config},
+		}
+	}
+
+	if socket, _, err := websocket.Dial(ctx, url, socketOptions); err != nil {
+		return nil, err
+	} else {
+		socket.SetReadLimit(options.getWebsocketFrameSize())
+		return serialize(websocket.NetConn(context.Background(), socket, websocket.MessageBinary))
+	}
+}
+
+// Connection wrapper that writes every MQTT packet to the underlying
+// connection in a single write, so that concurrent packets are not
+// interleaved.
+//
+// The MQTT client writes every packet as a batch of buffers with
+// [net.Buffers.WriteTo], and it writes from multiple goroutines, e.g.
+// when it publishes a request while acknowledging a response. TCP
+// connections write such a batch atomically, but other connections
+// like websocket and TLS connections receive one Write call per
+// buffer, so pieces of concurrent packets can interleave.
+//
+// The wrapper therefore embeds one end of a loopback TCP connection,
+// so that the MQTT client writes every batch to it atomically. A pump
+// goroutine reads whole packets from the other end and writes each of
+// them to the underlying connection at once. Reads, addresses and read
+// deadlines go to the underlying connection directly.
+//
+// When the pump fails, like when the underlying connection fails or a
+// packet carries a malformed fixed header, it closes both connections,
+// so that the MQTT client sees the failure on its next read or write.
+//
+type packetConn struct {
+	*net.TCPConn               // end of the loopback connection written by the client
+	conn         net.Conn      // underlying connection
+	done         chan struct{} // closed when the pump stops
+}
+
+// Wrap the given connection with a packet connection. The function
+// closes the given connection if the loopback connection cannot be
+// set up.
+//
+func serialize(conn net.Conn) (net.Conn, error) {
+	if local, remote, err := getLoopback(); err != nil {
+		conn.Close()
+		return nil, err
+	} else {
+		wrapped := &packetConn{TCPConn: local, conn: conn, done: make(chan struct{})}
+		go wrapped.pump(remote)
+		return wrapped, nil
+	}
+}
+
+// Open a loopback TCP connection and return both of its ends. Other
+// processes may connect to the temporary listener too, so connections
+// not coming from the local end are rejected.
+//
+func getLoopback() (*net.TCPConn, net.Conn, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer listener.Close()
+	local, err := net.Dial("tcp", listener.Addr().String())
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		if remote, err := listener.Accept(); err != nil {
+			local.Close()
+			return nil, nil, err
+		} else if remote.RemoteAddr().String() != local.LocalAddr().String() {
+			remote.Close()
+		} else {
+			return local.(*net.TCPConn), remote, nil
+		}
+	}
+}
+
+// Read whole packets from the given end of the loopback connection and
+// write each of them to the underlying connection, until either of the
+// connections fails or closes.
+//
+func (conn *packetConn) pump(remote net.Conn) {
+	reader := bufio.NewReader(remote)
+
+	for {
+		if packet, err := readPacket(reader); err != nil {
+			break
+		} else if _, err := conn.conn.Write(packet); err != nil {
+			break
+		}
+	}
+
+	remote.Close()
+	conn.TCPConn.Close()
+	conn.conn.Close()
+	close(conn.done)
+}
+
+func (conn *packetConn) Read(data []byte) (int, error) {
+	return conn.conn.Read(data)
+}
+
+// Close the connection. Packets already written are passed to the
+// underlying connection before it is closed, unless the pump does not
+// finish within a second, like when the underlying connection stalls.
+//
+func (conn *packetConn) Close() error {
+	err := conn.TCPConn.Close()
+
+	select {
+	case <-conn.done:
+	case <-time.After(time.Second):
+		conn.conn.Close()
+	}
+
+	return err
+}
+
+func (conn *packetConn) LocalAddr() net.Addr {
+	return conn.conn.LocalAddr()
+}
+
+func (conn *packetConn) RemoteAddr() net.Addr {
+	return conn.conn.RemoteAddr()
+}
+
+func (conn *packetConn) SetDeadline(deadline time.Time) error {
+	if err := conn.conn.SetReadDeadline(deadline); err != nil {
+		return err
+	} else {
+		return conn.TCPConn.SetWriteDeadline(deadline)
+	}
+}
+
+func (conn *packetConn) SetReadDeadline(deadline time.Time) error {
+	return conn.conn.SetReadDeadline(deadline)
+}
+
+// Error reported by [readPacket] when the fixed header of a packet is
+// malformed.
+//
+var errMalformedPacket = errors.New("malformed packet header")
+
+// Read a whole MQTT packet from the given reader. The function fails
+// if the fixed header of the packet is malformed.
+//
+func readPacket(reader *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 0, 5)
+
+	for {
+		if next, err := reader.ReadByte(); err != nil {
+			return nil, err
+		} else {
+			header = append(header, next)
+		}
+
+		if size, complete := getPacketSize(header); complete {
+			packet := make([]byte, size)
+			copy(packet, header)
+			_, err := io.ReadFull(reader, packet[len(header):])
+			return packet, err
+		} else if len(header) == cap(header) {
+			return nil, errMalformedPacket
+		}
+	}
+}
+
+// Return the size of the MQTT packet at the start of the given data,
+// as given by its fixed header. The function returns false if the
+// fixed header itself is incomplete.
+//
+func getPacketSize(data []byte) (int, bool) {
+	length := 0
+
+	for index := 1; index < len(data) && index <= 4; index++ {
+		length |= int(data[index]&0x7f) << (7 * (index - 1))
+
+		if data[index]&0x80 == 0 {
+			return 1 + index + length, true
+		}
+	}
+
+	return 0, false
+}
+
+// Open a plain TCP connection to the broker.
+//
+func dialTcp(ctx context.Context, address string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// Open a TLS connection to the broker.
+//
+func dialTls(ctx context.Context, address string, config *tls.Config) (net.Conn, error) {
+	dialer := &tls.Dialer{Config: config}
+
+	if conn, err := dialer.DialContext(ctx, "tcp", address); err != nil {
+		return nil, err
+	} else {
+		return serialize(conn)
+	}
+}
+
+// Return the host and port of the given url, filling in the given
+// default port when the url does not specify one.
+//
+func withDefaultPort(parsed *neturl.URL, port string) string {
+	if parsed.Port() == "" {
+		return net.JoinHostPort(parsed.Hostname(), port)
+	} else {
+		return parsed.Host
+	}
+}
@@ -0,0 +1,225 @@
+package codec_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/broker"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Write the given PEM blocks to a new file in the temporary directory
+// of the test, and return the path to the file.
+//
+func writePem(t *testing.T, name string, blocks ...*pem.Block) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	data := []byte{}
+
+	for _, block := range blocks {
+		data = append(data, pem.EncodeToMemory(block)...)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("cannot write %s: %v", name, err)
+	}
+
+	return path
+}
+
+// Generate a self-signed client certificate, and return the paths to
+// the certificate and its private key together with the certificate
+// itself.
+//
+func generateCertificate(t *testing.T) (string, string, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+
+	encoded, err := x509.MarshalPKCS8PrivateKey(key)
+
+	if err != nil {
+		t.Fatalf("cannot encode key: %v", err)
+	}
+
+	certFile := writePem(t, "client.crt", &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyFile := writePem(t, "client.key", &pem.Block{Type: "PRIVATE KEY", Bytes: encoded})
+	return certFile, keyFile, certificate
+}
+
+// Start a broker accepting websocket connections over TLS, and return
+// its url together with the path to a CA file verifying the broker and
+// the TLS config of the broker. The certificate of the broker is valid
+// for 127.0.0.1 and example.com.
+//
+func startTlsBroker(t *testing.T) (string, string, *tls.Config) {
+	t.Helper()
+
+	b := broker.NewBroker(nil)
+	server := httptest.NewUnstartedServer(b)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(func() { server.Close(); b.Close() })
+
+	url := strings.Replace(server.URL, "https://", "wss://", 1)
+	caFile := writePem(t, "ca.crt", &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return url, caFile, server.TLS
+}
+
+// Dial the given url with the given options, and close the resulting
+// connection at once.
+//
+func dial(url string, options *codec.Options) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if conn, err := codec.Dial(ctx, url, options); err != nil {
+		return err
+	} else {
+		return conn.Close()
+	}
+}
+
+func TestDialWithCaFile(t *testing.T) {
+	url, caFile, _ := startTlsBroker(t)
+
+	for name, options := range map[string]*codec.Options{
+		"ca file":     {TlsCaFile: caFile},
+		"server name": {TlsCaFile: caFile, TlsServerName: "example.com"},
+		"insecure":    {TlsInsecure: true},
+	} {
+		if err := dial(url, options); err != nil {
+			t.Errorf("cannot dial with %s: %v", name, err)
+		}
+	}
+
+	for name, options := range map[string]*codec.Options{
+		"no options":        nil,
+		"wrong server name": {TlsCaFile: caFile, TlsServerName: "example.org"},
+	} {
+		if err := dial(url, options); err == nil {
+			t.Errorf("broker with unknown certificate accepted with %s", name)
+		}
+	}
+}
+
+func TestDialWithClientCertificate(t *testing.T) {
+	_, caFile, brokerConfig := startTlsBroker(t)
+	certFile, keyFile, certificate := generateCertificate(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	// A plain TLS listener reports the certificates presented by the
+	// client, since TLS 1.3 clients finish the handshake before the
+	// server verifies their certificates.
+
+	config := brokerConfig.Clone()
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	config.ClientCAs = x509.NewCertPool()
+	config.ClientCAs.AddCert(certificate)
+	presented := make(chan int, 1)
+
+	go func() {
+		if conn, err := tls.NewListener(listener, config).Accept(); err == nil {
+			defer conn.Close()
+
+			if err := conn.(*tls.Conn).Handshake(); err != nil {
+				presented <- 0
+			} else {
+				presented <- len(conn.(*tls.Conn).ConnectionState().PeerCertificates)
+			}
+		}
+	}()
+
+	url := fmt.Sprintf("mqtts://%s", listener.Addr())
+
+	if err := dial(url, &codec.Options{TlsCaFile: caFile, TlsCertFile: certFile, TlsKeyFile: keyFile}); err != nil {
+		t.Fatalf("cannot dial with client certificate: %v", err)
+	}
+
+	select {
+	case count := <-presented:
+		if count != 1 {
+			t.Errorf("client presents %d certificates, expected 1", count)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("handshake not finished")
+	}
+}
+
+func TestDialWithInvalidTlsFiles(t *testing.T) {
+	certFile, keyFile, _ := generateCertificate(t)
+	empty := writePem(t, "empty.crt")
+	missing := filepath.Join(t.TempDir(), "missing.crt")
+
+	// The files are loaded before connecting, so the port does not
+	// need to be open.
+
+	for name, options := range map[string]*codec.Options{
+		"missing ca file":     {TlsCaFile: missing},
+		"empty ca file":       {TlsCaFile: empty},
+		"certificate only":    {TlsCertFile: certFile},
+		"key only":            {TlsKeyFile: keyFile},
+		"missing certificate": {TlsCertFile: missing, TlsKeyFile: keyFile},
+		"certificate as key":  {TlsCertFile: certFile, TlsKeyFile: certFile},
+		"key as ca file":      {TlsCaFile: keyFile},
+	} {
+		for _, url := range []string{"mqtts://127.0.0.1:1", "wss://127.0.0.1:1"} {
+			if err := dial(url, options); err == nil {
+				t.Errorf("%s dialed with %s", url, name)
+			} else if strings.Contains(err.Error(), "connection refused") {
+				t.Errorf("%s dialed with %s before loading the files", url, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package codec
+
+import (
+	"context"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Information on a server found on the intermediate MQTT broker.
+//
+// The 'LastSeen' field contains the time of the last heartbeat of
+// the server. Servers attach the time to their status messages;
+// older servers do not, in which case the field is zero unless a
+// fresh alive message is received during the discovery.
+//
+type ServerInfo struct {
+	Name     string    // name of the server
+	Alive    bool      // whether the server is alive
+	LastSeen time.Time // time of the last heartbeat of the server
+}
+
+// Watcher follows the status messages of all servers on the
+// intermediate MQTT broker and reports every status change to its
+// handler. Each server publishes a retained alive or dead message
+// to its status topic, so the current status of every server is
+// reported shortly after the watcher is created, followed by the
+// heartbeats and the status changes afterwards.
+//
+type Watcher struct {
+	mqtt     *paho.Client
+	handler  func(ServerInfo)
+	handlers sync.WaitGroup
+	mutex    sync.Mutex
+	seen     map[string]time.Time
+	done     chan struct{}
+	closed   bool
+}
+
+// Create a new watcher connected to the intermediate MQTT broker at
+// the given url. The given context controls the connection phase.
+//
+// The handler is invoked with the status of a server whenever a
+// status message is received. It is invoked from the goroutine that
+// reads messages from the broker and therefore should return quickly.
+// Since [Watcher.Close] waits for the handler to return, the handler
+// must not close the watcher itself.
+//
+func Watch(ctx context.Context, url string, options *Options, handler func(ServerInfo)) (*Watcher, error) {
+	connection, err := dial(ctx, url, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &Watcher{
+		handler: handler,
+		seen:    make(map[string]time.Time),
+		done:    make(chan struct{}),
+	}
+
+	watcher.mqtt = paho.NewClient(paho.ClientConfig{
+		Conn:   connection,
+		Router: paho.NewSingleHandlerRouter(watcher.receive),
+		OnServerDisconnect: func(*paho.Disconnect) {
+			watcher.terminate()
+		},
+		OnClientError: func(error) {
+			watcher.terminate()
+		},
+	})
+
+	connectPacket := &paho.Connect{
+		KeepAlive:    options.getMqttKeepAlive(),
+		ClientID:     protocol.GenerateMqttClientId("discovery"),
+		CleanStart:   true,
+		UsernameFlag: options.getPahoUsernameFlag(),
+		PasswordFlag: options.getPahoPasswordFlag(),
+		Username:     options.getPahoUsername(),
+		Password:     options.getPahoPassword(),
+	}
+
+	subscribePacket := &paho.Subscribe{
+		Subscriptions: map[string]paho.SubscribeOptions{
+			protocol.GetServerStatusTopic("+"): {QoS: 0},
+		},
+	}
+
+	if ack, err := watcher.mqtt.Connect(ctx, connectPacket); ack != nil && ack.ReasonCode != 0 {
+		connection.Close()
+		return nil, getConnackError(ack)
+	} else if err != nil {
+		connection.Close()
+		return nil, err
+	} else if _, err := watcher.mqtt.Subscribe(ctx, subscribePacket); err != nil {
+		watcher.Close()
+		return nil, err
+	} else {
+		return watcher, nil
+	}
+}
+
+// Return a channel that is closed when the watcher stops, either
+// because it is closed or because the broker connection is lost.
+//
+func (watcher *Watcher) Done() <-chan struct{} {
+	return watcher.done
+}
+
+// Close the watcher and the connection to the broker. The function
+// waits for any running invocation of the handler to return, so the
+// handler is not running and will not be invoked after the function
+// returns. Closing the watcher more than once has no further effect.
+//
+func (watcher *Watcher) Close() error {
+	stopped := watcher.terminate()
+	watcher.handlers.Wait()
+
+	if stopped {
+		return watcher.mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
+	} else {
+		return nil
+	}
+}
+
+// Mark the watcher as stopped. Return true if the watcher is stopped
+// by this call, and false if it is already stopped.
+//
+func (watcher *Watcher) terminate() bool {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	if watcher.closed == false {
+		watcher.closed = true
+		close(watcher.done)
+		return true
+	} else {
+		return false
+	}
+}
+
+// Process a message received from the broker. Messages other than
+// status messages are ignored.
+//
+// The time of the last heartbeat is taken from the message itself,
+// or the current time for a fresh alive message from servers that
+// do not attach the time. Otherwise, the last known time is kept.
+//
+func (watcher *Watcher) receive(received *paho.Publish) {
+	if protocol.IsServerStatusTopic(received.Topic) == false {
+		return
+	}
+
+	name := strings.TrimPrefix(received.Topic, protocol.GetServerStatusTopic(""))
+	server := ServerInfo{Name: name}
+
+	if protocol.IsAliveStatusMessage(received.Payload) {
+		server.Alive = true
+	} else if protocol.IsDeadStatusMessage(received.Payload) == false {
+		return
+	}
+
+	watcher.mutex.Lock()
+
+	if watcher.closed {
+		watcher.mutex.Unlock()
+		return
+	}
+
+	if timestamp, found := getStatusTimestamp(received); found {
+		server.LastSeen = timestamp
+	} else if server.Alive && received.Retain == false {
+		server.LastSeen = time.Now()
+	} else {
+		server.LastSeen = watcher.seen[name]
+	}
+
+	watcher.seen[name] = server.LastSeen
+	watcher.handlers.Add(1)
+	watcher.mutex.Unlock()
+
+	defer watcher.handlers.Done()
+	watcher.handler(server)
+}
+
+// Discover the servers on the intermediate MQTT broker at the given
+// url. Every server publishes a retained alive or dead message to
+// its status topic, so the servers can be enumerated by watching
+// the status topics of all servers for a while; see the
+// 'DiscoveryWindow' option.
+//
+// The given context controls the whole discovery. If it is cancelled
+// or its deadline passes before the discovery completes, the function
+// gives up and returns the context error. The servers are returned in
+// the order of their names.
+//
+func Discover(ctx context.Context, url string, options *Options) ([]ServerInfo, error) {
+	if servers, watcher, err := DiscoverAndWatch(ctx, url, options, func(ServerInfo) {}); err != nil {
+		return nil, err
+	} else {
+		watcher.Close()
+		return servers, nil
+	}
+}
+
+// Discover the servers on the intermediate MQTT broker at the given
+// url like [Discover], and keep watching them afterwards like [Watch]
+// over the same connection. The handler is invoked for every status
+// message, including the ones received during the discovery. The
+// caller should close the returned watcher when it is done.
+//
+// The given context controls the connection phase and the discovery.
+// If it is cancelled or its deadline passes before the discovery
+// completes, the function closes the watcher and returns the context
+// error.
+//
+func DiscoverAndWatch(ctx context.Context, url string, options *Options, handler func(ServerInfo)) ([]ServerInfo, *Watcher, error) {
+	mutex := sync.Mutex{}
+	servers := make(map[string]ServerInfo)
+
+	watcher, err := Watch(ctx, url, options, func(server ServerInfo) {
+		mutex.Lock()
+
+		if servers != nil {
+			servers[server.Name] = server
+		}
+
+		mutex.Unlock()
+		handler(server)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timer := time.NewTimer(options.getDiscoveryWindow())
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		output := make([]ServerInfo, 0, len(servers))
+
+		for _, server := range servers {
+			output = append(output, server)
+		}
+
+		sort.Slice(output, func(i, j int) bool {
+			return output[i].Name < output[j].Name
+		})
+
+		servers = nil
+		return output, watcher, nil
+
+	case <-ctx.Done():
+		watcher.Close()
+		return nil, nil, ctx.Err()
+	}
+}
+
+// Return the time attached to the given status message as the user
+// property defined by [protocol.StatusTimeProperty].
+//
+func getStatusTimestamp(received *paho.Publish) (time.Time, bool) {
+	if received.Properties == nil {
+		return time.Time{}, false
+	} else if value := received.Properties.User.Get(protocol.StatusTimeProperty); value == "" {
+		return time.Time{}, false
+	} else if timestamp, err := time.Parse(time.RFC3339Nano, value); err != nil {
+		return time.Time{}, false
+	} else {
+		return timestamp, true
+	}
+}
@@ -0,0 +1,255 @@
+package codec_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/kmchan2018/mindctrl/client/broker"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"net"
+	"testing"
+	"time"
+)
+
+// Start a broker with the given options that accepts plain connections
+// on a random port of the loopback interface, and return its url. The
+// broker is closed when the test finishes.
+//
+func startBroker(t *testing.T, options *broker.Options) string {
+	t.Helper()
+
+	b := broker.NewBroker(options)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+
+	go b.ServeTcp(listener)
+	t.Cleanup(func() { b.Close() })
+	return fmt.Sprintf("mqtt://%s", listener.Addr())
+}
+
+// Publish the given retained status message for the given server to
+// the broker at the given url, as the server would do.
+//
+func publishStatus(t *testing.T, url string, server string, status string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := codec.Dial(ctx, url, nil)
+
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+
+	client := paho.NewClient(paho.ClientConfig{Conn: conn})
+	defer client.Disconnect(&paho.Disconnect{ReasonCode: 0})
+
+	if _, err := client.Connect(ctx, &paho.Connect{ClientID: protocol.GenerateMqttClientId("status"), KeepAlive: 1, CleanStart: true}); err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+
+	publish := &paho.Publish{
+		Topic:   protocol.GetServerStatusTopic(server),
+		Payload: []byte(status),
+		QoS:     1,
+		Retain:  true,
+	}
+
+	if _, err := client.Publish(ctx, publish); err != nil {
+		t.Fatalf("cannot publish status: %v", err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	url := startBroker(t, nil)
+	publishStatus(t, url, "beta", "dead")
+	publishStatus(t, url, "alpha", "alive")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	servers, err := codec.Discover(ctx, url, &codec.Options{DiscoveryWindow: 200 * time.Millisecond})
+
+	if err != nil {
+		t.Fatalf("cannot discover servers: %v", err)
+	} else if len(servers) != 2 {
+		t.Fatalf("%d servers discovered, expected 2", len(servers))
+	}
+
+	if servers[0].Name != "alpha" || servers[0].Alive == false {
+		t.Errorf("first server is %+v, expected alpha alive", servers[0])
+	}
+
+	if servers[1].Name != "beta" || servers[1].Alive {
+		t.Errorf("second server is %+v, expected beta dead", servers[1])
+	}
+}
+
+func TestDiscoverGivesUpWithContext(t *testing.T) {
+	url := startBroker(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := codec.Discover(ctx, url, &codec.Options{DiscoveryWindow: time.Minute}); err != context.DeadlineExceeded {
+		t.Errorf("discovery fails with %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestDiscoverAndWatch(t *testing.T) {
+	url := startBroker(t, nil)
+	publishStatus(t, url, "alpha", "alive")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan codec.ServerInfo, 10)
+	options := &codec.Options{DiscoveryWindow: 200 * time.Millisecond}
+	servers, watcher, err := codec.DiscoverAndWatch(ctx, url, options, func(server codec.ServerInfo) {
+		received <- server
+	})
+
+	if err != nil {
+		t.Fatalf("cannot discover servers: %v", err)
+	}
+
+	defer watcher.Close()
+
+	if len(servers) != 1 || servers[0].Name != "alpha" || servers[0].Alive == false {
+		t.Errorf("servers discovered are %+v, expected alpha alive", servers)
+	}
+
+	if server := <-received; server.Name != "alpha" || server.Alive == false {
+		t.Errorf("watcher reports %+v during discovery, expected alpha alive", server)
+	}
+
+	publishStatus(t, url, "alpha", "dead")
+
+	select {
+	case server := <-received:
+		if server.Name != "alpha" || server.Alive {
+			t.Errorf("watcher reports %+v after discovery, expected alpha dead", server)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watcher does not report the status change after discovery")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	url := startBroker(t, nil)
+	publishStatus(t, url, "alpha", "alive")
+	publishStatus(t, url, "beta", "dead")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan codec.ServerInfo, 10)
+	watcher, err := codec.Watch(ctx, url, nil, func(server codec.ServerInfo) {
+		received <- server
+	})
+
+	if err != nil {
+		t.Fatalf("cannot watch servers: %v", err)
+	}
+
+	defer watcher.Close()
+
+	// The retained statuses are reported first, followed by the
+	// status changes afterwards.
+
+	statuses := map[string]bool{}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case server := <-received:
+			statuses[server.Name] = server.Alive
+		case <-time.After(5 * time.Second):
+			t.Fatalf("watcher does not report the retained statuses")
+		}
+	}
+
+	if alive, found := statuses["alpha"]; found == false || alive == false {
+		t.Errorf("watcher does not report alpha alive")
+	}
+
+	if alive, found := statuses["beta"]; found == false || alive {
+		t.Errorf("watcher does not report beta dead")
+	}
+
+	publishStatus(t, url, "alpha", "dead")
+
+	select {
+	case server := <-received:
+		if server.Name != "alpha" || server.Alive {
+			t.Errorf("watcher reports %+v, expected alpha dead", server)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watcher does not report the status change")
+	}
+}
+
+func TestWatchRefused(t *testing.T) {
+	url := startBroker(t, &broker.Options{Username: "user", Password: "secret"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	options := &codec.Options{Username: "user", Password: "wrong"}
+
+	if watcher, err := codec.Watch(ctx, url, options, func(codec.ServerInfo) {}); err == nil {
+		watcher.Close()
+		t.Errorf("watcher connected with a wrong password")
+	} else if errors.Is(err, codec.ErrConnectionRefused) == false {
+		t.Errorf("watcher fails with %v, expected %v", err, codec.ErrConnectionRefused)
+	}
+}
+
+func TestWatcherCloseWaitsForHandler(t *testing.T) {
+	url := startBroker(t, nil)
+	publishStatus(t, url, "alpha", "alive")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	watcher, err := codec.Watch(ctx, url, nil, func(server codec.ServerInfo) {
+		close(entered)
+		<-release
+	})
+
+	if err != nil {
+		t.Fatalf("cannot watch servers: %v", err)
+	}
+
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handler not invoked")
+	}
+
+	closed := make(chan struct{})
+
+	go func() {
+		watcher.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Errorf("watcher closed while the handler is running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Errorf("watcher not closed after the handler returns")
+	}
+}
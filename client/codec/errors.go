@@ -2,9 +2,116 @@ package codec
 
 import (
 	"errors"
+	"net/rpc"
 )
 
 // Error reported by [Codec] to indicate that the server (not the
 // intermediate MQTT broker) is dead.
 //
 var ErrServerDead = errors.New("server dead")
+
+// Error reported by [Codec] to indicate that the url of the
+// intermediate MQTT broker is invalid or uses an unsupported
+// scheme.
+//
+var ErrInvalidUrl = errors.New("invalid broker url")
+
+// Error reported by [Codec] to indicate that the connection to the
+// intermediate MQTT broker is lost.
+//
+var ErrConnectionLost = errors.New("connection lost")
+
+// Error reported by [Codec] to indicate that the intermediate MQTT
+// broker refuses the connection, like when the credentials are
+// wrong. The actual error names the reason code from the broker.
+//
+var ErrConnectionRefused = errors.New("connection refused")
+
+// Error reported by [Codec] to indicate that a request is submitted
+// while the codec is reconnecting to the server.
+//
+var ErrReconnecting = errors.New("reconnecting")
+
+// Error reported by [Codec] to indicate that no response is received
+// for a call before the request timeout.
+//
+var ErrTimeout = errors.New("request timeout")
+
+// Error reported by [Codec] for a call abandoned by its caller with
+// [Codec.Abandon]. The caller has already moved on, so the error is
+// only seen by net/rpc, which forgets the call.
+//
+var ErrAbandoned = errors.New("call abandoned")
+
+// Error reported by [Codec] to indicate that the codec is closed.
+// Calls in flight when the codec is closed, as well as calls made
+// afterwards, fail with this error.
+//
+var ErrClosed = errors.New("codec closed")
+
+// Error reported by [Codec] to indicate that some chunks of a chunked
+// response did not arrive within the chunk timeout.
+//
+var ErrIncompleteResponse = errors.New("incomplete chunked response")
+
+// Error reported by [Codec] to indicate that a chunked response could
+// not be reassembled within the chunk memory limit.
+//
+var ErrResponseTooLarge = errors.New("chunked response too large")
+
+// Error returned when a codec is created with an Ed25519 signing key
+// that is not a valid private key, like a key of the wrong size.
+//
+var ErrInvalidSigningKey = errors.New("invalid signing key")
+
+// Error returned when a codec is created with an Ed25519 signing key
+// but without the public key of the server, so that messages from the
+// server could not be verified. See the 'SkipServerVerify'
+// option for accepting such messages unverified.
+//
+var ErrMissingServerKey = errors.New("server public key required")
+
+// Error returned when a codec is created with a capability token that
+// is not bound to the Ed25519 signing key in the options. Extensions
+// refuse such tokens, since they could be replayed by anyone who sees
+// them on the broker.
+//
+var ErrUnboundToken = errors.New("capability token not bound to the signing key")
+
+// Error reported by [ReplayCodec] to indicate that the cassette has
+// no recorded response for a call.
+//
+var ErrNotRecorded = errors.New("call not recorded in cassette")
+
+// List of errors that may be reported by [Codec] for individual
+// calls. Such errors reach the caller through net/rpc as instances
+// of [rpc.ServerError] that carry only the error message.
+//
+var callErrors = []error{
+	ErrServerDead,
+	ErrConnectionLost,
+	ErrReconnecting,
+	ErrTimeout,
+	ErrAbandoned,
+	ErrIncompleteResponse,
+	ErrResponseTooLarge,
+	ErrNotRecorded,
+}
+
+// Restore the original error reported by [Codec] for a failed call.
+// The function translates [rpc.ServerError] instances that carry
+// the message of a known codec error back to the error itself, so
+// that they can be checked with [errors.Is]. Other errors are
+// returned unchanged.
+//
+func RestoreError(err error) error {
+	if serverError, ok := err.(rpc.ServerError); ok {
+		for _, candidate := range callErrors {
+			if string(serverError) == candidate.Error() {
+				return candidate
+			}
+		}
+	}
+
+	return err
+}
@@ -1,5 +1,16 @@
 package codec
 
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"os"
+	"time"
+)
+
 // Options for creating a new codec.
 //
 // The 'Username' and 'Password' fields contains the credentials
@@ -15,12 +26,215 @@ package codec
 // be buffered for processing. The minimum size and the default
 // sizes are both 100.
 //
+// The 'Reconnect' field enables automatic reconnection when the
+// server dies or the connection to the broker is lost. By default
+// it is disabled, and the codec becomes unusable after either
+// event.
+//
+// The 'ReconnectAttempts' field contains the maximum number of
+// reconnection attempts before the codec gives up, in which case
+// the codec fails with the loss that triggers the reconnection,
+// like [ErrConnectionLost] or [ErrServerDead]. Non-positive values
+// mean the codec will retry forever, which is the default. Calls in
+// flight when the loss happens fail at once in either case.
+//
+// The 'ReconnectDelay' and 'ReconnectMaxDelay' fields control the
+// exponential backoff between reconnection attempts. The delay
+// starts at 'ReconnectDelay' and doubles after every attempt until
+// it reaches 'ReconnectMaxDelay'. The defaults are 1 second and 30
+// seconds respectively.
+//
+// The 'MqttQoS' field contains the MQTT QoS level used to publish
+// requests and subscribe to responses. It can be 0, 1 or 2, and the
+// default is 0.
+//
+// The 'MqttClientId' field contains the MQTT client ID used to
+// identify the codec with the broker. The ID stays the same when
+// the codec reconnects. By default, an unique ID is generated for
+// every codec.
+//
+// The 'MqttSessionExpiry' field enables persistent session. When it
+// is positive, the broker keeps the session of the codec for the
+// given duration after the connection is lost, so that responses
+// published in the meantime are delivered after reconnection. It
+// should be used together with QoS 1 or 2. The broker may grant a
+// shorter expiry in its reply, or none at all. When the broker keeps
+// no session, like the embedded broker of this module, the calls in
+// flight fail as soon as the connection is lost. By default,
+// persistent session is disabled.
+//
+// The 'MqttResponseTopic' field contains the topic where the server
+// should publish responses. It is sent with every request as the
+// MQTT v5 Response Topic property. Extensions that predate the
+// property always publish responses to the client topic, so the
+// codec subscribes to both topics. By default, the client topic is
+// used.
+//
+// The 'RequestTimeout' field contains the maximum duration to wait
+// for the response of a call. Calls without response after the
+// duration will fail with [ErrTimeout]. By default, calls never
+// time out.
+//
+// The 'HeartbeatInterval' field contains the interval at which the
+// server republishes its alive message. The default is 60 seconds,
+// which matches the extension.
+//
+// The 'HeartbeatMisses' field enables the heartbeat watchdog. When
+// it is positive, the server is considered dead after the given
+// number of heartbeat intervals pass without any alive message.
+// By default, the watchdog is disabled.
+//
+// The 'TlsCaFile' field contains the path to a PEM bundle of CA
+// certificates trusted when connecting to the broker over TLS. By
+// default, the system certificate pool is used.
+//
+// The 'TlsCertFile' and 'TlsKeyFile' fields contain the paths to
+// the PEM encoded client certificate and private key presented to
+// the broker. They must be specified together. By default, no
+// client certificate is presented.
+//
+// The 'TlsServerName' field overrides the server name used to
+// verify the broker certificate. By default, the host name in the
+// url is used.
+//
+// The 'TlsMinVersion' field contains the minimum TLS version
+// accepted, like [tls.VersionTLS12]. By default, the defaults of
+// crypto/tls apply.
+//
+// The 'TlsInsecure' field disables verification of the broker
+// certificate. It should only be used for testing.
+//
+// The 'RecordFile' field contains the path to a cassette where every
+// request/response pair is recorded as a line of JSON. The cassette
+// is truncated when the codec is created, unless the 'RecordAppend'
+// field is set. By default, nothing is recorded.
+//
+// The 'ReplayFile' field contains the path to a cassette recorded
+// earlier. When it is set, the transport serves the responses in
+// the cassette with [ReplayCodec] instead of connecting to the
+// broker. Calls are matched by method and normalized params. By
+// default, the recorded responses of a call are served in order
+// and the last one is repeated afterwards, and calls without an
+// exact match fall back to responses of the same method. When the
+// 'ReplayStrict' field is set, each recorded response is served at
+// most once and only exact matches count. Calls without a match
+// fail with [ErrNotRecorded].
+//
+// The 'DiscoveryWindow' field contains the duration [Discover]
+// collects status messages for. The default is 1 second.
+//
+// The 'Compression' field contains the encoding used to compress the
+// input of calls, either "gzip" or "deflate"; inputs smaller than the
+// 'CompressionThreshold' field (16 KiB by default) are sent as is.
+// Compressed inputs are only understood by recent extensions, so
+// input compression is disabled by default. Outputs, on the other
+// hand, are always accepted in both encodings and decompressed
+// transparently; extensions that do not compress send plain JSON.
+//
+// The 'ChunkSize' field contains the maximum size of the response
+// messages the codec asks the server for, which should be below the
+// message size limit of the broker. The size covers the whole MQTT
+// packet, including the signature and the headers. Larger responses
+// are split by the server into chunks and reassembled by the codec.
+// The default is 256 KiB, and a negative value asks for whole
+// responses; servers that predate chunking always send whole
+// responses. The 'ChunkTimeout' field contains the maximum duration to
+// wait for the remaining chunks after the first one arrives, 1 minute
+// by default, and the 'ChunkMemoryLimit' field the maximum total size
+// of the chunks being reassembled, 64 MiB by default. A call whose
+// chunks exceed either limit fails with [ErrIncompleteResponse] or
+// [ErrResponseTooLarge].
+//
+// The 'SigningSecret' field contains a secret shared with the server.
+// When it is set, every message to the server is signed with HMAC-SHA256,
+// and every message from the server, apart from status messages, must
+// carry a valid signature. Alternatively, the 'SigningKey' field
+// contains the Ed25519 private key of the client, which signs the
+// messages to the server, and the 'ServerPublicKey' field contains the
+// Ed25519 public key of the server, which verifies the messages from
+// the server. A codec with a signing key but no server public key
+// cannot be created and fails with [ErrMissingServerKey], unless the
+// 'SkipServerVerify' field is set to accept the messages from the
+// server unverified. The 'SignatureWindow' field contains the
+// maximum clock difference accepted for signed messages, 5 minutes by
+// default; messages outside the window and messages seen before are
+// dropped. By default, messages are neither signed nor verified.
+//
+// Status messages are never signed, since they are retained by the
+// broker and the dead message is published by the broker itself when
+// the server disconnects. Anyone who can publish to the status topic
+// of the server can therefore make the codec consider the server dead
+// or alive; restrict the status topics with the access control of the
+// broker if it matters.
+//
+// The 'Token' field contains the capability token of the client,
+// which is required by extensions configured to restrict the methods
+// each client may call; see [protocol.TokenClaims]. The token must be
+// bound to the public key of the 'SigningKey' field, or the codec
+// cannot be created and fails with [ErrUnboundToken]. By default, no
+// token is sent.
+//
+// The 'StrictProtocol' field makes the transport check the protocol
+// version of the server with info.get_capabilities after connecting,
+// and refuse servers that speak another version or do not support
+// the method at all. It has no effect when replaying a cassette.
+//
+// The 'OnReconnecting' and 'OnReconnected' fields contain optional
+// hooks invoked before every reconnection attempt and after the
+// codec is reconnected. They are invoked from the goroutine that
+// reads responses and therefore should return quickly.
+//
+// The 'OnDisconnect' field contains an optional hook invoked once
+// when the codec is terminated, either because it is closed or
+// because the server or the broker connection is lost for good.
+// The hook receives the error that terminated the codec, which is
+// [ErrClosed] if the codec is closed. It may be invoked from the
+// goroutine that reads responses or the goroutine that closes the
+// codec and therefore should return quickly.
+//
 type Options struct {
-	Username           string // username for the intermediate MQTT broker
-	Password           string // password for the intermediate MQTT broker
-	WebsocketFrameSize int64  // maximum size of websocket packet
-	MqttMessageBuffer  int    // number of MQTT messages buffered by the codec
-	MqttKeepAlive      int    // keepalive duration of MQTT connection
+	Username             string                         // username for the intermediate MQTT broker
+	Password             string                         // password for the intermediate MQTT broker
+	WebsocketFrameSize   int64                          // maximum size of websocket packet
+	MqttMessageBuffer    int                            // number of MQTT messages buffered by the codec
+	MqttKeepAlive        int                            // keepalive duration of MQTT connection
+	MqttQoS              int                            // QoS level of requests and responses
+	MqttClientId         string                         // client ID of the MQTT connection
+	MqttSessionExpiry    time.Duration                  // expiry of persistent session of MQTT connection
+	MqttResponseTopic    string                         // topic where the server publishes responses
+	RequestTimeout       time.Duration                  // maximum duration to wait for a response
+	HeartbeatInterval    time.Duration                  // interval of alive messages from the server
+	HeartbeatMisses      int                            // number of missed heartbeats before the server is considered dead
+	TlsCaFile            string                         // path to the CA bundle for verifying the broker
+	TlsCertFile          string                         // path to the client certificate
+	TlsKeyFile           string                         // path to the private key of the client certificate
+	TlsServerName        string                         // server name for verifying the broker
+	TlsMinVersion        uint16                         // minimum TLS version accepted
+	TlsInsecure          bool                           // whether to skip verification of the broker
+	Reconnect            bool                           // whether to reconnect automatically
+	ReconnectAttempts    int                            // maximum number of reconnection attempts
+	ReconnectDelay       time.Duration                  // initial delay between reconnection attempts
+	ReconnectMaxDelay    time.Duration                  // maximum delay between reconnection attempts
+	RecordFile           string                         // path to the cassette to record to
+	RecordAppend         bool                           // whether to append to the cassette instead of truncating it
+	ReplayFile           string                         // path to the cassette to replay from
+	ReplayStrict         bool                           // whether each recorded response is served once and exactly matched
+	DiscoveryWindow      time.Duration                  // duration to collect status messages for during discovery
+	StrictProtocol       bool                           // whether to refuse servers of other protocol versions
+	Compression          string                         // encoding used to compress the input of calls
+	CompressionThreshold int                            // minimum size of inputs to be compressed
+	ChunkSize            int                            // maximum size of response messages
+	ChunkTimeout         time.Duration                  // maximum duration to reassemble a chunked response
+	ChunkMemoryLimit     int                            // maximum total size of chunks being reassembled
+	SigningSecret        string                         // secret shared with the server for HMAC signatures
+	SigningKey           ed25519.PrivateKey             // private key of the client for Ed25519 signatures
+	ServerPublicKey      ed25519.PublicKey              // public key of the server for Ed25519 signatures
+	SkipServerVerify     bool                           // whether to accept messages from the server unverified
+	SignatureWindow      time.Duration                  // maximum clock difference of signed messages
+	Token                string                         // capability token of the client
+	OnReconnecting       func(attempt int, cause error) // hook invoked before every reconnection attempt
+	OnReconnected        func(attempt int)              // hook invoked after the codec is reconnected
+	OnDisconnect         func(err error)                // hook invoked after the codec is terminated
 }
 
 func (options *Options) getPahoUsernameFlag() bool {
@@ -96,3 +310,338 @@ func (options *Options) getMqttKeepAlive() uint16 {
 		return uint16(options.MqttKeepAlive)
 	}
 }
+
+func (options *Options) getMqttQoS() byte {
+	if options == nil {
+		return 0
+	} else if options.MqttQoS <= 0 {
+		return 0
+	} else if options.MqttQoS >= 2 {
+		return 2
+	} else {
+		return byte(options.MqttQoS)
+	}
+}
+
+func (options *Options) getMqttClientId(name string) string {
+	if options == nil {
+		return protocol.GenerateMqttClientId(name)
+	} else if options.MqttClientId == "" {
+		return protocol.GenerateMqttClientId(name)
+	} else {
+		return options.MqttClientId
+	}
+}
+
+func (options *Options) getMqttResponseTopic(name string) string {
+	if options == nil {
+		return protocol.GetClientTopic(name)
+	} else if options.MqttResponseTopic == "" {
+		return protocol.GetClientTopic(name)
+	} else {
+		return options.MqttResponseTopic
+	}
+}
+
+func (options *Options) getMqttSessionExpiry() uint32 {
+	if options == nil {
+		return 0
+	} else if options.MqttSessionExpiry <= 0 {
+		return 0
+	} else if seconds := options.MqttSessionExpiry / time.Second; seconds < 1 {
+		return 1
+	} else if seconds > 0xFFFFFFFE {
+		return 0xFFFFFFFE
+	} else {
+		return uint32(seconds)
+	}
+}
+
+func (options *Options) getRequestTimeout() time.Duration {
+	if options == nil {
+		return 0
+	} else if options.RequestTimeout < 0 {
+		return 0
+	} else {
+		return options.RequestTimeout
+	}
+}
+
+func (options *Options) getHeartbeatTolerance() time.Duration {
+	if options == nil {
+		return 0
+	} else if options.HeartbeatMisses <= 0 {
+		return 0
+	} else if options.HeartbeatInterval <= 0 {
+		return time.Duration(options.HeartbeatMisses) * 60 * time.Second
+	} else {
+		return time.Duration(options.HeartbeatMisses) * options.HeartbeatInterval
+	}
+}
+
+func (options *Options) hasTlsConfig() bool {
+	if options == nil {
+		return false
+	} else if options.TlsCaFile != "" || options.TlsCertFile != "" || options.TlsKeyFile != "" {
+		return true
+	} else if options.TlsServerName != "" || options.TlsMinVersion != 0 || options.TlsInsecure {
+		return true
+	} else {
+		return false
+	}
+}
+
+func (options *Options) getTlsConfig(hostname string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: hostname}
+
+	if options == nil {
+		return config, nil
+	}
+
+	if options.TlsServerName != "" {
+		config.ServerName = options.TlsServerName
+	}
+
+	if options.TlsMinVersion != 0 {
+		config.MinVersion = options.TlsMinVersion
+	}
+
+	if options.TlsInsecure {
+		config.InsecureSkipVerify = true
+	}
+
+	if options.TlsCaFile != "" {
+		if data, err := os.ReadFile(options.TlsCaFile); err != nil {
+			return nil, err
+		} else if pool := x509.NewCertPool(); pool.AppendCertsFromPEM(data) == false {
+			return nil, fmt.Errorf("no certificate found in ca file %s", options.TlsCaFile)
+		} else {
+			config.RootCAs = pool
+		}
+	}
+
+	if options.TlsCertFile != "" && options.TlsKeyFile != "" {
+		if certificate, err := tls.LoadX509KeyPair(options.TlsCertFile, options.TlsKeyFile); err != nil {
+			return nil, err
+		} else {
+			config.Certificates = []tls.Certificate{certificate}
+		}
+	} else if options.TlsCertFile != "" || options.TlsKeyFile != "" {
+		return nil, errors.New("client certificate and key must be specified together")
+	}
+
+	return config, nil
+}
+
+func (options *Options) getReconnect() bool {
+	if options == nil {
+		return false
+	} else {
+		return options.Reconnect
+	}
+}
+
+func (options *Options) getReconnectAttempts() int {
+	if options == nil {
+		return 0
+	} else if options.ReconnectAttempts < 0 {
+		return 0
+	} else {
+		return options.ReconnectAttempts
+	}
+}
+
+func (options *Options) getReconnectDelay(attempt int) time.Duration {
+	initial := 1 * time.Second
+	maximum := 30 * time.Second
+
+	if options != nil && options.ReconnectDelay > 0 {
+		initial = options.ReconnectDelay
+	}
+
+	if options != nil && options.ReconnectMaxDelay > 0 {
+		maximum = options.ReconnectMaxDelay
+	}
+
+	delay := initial
+
+	for i := 1; i < attempt && delay < maximum; i++ {
+		delay *= 2
+	}
+
+	if delay > maximum {
+		return maximum
+	} else {
+		return delay
+	}
+}
+
+func (options *Options) getRecordFile() string {
+	if options == nil {
+		return ""
+	} else {
+		return options.RecordFile
+	}
+}
+
+func (options *Options) getRecordAppend() bool {
+	if options == nil {
+		return false
+	} else {
+		return options.RecordAppend
+	}
+}
+
+func (options *Options) getReplayStrict() bool {
+	if options == nil {
+		return false
+	} else {
+		return options.ReplayStrict
+	}
+}
+
+func (options *Options) getDiscoveryWindow() time.Duration {
+	if options == nil {
+		return 1 * time.Second
+	} else if options.DiscoveryWindow <= 0 {
+		return 1 * time.Second
+	} else {
+		return options.DiscoveryWindow
+	}
+}
+
+func (options *Options) getCompression() string {
+	if options == nil {
+		return ""
+	} else if options.Compression == protocol.GzipEncoding || options.Compression == protocol.DeflateEncoding {
+		return options.Compression
+	} else {
+		return ""
+	}
+}
+
+func (options *Options) getCompressionThreshold() int {
+	if options == nil {
+		return 16 * 1024
+	} else if options.CompressionThreshold <= 0 {
+		return 16 * 1024
+	} else {
+		return options.CompressionThreshold
+	}
+}
+
+func (options *Options) getChunkSize() int {
+	if options == nil {
+		return 256 * 1024
+	} else if options.ChunkSize < 0 {
+		return 0
+	} else if options.ChunkSize == 0 {
+		return 256 * 1024
+	} else if options.ChunkSize < protocol.MinimumChunkSize {
+		return protocol.MinimumChunkSize
+	} else {
+		return options.ChunkSize
+	}
+}
+
+func (options *Options) getChunkTimeout() time.Duration {
+	if options == nil {
+		return time.Minute
+	} else if options.ChunkTimeout <= 0 {
+		return time.Minute
+	} else {
+		return options.ChunkTimeout
+	}
+}
+
+func (options *Options) getChunkMemoryLimit() int {
+	if options == nil {
+		return 64 * 1024 * 1024
+	} else if options.ChunkMemoryLimit <= 0 {
+		return 64 * 1024 * 1024
+	} else {
+		return options.ChunkMemoryLimit
+	}
+}
+
+func (options *Options) getSignatureAlgorithm() string {
+	if options == nil {
+		return ""
+	} else if len(options.SigningKey) > 0 {
+		return protocol.Ed25519Signature
+	} else if options.SigningSecret != "" {
+		return protocol.HmacSha256Signature
+	} else {
+		return ""
+	}
+}
+
+func (options *Options) getSigningKey() []byte {
+	if options == nil {
+		return nil
+	} else if len(options.SigningKey) > 0 {
+		return options.SigningKey
+	} else {
+		return []byte(options.SigningSecret)
+	}
+}
+
+func (options *Options) getVerifyingKey() []byte {
+	if options == nil {
+		return nil
+	} else if len(options.SigningKey) > 0 {
+		return options.ServerPublicKey
+	} else {
+		return []byte(options.SigningSecret)
+	}
+}
+
+func (options *Options) getSkipServerVerify() bool {
+	if options == nil {
+		return false
+	} else {
+		return options.SkipServerVerify
+	}
+}
+
+func (options *Options) getSignatureWindow() time.Duration {
+	if options == nil {
+		return 5 * time.Minute
+	} else if options.SignatureWindow <= 0 {
+		return 5 * time.Minute
+	} else {
+		return options.SignatureWindow
+	}
+}
+
+func (options *Options) getToken() string {
+	if options == nil {
+		return ""
+	} else {
+		return options.Token
+	}
+}
+
+func (options *Options) getOnReconnecting() func(int, error) {
+	if options == nil {
+		return nil
+	} else {
+		return options.OnReconnecting
+	}
+}
+
+func (options *Options) getOnReconnected() func(int) {
+	if options == nil {
+		return nil
+	} else {
+		return options.OnReconnected
+	}
+}
+
+func (options *Options) getOnDisconnect() func(error) {
+	if options == nil {
+		return nil
+	} else {
+		return options.OnDisconnect
+	}
+}
@@ -0,0 +1,100 @@
+package codec
+
+import (
+	"github.com/eclipse/paho.golang/paho"
+	"time"
+)
+
+// Handle the loss of the server or the broker connection. The
+// server is no longer considered alive until it is reconnected.
+//
+// If automatic reconnection is disabled, the codec is invalidated
+// and the cause is returned. In that case net/rpc will shut down
+// the client and fail every outstanding call with the cause.
+//
+// Otherwise, the calls in flight are marked as failed with the
+// cause and the function returns nil; the reader reports the failed
+// calls to net/rpc first, and then reconnects to the server with
+// [Codec.reconnect]. As an exception, if the broker keeps a
+// persistent session for the codec and only the broker connection is
+// lost, the calls in flight are kept, since their responses will be
+// delivered once the session is resumed.
+//
+// Note that any request submitted during reconnection will fail
+// with [ErrReconnecting].
+//
+func (codec *Codec) recover(cause error) error {
+	codec.mutex.Lock()
+	mqtt := codec.mqtt
+	codec.mqtt = nil
+	codec.status.Alive = false
+	codec.mutex.Unlock()
+
+	if cause != ErrConnectionLost || codec.session == false {
+		codec.failInflight(cause)
+	}
+
+	if mqtt != nil {
+		mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
+	}
+
+	if codec.options.getReconnect() == false {
+		codec.invalidate()
+		return cause
+	} else {
+		codec.outage = cause
+		return nil
+	}
+}
+
+// Connect to the server again with exponential backoff after the
+// loss recorded by [Codec.recover]. The function returns nil after
+// the codec is reconnected, the cause of the loss after all attempts
+// are exhausted, and [ErrClosed] if the codec is closed in between.
+//
+func (codec *Codec) reconnect() error {
+	outage := codec.outage
+	cause := outage
+	codec.outage = nil
+
+	for attempt := 1; ; attempt++ {
+		if hook := codec.options.getOnReconnecting(); hook != nil {
+			hook(attempt, cause)
+		}
+
+		timer := time.NewTimer(codec.options.getReconnectDelay(attempt))
+
+		select {
+		case <-timer.C:
+		case <-codec.ctx.Done():
+			timer.Stop()
+			return ErrClosed
+		}
+
+		if mqtt, err := codec.connect(codec.ctx, true); err == nil {
+			codec.mutex.Lock()
+
+			if codec.ctx.Err() != nil {
+				codec.mutex.Unlock()
+				mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
+				return ErrClosed
+			}
+
+			codec.mqtt = mqtt
+			codec.mutex.Unlock()
+
+			if hook := codec.options.getOnReconnected(); hook != nil {
+				hook(attempt)
+			}
+
+			return nil
+		} else if codec.ctx.Err() != nil {
+			return ErrClosed
+		} else if limit := codec.options.getReconnectAttempts(); limit > 0 && attempt >= limit {
+			codec.invalidate()
+			return outage
+		} else {
+			cause = err
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package codec
+
+import (
+	"encoding/json"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+// Implementation of net/rpc client codec that serves responses
+// recorded in a cassette instead of communicating with the server.
+// It allows code built on the mindctrl package to run offline, for
+// example in regression tests.
+//
+// Calls are matched with the recorded interactions by the method
+// and the normalized params; see [Options] for the matching rules.
+// The server is always considered alive.
+//
+type ReplayCodec struct {
+	options   *Options
+	strict    bool
+	exact     map[string][]Interaction
+	fallback  map[string][]Interaction
+	positions map[string]int
+	queue     []replayed
+	result    json.RawMessage
+	mutex     sync.Mutex
+	wakeup    chan struct{}
+	status    Status
+	done      chan struct{}
+	err       error
+}
+
+// Replayed is a response waiting to be read by net/rpc. The error
+// is set if the call cannot be matched.
+//
+type replayed struct {
+	seq    uint64
+	method string
+	result json.RawMessage
+	err    error
+}
+
+// Create a new net/rpc client codec that serves the responses in
+// the cassette at the given path.
+//
+func NewReplayCodec(path string, options *Options) (*ReplayCodec, error) {
+	if interactions, err := ReadCassette(path); err != nil {
+		return nil, err
+	} else {
+		codec := &ReplayCodec{
+			options:   options,
+			strict:    options.getReplayStrict(),
+			exact:     make(map[string][]Interaction),
+			fallback:  make(map[string][]Interaction),
+			positions: make(map[string]int),
+			queue:     make([]replayed, 0),
+			wakeup:    make(chan struct{}, 1),
+			status:    Status{Alive: true, LastSeen: time.Now()},
+			done:      make(chan struct{}),
+		}
+
+		for _, interaction := range interactions {
+			key := interaction.Key()
+			codec.exact[key] = append(codec.exact[key], interaction)
+			codec.fallback[interaction.Method] = append(codec.fallback[interaction.Method], interaction)
+		}
+
+		return codec, nil
+	}
+}
+
+// Look up the recorded response of the given request and queue it
+// for [ReplayCodec.ReadResponseHeader]. Calls without a recorded
+// response are queued as failed with the error [ErrNotRecorded].
+//
+// If the codec is closed, the function will return the error
+// [ErrClosed] directly.
+//
+func (codec *ReplayCodec) WriteRequest(request *rpc.Request, input interface{}) error {
+	params, err := json.Marshal(input)
+
+	if err != nil {
+		return err
+	}
+
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	if codec.err != nil {
+		return ErrClosed
+	}
+
+	key := (&Interaction{Method: request.ServiceMethod, Params: params}).Key()
+	entry := replayed{seq: request.Seq, method: request.ServiceMethod}
+
+	if interaction, found := codec.lookup(key, codec.exact[key]); found {
+		entry.result = interaction.Result
+	} else if codec.strict {
+		entry.err = ErrNotRecorded
+	} else if interaction, found := codec.lookup(request.ServiceMethod, codec.fallback[request.ServiceMethod]); found {
+		entry.result = interaction.Result
+	} else {
+		entry.err = ErrNotRecorded
+	}
+
+	codec.queue = append(codec.queue, entry)
+
+	select {
+	case codec.wakeup <- struct{}{}:
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Pick the next interaction from the given candidates. In strict
+// mode each candidate is picked once; otherwise the last candidate
+// is picked repeatedly after the others are exhausted.
+//
+func (codec *ReplayCodec) lookup(key string, candidates []Interaction) (Interaction, bool) {
+	position := codec.positions[key]
+
+	if len(candidates) == 0 {
+		return Interaction{}, false
+	} else if position < len(candidates) {
+		codec.positions[key] = position + 1
+		return candidates[position], true
+	} else if codec.strict {
+		return Interaction{}, false
+	} else {
+		return candidates[len(candidates)-1], true
+	}
+}
+
+// Return the next queued response. The function blocks until a
+// response is queued or the codec is closed, in which case the
+// error [ErrClosed] is returned.
+//
+func (codec *ReplayCodec) ReadResponseHeader(response *rpc.Response) error {
+	for {
+		codec.mutex.Lock()
+
+		if len(codec.queue) > 0 {
+			entry := codec.queue[0]
+			codec.queue = codec.queue[1:]
+			codec.result = entry.result
+			codec.mutex.Unlock()
+
+			response.ServiceMethod = entry.method
+			response.Seq = entry.seq
+
+			if entry.err != nil {
+				response.Error = entry.err.Error()
+			}
+
+			return nil
+		}
+
+		codec.mutex.Unlock()
+
+		select {
+		case <-codec.wakeup:
+			continue
+		case <-codec.done:
+			return ErrClosed
+		}
+	}
+}
+
+// Decode the result from the previous response to the given output
+// object.
+//
+func (codec *ReplayCodec) ReadResponseBody(output interface{}) error {
+	if output == nil {
+		return nil
+	} else {
+		return json.Unmarshal(codec.result, output)
+	}
+}
+
+// Close the codec. Calls in flight and calls made afterwards fail
+// with the error [ErrClosed]. Closing the codec more than once has
+// no further effect.
+//
+func (codec *ReplayCodec) Close() error {
+	codec.mutex.Lock()
+
+	if codec.err != nil {
+		codec.mutex.Unlock()
+		return nil
+	}
+
+	codec.err = ErrClosed
+	codec.status.Alive = false
+	close(codec.done)
+	codec.mutex.Unlock()
+
+	if hook := codec.options.getOnDisconnect(); hook != nil {
+		hook(ErrClosed)
+	}
+
+	return nil
+}
+
+// Return a channel that is closed when the codec is closed.
+//
+func (codec *ReplayCodec) Done() <-chan struct{} {
+	return codec.done
+}
+
+// Return [ErrClosed] if the codec is closed, or nil otherwise.
+//
+func (codec *ReplayCodec) Err() error {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+	return codec.err
+}
+
+// Return the status of the server, which is always alive until the
+// codec is closed.
+//
+func (codec *ReplayCodec) Status() Status {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+	return codec.status
+}
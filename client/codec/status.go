@@ -0,0 +1,71 @@
+package codec
+
+import (
+	"time"
+)
+
+// Status of the server as observed by the codec.
+//
+// The server publishes an alive message to its status topic when
+// it starts, and republishes it periodically as a heartbeat. The
+// codec records the time when the last alive message is received,
+// and considers the server alive until it receives a dead message
+// or the heartbeat stops.
+//
+type Status struct {
+	Alive    bool      // whether the server is considered alive
+	LastSeen time.Time // time when the last alive message is received
+}
+
+// Return the status of the server.
+//
+func (codec *Codec) Status() Status {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+	return codec.status
+}
+
+// Record an alive message from the server.
+//
+func (codec *Codec) beat() {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+	codec.status.Alive = true
+	codec.status.LastSeen = time.Now()
+}
+
+// Start a timer for the moment the server should be considered dead
+// if no more alive message is received. The function returns a
+// function that stops the timer and the channel of the timer. The
+// channel is nil if the heartbeat watchdog is disabled or the server
+// is not alive.
+//
+func (codec *Codec) watchHeartbeat() (func(), <-chan time.Time) {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	if tolerance := codec.options.getHeartbeatTolerance(); tolerance == 0 {
+		return func() {}, nil
+	} else if codec.status.Alive == false {
+		return func() {}, nil
+	} else {
+		timer := time.NewTimer(time.Until(codec.status.LastSeen.Add(tolerance)))
+		return func() { timer.Stop() }, timer.C
+	}
+}
+
+// Return if the heartbeat of the server has stopped, meaning that no
+// alive message is received within the tolerance.
+//
+func (codec *Codec) isHeartbeatStopped(now time.Time) bool {
+	codec.mutex.Lock()
+	defer codec.mutex.Unlock()
+
+	if tolerance := codec.options.getHeartbeatTolerance(); tolerance == 0 {
+		return false
+	} else if codec.status.Alive == false {
+		return false
+	} else {
+		return now.Before(codec.status.LastSeen.Add(tolerance)) == false
+	}
+}
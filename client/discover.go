@@ -0,0 +1,28 @@
+package mindctrl
+
+import (
+	"context"
+	"github.com/kmchan2018/mindctrl/client/codec"
+)
+
+// ServerInfo contains the name and the status of a server found on
+// the intermediate MQTT broker.
+//
+type ServerInfo = codec.ServerInfo
+
+// List the servers on the intermediate MQTT broker at the given url,
+// together with their status. Both live and dead servers are listed,
+// in the order of their names.
+//
+func DiscoverServers(url string, options *Options) ([]ServerInfo, error) {
+	return DiscoverServersContext(context.Background(), url, options)
+}
+
+// List the servers on the intermediate MQTT broker like
+// [DiscoverServers]. If the given context is cancelled or its
+// deadline passes before the discovery completes, the function
+// gives up and returns the context error.
+//
+func DiscoverServersContext(ctx context.Context, url string, options *Options) ([]ServerInfo, error) {
+	return codec.Discover(ctx, url, options)
+}
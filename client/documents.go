@@ -1,7 +1,7 @@
 package mindctrl
 
 import (
-	"errors"
+	"context"
 	"github.com/kmchan2018/mindctrl/client/protocol"
 )
 
@@ -124,25 +124,45 @@ func (op *QueryDocumentOperation) ClearVariables() *QueryDocumentOperation {
 	return op
 }
 
-func (op *QueryDocumentOperation) Start(transport *Transport, callback func(op *QueryDocumentOperation)) {
-	op.doStart(transport, protocol.QueryDocumentMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *QueryDocumentOperation) Start(transport Executor, callback func(op *QueryDocumentOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *QueryDocumentOperation) StartContext(ctx context.Context, transport Executor, callback func(op *QueryDocumentOperation)) {
+	op.doStart(ctx, transport, protocol.QueryDocumentMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *QueryDocumentOperation) StartChannel(transport *Transport, channel chan *QueryDocumentOperation) {
-	op.doStart(transport, protocol.QueryDocumentMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *QueryDocumentOperation) StartChannel(transport Executor, channel chan *QueryDocumentOperation) {
+	op.doStart(context.Background(), transport, protocol.QueryDocumentMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *QueryDocumentOperation) Execute(transport *Transport) error {
-	if err := op.doExecute(transport, protocol.QueryDocumentMethod, &op.input, &op.output); err != nil {
+func (op *QueryDocumentOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *QueryDocumentOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.QueryDocumentMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *QueryDocumentOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.QueryDocumentMethod, &op.input, &op.output)
+}
+
+func (op *QueryDocumentOperation) Execute(transport Executor) error {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *QueryDocumentOperation) ExecuteContext(ctx context.Context, transport Executor) error {
+	if err := op.doExecute(ctx, transport, protocol.QueryDocumentMethod, &op.input, &op.output); err != nil {
 		return err
 	} else if op.output.Success == false {
-		return errors.New(op.output.Message)
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
@@ -151,8 +171,10 @@ func (op *QueryDocumentOperation) Execute(transport *Transport) error {
 func (op *QueryDocumentOperation) Result() error {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return err
+	} else if op.output.Success == false {
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
@@ -1,7 +1,7 @@
 package mindctrl
 
 import (
-	"errors"
+	"context"
 	"github.com/kmchan2018/mindctrl/client/protocol"
 )
 
@@ -63,25 +63,45 @@ func (op *FindDownloadsOperation) SetState(specified bool, state string) *FindDo
 	}
 }
 
-func (op *FindDownloadsOperation) Start(transport *Transport, callback func(op *FindDownloadsOperation)) {
-	op.doStart(transport, protocol.FindDownloadsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FindDownloadsOperation) Start(transport Executor, callback func(op *FindDownloadsOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *FindDownloadsOperation) StartContext(ctx context.Context, transport Executor, callback func(op *FindDownloadsOperation)) {
+	op.doStart(ctx, transport, protocol.FindDownloadsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *FindDownloadsOperation) StartChannel(transport *Transport, channel chan *FindDownloadsOperation) {
-	op.doStart(transport, protocol.FindDownloadsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FindDownloadsOperation) StartChannel(transport Executor, channel chan *FindDownloadsOperation) {
+	op.doStart(context.Background(), transport, protocol.FindDownloadsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *FindDownloadsOperation) Execute(transport *Transport) ([]protocol.Download, error) {
-	if err := op.doExecute(transport, protocol.FindDownloadsMethod, &op.input, &op.output); err != nil {
+func (op *FindDownloadsOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *FindDownloadsOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.FindDownloadsMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *FindDownloadsOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.FindDownloadsMethod, &op.input, &op.output)
+}
+
+func (op *FindDownloadsOperation) Execute(transport Executor) ([]protocol.Download, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *FindDownloadsOperation) ExecuteContext(ctx context.Context, transport Executor) ([]protocol.Download, error) {
+	if err := op.doExecute(ctx, transport, protocol.FindDownloadsMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return op.output.Result, nil
 	}
@@ -90,8 +110,10 @@ func (op *FindDownloadsOperation) Execute(transport *Transport) ([]protocol.Down
 func (op *FindDownloadsOperation) Result() ([]protocol.Download, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return op.output.Result, nil
 	}
@@ -122,25 +144,45 @@ func (op *GetDownloadOperation) SetDownloadId(downloadId int) *GetDownloadOperat
 	return op
 }
 
-func (op *GetDownloadOperation) Start(transport *Transport, callback func(op *GetDownloadOperation)) {
-	op.doStart(transport, protocol.GetDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetDownloadOperation) Start(transport Executor, callback func(op *GetDownloadOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *GetDownloadOperation) StartContext(ctx context.Context, transport Executor, callback func(op *GetDownloadOperation)) {
+	op.doStart(ctx, transport, protocol.GetDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *GetDownloadOperation) StartChannel(transport *Transport, channel chan *GetDownloadOperation) {
-	op.doStart(transport, protocol.GetDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetDownloadOperation) StartChannel(transport Executor, channel chan *GetDownloadOperation) {
+	op.doStart(context.Background(), transport, protocol.GetDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *GetDownloadOperation) Execute(transport *Transport) (*protocol.Download, error) {
-	if err := op.doExecute(transport, protocol.GetDownloadMethod, &op.input, &op.output); err != nil {
+func (op *GetDownloadOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *GetDownloadOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.GetDownloadMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *GetDownloadOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.GetDownloadMethod, &op.input, &op.output)
+}
+
+func (op *GetDownloadOperation) Execute(transport Executor) (*protocol.Download, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *GetDownloadOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Download, error) {
+	if err := op.doExecute(ctx, transport, protocol.GetDownloadMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -149,8 +191,10 @@ func (op *GetDownloadOperation) Execute(transport *Transport) (*protocol.Downloa
 func (op *GetDownloadOperation) Result() (*protocol.Download, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -236,25 +280,45 @@ func (op *CreateDownloadOperation) SetNoWait(specified bool, noWait bool) *Creat
 	}
 }
 
-func (op *CreateDownloadOperation) Start(transport *Transport, callback func(op *CreateDownloadOperation)) {
-	op.doStart(transport, protocol.CreateDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *CreateDownloadOperation) Start(transport Executor, callback func(op *CreateDownloadOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *CreateDownloadOperation) StartContext(ctx context.Context, transport Executor, callback func(op *CreateDownloadOperation)) {
+	op.doStart(ctx, transport, protocol.CreateDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *CreateDownloadOperation) StartChannel(transport *Transport, channel chan *CreateDownloadOperation) {
-	op.doStart(transport, protocol.CreateDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *CreateDownloadOperation) StartChannel(transport Executor, channel chan *CreateDownloadOperation) {
+	op.doStart(context.Background(), transport, protocol.CreateDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *CreateDownloadOperation) Execute(transport *Transport) (*protocol.Download, error) {
-	if err := op.doExecute(transport, protocol.CreateDownloadMethod, &op.input, &op.output); err != nil {
+func (op *CreateDownloadOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *CreateDownloadOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.CreateDownloadMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *CreateDownloadOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.CreateDownloadMethod, &op.input, &op.output)
+}
+
+func (op *CreateDownloadOperation) Execute(transport Executor) (*protocol.Download, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *CreateDownloadOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Download, error) {
+	if err := op.doExecute(ctx, transport, protocol.CreateDownloadMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -263,8 +327,10 @@ func (op *CreateDownloadOperation) Execute(transport *Transport) (*protocol.Down
 func (op *CreateDownloadOperation) Result() (*protocol.Download, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -295,25 +361,45 @@ func (op *PauseDownloadOperation) SetDownloadId(downloadId int) *PauseDownloadOp
 	return op
 }
 
-func (op *PauseDownloadOperation) Start(transport *Transport, callback func(op *PauseDownloadOperation)) {
-	op.doStart(transport, protocol.PauseDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *PauseDownloadOperation) Start(transport Executor, callback func(op *PauseDownloadOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *PauseDownloadOperation) StartContext(ctx context.Context, transport Executor, callback func(op *PauseDownloadOperation)) {
+	op.doStart(ctx, transport, protocol.PauseDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *PauseDownloadOperation) StartChannel(transport *Transport, channel chan *PauseDownloadOperation) {
-	op.doStart(transport, protocol.PauseDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *PauseDownloadOperation) StartChannel(transport Executor, channel chan *PauseDownloadOperation) {
+	op.doStart(context.Background(), transport, protocol.PauseDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *PauseDownloadOperation) Execute(transport *Transport) (*protocol.Download, error) {
-	if err := op.doExecute(transport, protocol.PauseDownloadMethod, &op.input, &op.output); err != nil {
+func (op *PauseDownloadOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *PauseDownloadOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.PauseDownloadMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *PauseDownloadOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.PauseDownloadMethod, &op.input, &op.output)
+}
+
+func (op *PauseDownloadOperation) Execute(transport Executor) (*protocol.Download, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *PauseDownloadOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Download, error) {
+	if err := op.doExecute(ctx, transport, protocol.PauseDownloadMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -322,8 +408,10 @@ func (op *PauseDownloadOperation) Execute(transport *Transport) (*protocol.Downl
 func (op *PauseDownloadOperation) Result() (*protocol.Download, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -376,25 +464,45 @@ func (op *ResumeDownloadOperation) SetNoWait(specified bool, noWait bool) *Resum
 	}
 }
 
-func (op *ResumeDownloadOperation) Start(transport *Transport, callback func(op *ResumeDownloadOperation)) {
-	op.doStart(transport, protocol.ResumeDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *ResumeDownloadOperation) Start(transport Executor, callback func(op *ResumeDownloadOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *ResumeDownloadOperation) StartContext(ctx context.Context, transport Executor, callback func(op *ResumeDownloadOperation)) {
+	op.doStart(ctx, transport, protocol.ResumeDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *ResumeDownloadOperation) StartChannel(transport *Transport, channel chan *ResumeDownloadOperation) {
-	op.doStart(transport, protocol.ResumeDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *ResumeDownloadOperation) StartChannel(transport Executor, channel chan *ResumeDownloadOperation) {
+	op.doStart(context.Background(), transport, protocol.ResumeDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *ResumeDownloadOperation) Execute(transport *Transport) (*protocol.Download, error) {
-	if err := op.doExecute(transport, protocol.ResumeDownloadMethod, &op.input, &op.output); err != nil {
+func (op *ResumeDownloadOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *ResumeDownloadOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.ResumeDownloadMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *ResumeDownloadOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.ResumeDownloadMethod, &op.input, &op.output)
+}
+
+func (op *ResumeDownloadOperation) Execute(transport Executor) (*protocol.Download, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *ResumeDownloadOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Download, error) {
+	if err := op.doExecute(ctx, transport, protocol.ResumeDownloadMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -403,8 +511,10 @@ func (op *ResumeDownloadOperation) Execute(transport *Transport) (*protocol.Down
 func (op *ResumeDownloadOperation) Result() (*protocol.Download, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -435,25 +545,45 @@ func (op *CancelDownloadOperation) SetDownloadId(downloadId int) *CancelDownload
 	return op
 }
 
-func (op *CancelDownloadOperation) Start(transport *Transport, callback func(op *CancelDownloadOperation)) {
-	op.doStart(transport, protocol.CancelDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *CancelDownloadOperation) Start(transport Executor, callback func(op *CancelDownloadOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *CancelDownloadOperation) StartContext(ctx context.Context, transport Executor, callback func(op *CancelDownloadOperation)) {
+	op.doStart(ctx, transport, protocol.CancelDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *CancelDownloadOperation) StartChannel(transport *Transport, channel chan *CancelDownloadOperation) {
-	op.doStart(transport, protocol.CancelDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *CancelDownloadOperation) StartChannel(transport Executor, channel chan *CancelDownloadOperation) {
+	op.doStart(context.Background(), transport, protocol.CancelDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *CancelDownloadOperation) Execute(transport *Transport) (*protocol.Download, error) {
-	if err := op.doExecute(transport, protocol.CancelDownloadMethod, &op.input, &op.output); err != nil {
+func (op *CancelDownloadOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *CancelDownloadOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.CancelDownloadMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *CancelDownloadOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.CancelDownloadMethod, &op.input, &op.output)
+}
+
+func (op *CancelDownloadOperation) Execute(transport Executor) (*protocol.Download, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *CancelDownloadOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Download, error) {
+	if err := op.doExecute(ctx, transport, protocol.CancelDownloadMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -462,8 +592,10 @@ func (op *CancelDownloadOperation) Execute(transport *Transport) (*protocol.Down
 func (op *CancelDownloadOperation) Result() (*protocol.Download, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -494,25 +626,45 @@ func (op *RemoveDownloadOperation) SetDownloadId(downloadId int) *RemoveDownload
 	return op
 }
 
-func (op *RemoveDownloadOperation) Start(transport *Transport, callback func(op *RemoveDownloadOperation)) {
-	op.doStart(transport, protocol.RemoveDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *RemoveDownloadOperation) Start(transport Executor, callback func(op *RemoveDownloadOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *RemoveDownloadOperation) StartContext(ctx context.Context, transport Executor, callback func(op *RemoveDownloadOperation)) {
+	op.doStart(ctx, transport, protocol.RemoveDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *RemoveDownloadOperation) StartChannel(transport *Transport, channel chan *RemoveDownloadOperation) {
-	op.doStart(transport, protocol.RemoveDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *RemoveDownloadOperation) StartChannel(transport Executor, channel chan *RemoveDownloadOperation) {
+	op.doStart(context.Background(), transport, protocol.RemoveDownloadMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *RemoveDownloadOperation) Execute(transport *Transport) error {
-	if err := op.doExecute(transport, protocol.RemoveDownloadMethod, &op.input, &op.output); err != nil {
+func (op *RemoveDownloadOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *RemoveDownloadOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.RemoveDownloadMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *RemoveDownloadOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.RemoveDownloadMethod, &op.input, &op.output)
+}
+
+func (op *RemoveDownloadOperation) Execute(transport Executor) error {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *RemoveDownloadOperation) ExecuteContext(ctx context.Context, transport Executor) error {
+	if err := op.doExecute(ctx, transport, protocol.RemoveDownloadMethod, &op.input, &op.output); err != nil {
 		return err
 	} else if op.output.Success == false {
-		return errors.New(op.output.Message)
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
@@ -521,8 +673,10 @@ func (op *RemoveDownloadOperation) Execute(transport *Transport) error {
 func (op *RemoveDownloadOperation) Result() error {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return err
+	} else if op.output.Success == false {
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
@@ -0,0 +1,126 @@
+package mindctrl
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+)
+
+// Errors reported by operations when the server fails the call. The
+// actual error is a [*RemoteError] carrying the error category from
+// the server, or a [*ForbiddenError] or [*PolicyViolationError]
+// wrapping one, which can be checked against these errors with
+// [errors.Is] according to the category:
+//
+//   - ErrUnknownMethod for the "dispatch" category, when the server
+//     does not support the method
+//   - ErrInvalidInput for the "validation" category, when the input of
+//     the call is not acceptable
+//   - ErrInternal for the "internal" category, when the extension fails
+//     unexpectedly
+//   - ErrExecution for the "execution" category, when the browser fails
+//     the call
+//   - ErrForbidden for the "forbidden" category, when the capability
+//     token of the client does not permit the method; see the 'Token'
+//     option
+//   - ErrPolicyViolation for the "policy" category, when the call
+//     violates the sandbox policy of the server, like loading a page
+//     or downloading to a directory not permitted
+//
+// Execution errors are often transient, like a page that fails to
+// load, and may be worth a retry; the other errors will recur if the
+// call is repeated as is.
+//
+var (
+	ErrUnknownMethod   = errors.New("unknown method")
+	ErrInvalidInput    = errors.New("invalid input")
+	ErrInternal        = errors.New("internal error")
+	ErrExecution       = errors.New("execution error")
+	ErrForbidden       = errors.New("forbidden")
+	ErrPolicyViolation = errors.New("policy violation")
+)
+
+// Error categories reported by the server and the errors they match.
+//
+var categoryErrors = map[string]error{
+	"dispatch":   ErrUnknownMethod,
+	"validation": ErrInvalidInput,
+	"internal":   ErrInternal,
+	"execution":  ErrExecution,
+	"forbidden":  ErrForbidden,
+	"policy":     ErrPolicyViolation,
+}
+
+// RemoteError is reported by operations when the server fails the call.
+// It carries the method of the call together with the error category
+// and the message from the server. Errors of unknown categories match
+// none of the errors above. Calls refused with the "forbidden" and the
+// "policy" categories are reported as [*ForbiddenError] and
+// [*PolicyViolationError] instead, which wrap the remote error and can
+// be unwrapped to it with [errors.As].
+//
+type RemoteError struct {
+	Method   string // method of the call
+	Category string // error category from the server
+	Message  string // explanation from the server
+}
+
+func (err *RemoteError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Method, err.Message)
+}
+
+func (err *RemoteError) Is(target error) bool {
+	if expected, found := categoryErrors[err.Category]; found {
+		return target == expected
+	} else {
+		return false
+	}
+}
+
+// ForbiddenError is reported by operations when the server refuses
+// the call with the "forbidden" error category, either because the
+// capability token of the client is missing or invalid, or because
+// the token does not permit the method.
+//
+type ForbiddenError struct {
+	RemoteError
+}
+
+func (err *ForbiddenError) Error() string {
+	return fmt.Sprintf("%s forbidden: %s", err.Method, err.Message)
+}
+
+func (err *ForbiddenError) Unwrap() error {
+	return &err.RemoteError
+}
+
+// PolicyViolationError is reported by operations when the server
+// refuses the call with the "policy" error category. The policy of
+// the server can be inspected with [GetPolicyOperation].
+//
+type PolicyViolationError struct {
+	RemoteError
+}
+
+func (err *PolicyViolationError) Error() string {
+	return fmt.Sprintf("%s violates policy: %s", err.Method, err.Message)
+}
+
+func (err *PolicyViolationError) Unwrap() error {
+	return &err.RemoteError
+}
+
+// Return the error for the given output of a failed call of the given
+// method.
+//
+func getOutputError(method string, output *protocol.GenericOutput) error {
+	remote := RemoteError{Method: method, Category: output.Category, Message: output.Message}
+
+	if output.Category == "forbidden" {
+		return &ForbiddenError{RemoteError: remote}
+	} else if output.Category == "policy" {
+		return &PolicyViolationError{RemoteError: remote}
+	} else {
+		return &remote
+	}
+}
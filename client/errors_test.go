@@ -0,0 +1,84 @@
+package mindctrl_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"testing"
+)
+
+// Fail the tabs.load method of the given server with the given error
+// category and message, and return the error reported for a call.
+//
+func failLoad(t *testing.T, server *mindctrltest.Server, transport *mindctrl.Transport, category string, message string) error {
+	t.Helper()
+
+	server.Handle(protocol.LoadTabMethod, func(input json.RawMessage) interface{} {
+		return mindctrltest.Failure(category, message)
+	})
+
+	if _, err := mindctrl.LoadTab(1, "https://example.com/").Execute(transport); err == nil {
+		t.Fatalf("load succeeds, expected a %s error", category)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func TestRemoteError(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	err := failLoad(t, server, transport, "execution", "network error")
+	remote := (*mindctrl.RemoteError)(nil)
+
+	if errors.As(err, &remote) == false {
+		t.Fatalf("load fails with %T, expected %T", err, remote)
+	} else if remote.Method != protocol.LoadTabMethod || remote.Category != "execution" || remote.Message != "network error" {
+		t.Errorf("load fails with %+v, expected the method, category and message of the call", remote)
+	}
+
+	if errors.Is(err, mindctrl.ErrExecution) == false || errors.Is(err, mindctrl.ErrInvalidInput) {
+		t.Errorf("load error %v matches the wrong errors", err)
+	} else if err.Error() != "tabs.load: network error" {
+		t.Errorf("load error reads %q, expected %q", err.Error(), "tabs.load: network error")
+	}
+}
+
+func TestForbiddenError(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	err := failLoad(t, server, transport, "forbidden", "not permitted")
+	forbidden := (*mindctrl.ForbiddenError)(nil)
+	remote := (*mindctrl.RemoteError)(nil)
+
+	if errors.As(err, &forbidden) == false {
+		t.Fatalf("load fails with %T, expected %T", err, forbidden)
+	} else if errors.As(err, &remote) == false || remote.Category != "forbidden" {
+		t.Errorf("load error %v does not wrap the remote error", err)
+	}
+
+	if errors.Is(err, mindctrl.ErrForbidden) == false || errors.Is(err, mindctrl.ErrExecution) {
+		t.Errorf("load error %v matches the wrong errors", err)
+	} else if err.Error() != "tabs.load forbidden: not permitted" {
+		t.Errorf("load error reads %q, expected %q", err.Error(), "tabs.load forbidden: not permitted")
+	}
+}
+
+func TestPolicyViolationError(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	err := failLoad(t, server, transport, "policy", "url not permitted")
+	violation := (*mindctrl.PolicyViolationError)(nil)
+	remote := (*mindctrl.RemoteError)(nil)
+
+	if errors.As(err, &violation) == false {
+		t.Fatalf("load fails with %T, expected %T", err, violation)
+	} else if errors.As(err, &remote) == false || remote.Category != "policy" {
+		t.Errorf("load error %v does not wrap the remote error", err)
+	}
+
+	if errors.Is(err, mindctrl.ErrPolicyViolation) == false || errors.Is(err, mindctrl.ErrForbidden) {
+		t.Errorf("load error %v matches the wrong errors", err)
+	} else if err.Error() != "tabs.load violates policy: url not permitted" {
+		t.Errorf("load error reads %q, expected %q", err.Error(), "tabs.load violates policy: url not permitted")
+	}
+}
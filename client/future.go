@@ -0,0 +1,129 @@
+package mindctrl
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// Error reported by [WaitAny] to indicate that no future is given, so
+// there is nothing to wait for.
+//
+var ErrNoFutures = errors.New("no futures to wait for")
+
+// Future represents an operation started by its "Go" function. It is
+// finished when the operation is finished, and reports the error of
+// the operation, if any; the result itself is retrieved by the
+// "Result" function of the operation once the future is finished.
+//
+// Unlike callbacks and channels, futures of different operation types
+// can be waited together by [WaitAll] and [WaitAny].
+//
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+// Create a new unfinished future.
+//
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+// Finish the future with the given error.
+//
+func (future *Future) finish(err error) {
+	future.err = err
+	close(future.done)
+}
+
+// Return a channel that is closed when the future is finished.
+//
+func (future *Future) Done() <-chan struct{} {
+	return future.done
+}
+
+// Wait until the future is finished and return the error of the
+// operation. If the given context is done before that, the context
+// error is returned instead; the operation itself is controlled by
+// the context given when it is started and keeps going.
+//
+func (future *Future) Wait(ctx context.Context) error {
+	select {
+	case <-future.done:
+		return future.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Return the error of the operation, which is nil if the operation
+// succeeds. The function panics if the future is not finished yet.
+//
+func (future *Future) Result() error {
+	select {
+	case <-future.done:
+		return future.err
+	default:
+		panic("future not finished")
+	}
+}
+
+// Wait until all the given futures are finished, and return the first
+// error of their operations in the order of the futures. If the given
+// context is done before that, the context error is returned instead.
+//
+func WaitAll(ctx context.Context, futures ...*Future) error {
+	for _, future := range futures {
+		select {
+		case <-future.done:
+			continue
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for _, future := range futures {
+		if future.err != nil {
+			return future.err
+		}
+	}
+
+	return nil
+}
+
+// Wait until any of the given futures is finished, and return its
+// index together with the error of its operation. If several futures
+// are already finished, the first one is reported. If the given
+// context is done before that, -1 and the context error are returned
+// instead. If no future is given, -1 and [ErrNoFutures] are returned
+// at once.
+//
+func WaitAny(ctx context.Context, futures ...*Future) (int, error) {
+	if len(futures) == 0 {
+		return -1, ErrNoFutures
+	}
+
+	for index, future := range futures {
+		select {
+		case <-future.done:
+			return index, future.err
+		default:
+			continue
+		}
+	}
+
+	cases := make([]reflect.SelectCase, 0, len(futures)+1)
+
+	for _, future := range futures {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(future.done)})
+	}
+
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+
+	if chosen, _, _ := reflect.Select(cases); chosen < len(futures) {
+		return chosen, futures[chosen].err
+	} else {
+		return -1, ctx.Err()
+	}
+}
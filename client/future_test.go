@@ -0,0 +1,168 @@
+package mindctrl_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Hold the ping calls to the given server until the returned function
+// is called. The calls are released when the test finishes unless they
+// are released by the test itself.
+//
+func holdPings(t *testing.T, server *mindctrltest.Server) func() {
+	t.Helper()
+
+	held := make(chan struct{})
+	once := sync.Once{}
+	release := func() { once.Do(func() { close(held) }) }
+
+	server.Handle(protocol.PingMethod, func(input json.RawMessage) interface{} {
+		<-held
+		return protocol.GenericOutput{Success: true}
+	})
+
+	t.Cleanup(release)
+	return release
+}
+
+func TestGo(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	tab := server.AddTab(protocol.Tab{Url: "https://example.com/"})
+	found := mindctrl.GetTab(tab.Id)
+	missing := mindctrl.GetTab(1000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := found.Go(transport).Wait(ctx); err != nil {
+		t.Errorf("future of a successful call fails with %v", err)
+	} else if result, err := found.Result(); err != nil || result.Url != tab.Url {
+		t.Errorf("call returns %+v and %v, expected %s", result, err, tab.Url)
+	}
+
+	if err := missing.Go(transport).Wait(ctx); errors.Is(err, mindctrl.ErrExecution) == false {
+		t.Errorf("future of a failed call fails with %v, expected %v", err, mindctrl.ErrExecution)
+	} else if _, err := missing.Result(); errors.Is(err, mindctrl.ErrExecution) == false {
+		t.Errorf("failed call returns %v, expected %v", err, mindctrl.ErrExecution)
+	}
+}
+
+func TestGoContext(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	holdPings(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	future := mindctrl.Ping().GoContext(ctx, transport)
+	cancel()
+
+	within(t, 5*time.Second, func() {
+		if err := future.Wait(context.Background()); errors.Is(err, context.Canceled) == false {
+			t.Errorf("future of a cancelled call fails with %v, expected %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestFutureWaitGivesUpWithContext(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	holdPings(t, server)
+	future := mindctrl.Ping().Go(transport)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := future.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait fails with %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	select {
+	case <-future.Done():
+		t.Errorf("future finished while the call is held")
+	default:
+	}
+}
+
+func TestWaitAll(t *testing.T) {
+	_, transport := startTransport(t, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := mindctrl.WaitAll(ctx, mindctrl.Ping().Go(transport), mindctrl.FindTabs().Go(transport)); err != nil {
+		t.Errorf("waiting for successful calls fails with %v", err)
+	}
+
+	if err := mindctrl.WaitAll(ctx, mindctrl.Ping().Go(transport), mindctrl.GetTab(1000).Go(transport)); errors.Is(err, mindctrl.ErrExecution) == false {
+		t.Errorf("waiting for a failed call fails with %v, expected %v", err, mindctrl.ErrExecution)
+	}
+
+	if err := mindctrl.WaitAll(ctx); err != nil {
+		t.Errorf("waiting for no futures fails with %v", err)
+	}
+}
+
+func TestWaitAllGivesUpWithContext(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	holdPings(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := mindctrl.WaitAll(ctx, mindctrl.FindTabs().Go(transport), mindctrl.Ping().Go(transport)); err != context.DeadlineExceeded {
+		t.Errorf("waiting for a held call fails with %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWaitAny(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	release := holdPings(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	held := mindctrl.Ping().Go(transport)
+	failed := mindctrl.GetTab(1000).Go(transport)
+
+	if index, err := mindctrl.WaitAny(ctx, held, failed); index != 1 || errors.Is(err, mindctrl.ErrExecution) == false {
+		t.Errorf("waiting for any call returns %d and %v, expected 1 and %v", index, err, mindctrl.ErrExecution)
+	}
+
+	// Futures already finished are reported in the order given, even
+	// if a later one finishes first.
+
+	release()
+
+	if err := held.Wait(ctx); err != nil {
+		t.Fatalf("held call fails with %v", err)
+	}
+
+	if index, err := mindctrl.WaitAny(ctx, held, failed); index != 0 || err != nil {
+		t.Errorf("waiting for finished calls returns %d and %v, expected 0 and no error", index, err)
+	}
+}
+
+func TestWaitAnyGivesUpWithContext(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	holdPings(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if index, err := mindctrl.WaitAny(ctx, mindctrl.Ping().Go(transport), mindctrl.Ping().Go(transport)); index != -1 || err != context.DeadlineExceeded {
+		t.Errorf("waiting for held calls returns %d and %v, expected -1 and %v", index, err, context.DeadlineExceeded)
+	}
+}
+
+func TestWaitAnyWithoutFutures(t *testing.T) {
+	within(t, 5*time.Second, func() {
+		if index, err := mindctrl.WaitAny(context.Background()); index != -1 || err != mindctrl.ErrNoFutures {
+			t.Errorf("waiting for no futures returns %d and %v, expected -1 and %v", index, err, mindctrl.ErrNoFutures)
+		}
+	})
+}
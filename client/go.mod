@@ -5,12 +5,12 @@ go 1.18
 require (
 	github.com/eclipse/paho.golang v0.10.0
 	github.com/spf13/cobra v1.6.1
+	github.com/spf13/pflag v1.0.5
 	nhooyr.io/websocket v1.8.7
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.0.1 // indirect
 	github.com/klauspost/compress v1.10.3 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a // indirect
 )
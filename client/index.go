@@ -42,7 +42,183 @@
 // the call is finished. The end result can be retrieved by the
 // "Result" function.
 //
-// For the asynchronous methods, progress checks and post-finish
-// actions are handled by the [Transport.Dispatch] function.
+// The "Go" function executes the call over the given transport
+// asynchronously, and returns a [Future] that is finished after the
+// call is finished. Futures of different operation types can be
+// waited together by [WaitAll] and [WaitAny], so that tab loads,
+// queries and downloads can run concurrently in a single step:
+//
+//	load := mindctrl.LoadTab(tabId, url)
+//	download := mindctrl.CreateDownload(fileUrl, filename)
+//	err := mindctrl.WaitAll(ctx, load.Go(transport), download.Go(transport))
+//
+// The end result of each operation can then be retrieved by its
+// "Result" function. The "GoContext" function works likewise but
+// accepts a context that controls the call.
+//
+// For the asynchronous methods, post-finish actions are handled by
+// a background goroutine owned by the transport. The legacy
+// [Transport.Dispatch] function is kept for compatibility, but it may
+// return early while a callback is running; [WaitAll] is the reliable
+// way to wait for outstanding calls to finish.
+//
+// Errors
+//
+// Calls failed by the server are reported as [*RemoteError] with the
+// error category from the server, and can be told apart by matching
+// against [ErrUnknownMethod], [ErrInvalidInput], [ErrInternal] and
+// [ErrExecution] with [errors.Is]. Execution errors may be worth a
+// retry, while the others will recur. Calls refused by the capability
+// token or the sandbox policy are still reported as [*ForbiddenError]
+// and [*PolicyViolationError], which wrap the remote error. Failures
+// of the call itself, like timeouts or lost connections, are reported
+// by the codec.
+//
+// Concurrency
+//
+// The transport is safe for concurrent use. Multiple goroutines can
+// execute or start operations over a single transport at the same
+// time. However, each operation instance should only be used by one
+// goroutine.
+//
+// Cancellation
+//
+// The "ExecuteContext" and "StartContext" functions work like their
+// counterparts above, but accept a context that controls the call.
+// If the context is cancelled or its deadline passes before the
+// call is finished, the call is abandoned and the operation fails
+// with the context error.
+//
+// Shutdown
+//
+// The transport should be closed by [Transport.Close] when it is no
+// longer needed. Calls in flight at that moment fail with the error
+// [codec.ErrClosed] instead of waiting forever. The [Transport.Done]
+// and [Transport.Err] functions report when and why the transport
+// becomes unusable, whether it is closed or the server is lost for
+// good; the OnDisconnect hook in the options offers the same
+// information as a callback.
+//
+// Interceptors
+//
+// Cross-cutting behaviour like logging, metrics, retries or extra
+// request data can be added by passing interceptors to
+// [NewTransport]. Each interceptor sees the method, the input and
+// the output of every call, synchronous or not, and decides how the
+// call proceeds:
+//
+//	logger := func(ctx context.Context, method string, input, output interface{}, invoke mindctrl.Invoker) error {
+//		start := time.Now()
+//		err := invoke(ctx, method, input, output)
+//		generic := mindctrl.GetGenericOutput(output)
+//		log.Printf("%s %v success=%v in %s", method, err, generic.Success, time.Since(start))
+//		return err
+//	}
+//
+//	transport, err := mindctrl.NewTransport(url, client, server, nil, logger)
+//
+// Capabilities
+//
+// [GetCapabilitiesOperation] reports the version of the extension,
+// the protocol version it speaks and the methods it supports, so
+// that callers can check for methods like "tabs.deactivate" before
+// using them. Setting 'StrictProtocol' in the options makes
+// [NewTransport] refuse servers that speak another protocol version
+// with [ErrIncompatibleProtocol].
+//
+// Compression
+//
+// Large outputs, like documents.query results with whole pages of
+// HTML, are compressed by recent extensions and decompressed by the
+// transport without any setup. Large inputs are compressed as well
+// when the 'Compression' option names an encoding; extensions that
+// support it list the encoding in [protocol.Capabilities].
+//
+// Chunking
+//
+// Responses larger than the 'ChunkSize' option, 256 KiB by default,
+// are split by recent extensions into chunks that stay below the
+// message size limit of the broker, and reassembled by the transport.
+// Calls whose chunks do not all arrive within the 'ChunkTimeout'
+// option, or do not fit within the 'ChunkMemoryLimit' option, fail
+// with [codec.ErrIncompleteResponse] or [codec.ErrResponseTooLarge].
+//
+// Signing
+//
+// Messages can be signed end to end, so that broker credentials
+// alone no longer control the browser. Set the 'SigningSecret'
+// option to the secret shared with the extension, or the
+// 'SigningKey' and 'ServerPublicKey' options to Ed25519 keys; each
+// message is then wrapped in a [protocol.SignedPacket] with a
+// timestamp and a nonce, and responses that are unsigned, badly
+// signed, stale or replayed are dropped. A signing key without the
+// public key of the server is refused unless the 'SkipServerVerify'
+// option is set. Status messages are not signed, so whoever can
+// publish to the status topic can still mark the server dead or
+// alive.
+//
+// Capability tokens
+//
+// Extensions configured with a token key only execute the methods
+// permitted by the capability token of the client, which is passed
+// in the 'Token' option and issued by [protocol.IssueToken]. Calls
+// to other methods fail with an error matching [ErrForbidden].
+//
+// Tokens are bound to the public key of the client, and are only
+// honoured on requests signed with the matching private key; the
+// client name in the token is chosen by the caller and does not stop
+// a token seen on the broker from being replayed. A token therefore
+// requires the Ed25519 'SigningKey' option, and [NewTransport] fails
+// with [codec.ErrUnboundToken] if the token is not bound to it.
+//
+// Sandbox policy
+//
+// Extensions can be configured with a policy restricting the pages
+// tabs.load, tabs.create, tabs.reload, windows.create and
+// documents.query may touch, and the directories downloads.create may
+// save files to. The policy is reported by [GetPolicyOperation], and
+// calls violating it fail with an error matching [ErrPolicyViolation].
+// Pages redirecting elsewhere are only caught when the call waits for
+// the page to load; see [protocol.Policy].
+//
+// Discovery
+//
+// The servers known to a broker, alive or not, can be listed by
+// [DiscoverServers] before a transport is created. Each server is
+// reported with its state and the time of its last heartbeat.
+//
+// Batches
+//
+// Many small calls can be sent together with a [Batch], which
+// publishes the requests of its operations in as few MQTT messages
+// as the websocket frame size allows. The operations are finished
+// when the batch is executed, and their results are retrieved with
+// their "Result" functions as usual. Batches can be executed over a
+// transport or a pool; calls in batches sent to servers predating
+// batch packets are executed individually.
+//
+// Pools
+//
+// A [Pool] holds the transports of several servers on one broker
+// and spreads calls across them, either round-robin or to the least
+// busy server, within optional per-server concurrency limits. Since
+// operations run over any [Executor], the pool can be used wherever
+// a transport is expected:
+//
+//	pool, err := mindctrl.NewPool(url, client, nil, &mindctrl.PoolOptions{Limit: 4})
+//	tabs, err := mindctrl.FindTabs().Execute(pool)
+//
+// Servers join the pool when they are alive and leave when they die.
+//
+// Recording and Replay
+//
+// Setting 'RecordFile' in the options makes the transport record
+// every call and its response to a cassette, a JSONL file with one
+// [codec.Interaction] per line. A transport created with
+// 'ReplayFile' set instead answers calls from such a cassette
+// without any broker or browser, which allows code like a scraping
+// pipeline built on [QueryDocumentOperation] to run offline and in
+// regression tests. Calls that cannot be matched with the cassette
+// fail with [codec.ErrNotRecorded].
 //
 package mindctrl
@@ -1,7 +1,7 @@
 package mindctrl
 
 import (
-	"errors"
+	"context"
 	"github.com/kmchan2018/mindctrl/client/protocol"
 )
 
@@ -19,25 +19,45 @@ func GetBrowserInfo() *GetBrowserInfoOperation {
 	return op
 }
 
-func (op *GetBrowserInfoOperation) Start(transport *Transport, callback func(op *GetBrowserInfoOperation)) {
-	op.doStart(transport, protocol.GetBrowserInfoMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetBrowserInfoOperation) Start(transport Executor, callback func(op *GetBrowserInfoOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *GetBrowserInfoOperation) StartContext(ctx context.Context, transport Executor, callback func(op *GetBrowserInfoOperation)) {
+	op.doStart(ctx, transport, protocol.GetBrowserInfoMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *GetBrowserInfoOperation) StartChannel(transport *Transport, channel chan *GetBrowserInfoOperation) {
-	op.doStart(transport, protocol.GetBrowserInfoMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetBrowserInfoOperation) StartChannel(transport Executor, channel chan *GetBrowserInfoOperation) {
+	op.doStart(context.Background(), transport, protocol.GetBrowserInfoMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *GetBrowserInfoOperation) Execute(transport *Transport) (*protocol.BrowserInfo, error) {
-	if err := op.doExecute(transport, protocol.GetBrowserInfoMethod, &op.input, &op.output); err != nil {
+func (op *GetBrowserInfoOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *GetBrowserInfoOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.GetBrowserInfoMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *GetBrowserInfoOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.GetBrowserInfoMethod, &op.input, &op.output)
+}
+
+func (op *GetBrowserInfoOperation) Execute(transport Executor) (*protocol.BrowserInfo, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *GetBrowserInfoOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.BrowserInfo, error) {
+	if err := op.doExecute(ctx, transport, protocol.GetBrowserInfoMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -46,8 +66,10 @@ func (op *GetBrowserInfoOperation) Execute(transport *Transport) (*protocol.Brow
 func (op *GetBrowserInfoOperation) Result() (*protocol.BrowserInfo, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -67,25 +89,45 @@ func GetPlatformInfo() *GetPlatformInfoOperation {
 	return instance
 }
 
-func (op *GetPlatformInfoOperation) Start(transport *Transport, callback func(op *GetPlatformInfoOperation)) {
-	op.doStart(transport, protocol.GetPlatformInfoMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetPlatformInfoOperation) Start(transport Executor, callback func(op *GetPlatformInfoOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *GetPlatformInfoOperation) StartContext(ctx context.Context, transport Executor, callback func(op *GetPlatformInfoOperation)) {
+	op.doStart(ctx, transport, protocol.GetPlatformInfoMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *GetPlatformInfoOperation) StartChannel(transport *Transport, channel chan *GetPlatformInfoOperation) {
-	op.doStart(transport, protocol.GetPlatformInfoMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetPlatformInfoOperation) StartChannel(transport Executor, channel chan *GetPlatformInfoOperation) {
+	op.doStart(context.Background(), transport, protocol.GetPlatformInfoMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *GetPlatformInfoOperation) Execute(transport *Transport) (*protocol.PlatformInfo, error) {
-	if err := op.doExecute(transport, protocol.GetPlatformInfoMethod, &op.input, &op.output); err != nil {
+func (op *GetPlatformInfoOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *GetPlatformInfoOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.GetPlatformInfoMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *GetPlatformInfoOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.GetPlatformInfoMethod, &op.input, &op.output)
+}
+
+func (op *GetPlatformInfoOperation) Execute(transport Executor) (*protocol.PlatformInfo, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *GetPlatformInfoOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.PlatformInfo, error) {
+	if err := op.doExecute(ctx, transport, protocol.GetPlatformInfoMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -94,8 +136,150 @@ func (op *GetPlatformInfoOperation) Execute(transport *Transport) (*protocol.Pla
 func (op *GetPlatformInfoOperation) Result() (*protocol.PlatformInfo, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
+	} else {
+		return &op.output.Result, nil
+	}
+}
+
+// This operation provides a fluent interface to execute info.get_capabilities
+// method on a mindctrl web extension instance.
+//
+type GetCapabilitiesOperation struct {
+	GenericOperation
+	input  protocol.GetCapabilitiesInput
+	output protocol.GetCapabilitiesOutput
+}
+
+func GetCapabilities() *GetCapabilitiesOperation {
+	instance := &GetCapabilitiesOperation{}
+	return instance
+}
+
+func (op *GetCapabilitiesOperation) Start(transport Executor, callback func(op *GetCapabilitiesOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *GetCapabilitiesOperation) StartContext(ctx context.Context, transport Executor, callback func(op *GetCapabilitiesOperation)) {
+	op.doStart(ctx, transport, protocol.GetCapabilitiesMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+		op.doFinish(err)
+		callback(op)
+	})
+}
+
+func (op *GetCapabilitiesOperation) StartChannel(transport Executor, channel chan *GetCapabilitiesOperation) {
+	op.doStart(context.Background(), transport, protocol.GetCapabilitiesMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+		op.doFinish(err)
+		channel <- op
+	})
+}
+
+func (op *GetCapabilitiesOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *GetCapabilitiesOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.GetCapabilitiesMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *GetCapabilitiesOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.GetCapabilitiesMethod, &op.input, &op.output)
+}
+
+func (op *GetCapabilitiesOperation) Execute(transport Executor) (*protocol.Capabilities, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *GetCapabilitiesOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Capabilities, error) {
+	if err := op.doExecute(ctx, transport, protocol.GetCapabilitiesMethod, &op.input, &op.output); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
+	} else {
+		return &op.output.Result, nil
+	}
+}
+
+func (op *GetCapabilitiesOperation) Result() (*protocol.Capabilities, error) {
+	op.doEnsureFinished()
+
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
+	} else {
+		return &op.output.Result, nil
+	}
+}
+
+// This operation provides a fluent interface to execute info.get_policy
+// method on a mindctrl web extension instance.
+//
+type GetPolicyOperation struct {
+	GenericOperation
+	input  protocol.GetPolicyInput
+	output protocol.GetPolicyOutput
+}
+
+func GetPolicy() *GetPolicyOperation {
+	instance := &GetPolicyOperation{}
+	return instance
+}
+
+func (op *GetPolicyOperation) Start(transport Executor, callback func(op *GetPolicyOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *GetPolicyOperation) StartContext(ctx context.Context, transport Executor, callback func(op *GetPolicyOperation)) {
+	op.doStart(ctx, transport, protocol.GetPolicyMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+		op.doFinish(err)
+		callback(op)
+	})
+}
+
+func (op *GetPolicyOperation) StartChannel(transport Executor, channel chan *GetPolicyOperation) {
+	op.doStart(context.Background(), transport, protocol.GetPolicyMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+		op.doFinish(err)
+		channel <- op
+	})
+}
+
+func (op *GetPolicyOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *GetPolicyOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.GetPolicyMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *GetPolicyOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.GetPolicyMethod, &op.input, &op.output)
+}
+
+func (op *GetPolicyOperation) Execute(transport Executor) (*protocol.Policy, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *GetPolicyOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Policy, error) {
+	if err := op.doExecute(ctx, transport, protocol.GetPolicyMethod, &op.input, &op.output); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
+	} else {
+		return &op.output.Result, nil
+	}
+}
+
+func (op *GetPolicyOperation) Result() (*protocol.Policy, error) {
+	op.doEnsureFinished()
+
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
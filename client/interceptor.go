@@ -0,0 +1,65 @@
+package mindctrl
+
+import (
+	"context"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+)
+
+// Invoker performs a remote call. The input is encoded as the params
+// of the request, and the result of the response is decoded into
+// the output, which is a pointer to one of the output structs in
+// the protocol package.
+//
+type Invoker func(ctx context.Context, method string, input interface{}, output interface{}) error
+
+// Interceptor wraps every remote call made over a transport, both
+// synchronous and asynchronous ones. It receives the call together
+// with the invoker for the rest of the chain, and is responsible for
+// calling the invoker to proceed with the call.
+//
+// An interceptor may inspect or replace the input before invoking
+// the rest of the chain, and inspect the output and the error after
+// the invoker returns; [GetGenericOutput] gives access to the common
+// output fields like the success flag and the error category. It
+// may also short-circuit the call by filling in the output and
+// returning without invoking the chain, or retry the call by
+// invoking the chain more than once.
+//
+// For asynchronous calls, the interceptors run in a goroutine of
+// their own, and the callback is invoked after the whole chain
+// returns.
+//
+type Interceptor func(ctx context.Context, method string, input interface{}, output interface{}, invoke Invoker) error
+
+// Return the common output fields of the given output, or nil if the
+// output does not contain them. Every output struct in the protocol
+// package contains the fields.
+//
+func GetGenericOutput(output interface{}) *protocol.GenericOutput {
+	if generic, ok := output.(interface {
+		Generic() *protocol.GenericOutput
+	}); ok {
+		return generic.Generic()
+	} else if generic, ok := output.(*protocol.GenericOutput); ok {
+		return generic
+	} else {
+		return nil
+	}
+}
+
+// Chain the given interceptors around the given invoker. The first
+// interceptor is the outermost one, so it sees the call first and
+// the output last.
+//
+func chainInterceptors(interceptors []Interceptor, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor := interceptors[i]
+		next := invoker
+
+		invoker = func(ctx context.Context, method string, input interface{}, output interface{}) error {
+			return interceptor(ctx, method, input, output, next)
+		}
+	}
+
+	return invoker
+}
@@ -0,0 +1,135 @@
+package broker
+
+import (
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/broker"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/spf13/cobra"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var (
+	RootCommand = &cobra.Command{
+		Use:   "broker",
+		Short: "Run an intermediate MQTT server",
+		Long:  "Run an intermediate MQTT server for the browser and the clients. The server accepts MQTT over websocket connections and optionally plain MQTT connections. If username and password are given, clients must present them to connect; they are required when the server listens on addresses other than the loopback interface, unless --allow-anonymous is given. Websocket connections from web pages are refused unless their origin matches --websocket-origin; the browser extension and other programs are always accepted. The server keeps no session after a connection is closed.",
+
+		DisableAutoGenTag:     true,
+		DisableFlagsInUseLine: true,
+	}
+)
+
+func init() {
+	RootCommand.Flags().String("websocket", "localhost:9001", "address to accept MQTT over websocket connections")
+	RootCommand.Flags().String("tcp", "", "address to accept plain MQTT connections; empty to disable")
+	RootCommand.Flags().StringSlice("websocket-origin", nil, "host pattern of web pages allowed to connect over websocket, like *.example.com; may be repeated")
+	RootCommand.Flags().Bool("allow-anonymous", false, "accept clients without credentials on addresses other than the loopback interface")
+	RootCommand.Flags().BoolP("verbose", "v", false, "print a line when a client connects or disconnects")
+
+	RootCommand.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	RootCommand.Args = func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.NewExcessArgumentError()
+		} else {
+			return nil
+		}
+	}
+
+	RootCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		websocketAddress, _ := flags.GetString("websocket")
+		tcpAddress, _ := flags.GetString("tcp")
+		origins, _ := flags.GetStringSlice("websocket-origin")
+		anonymous, _ := flags.GetBool("allow-anonymous")
+		verbose, _ := flags.GetBool("verbose")
+		username, _ := flags.GetString("username")
+		password, _ := flags.GetString("password")
+		stdout := cmd.OutOrStdout()
+		options := &broker.Options{WebsocketOrigins: origins}
+
+		if username != "" && password != "" {
+			options.Username = username
+			options.Password = password
+		}
+
+		if verbose {
+			options.OnConnect = func(client string) {
+				fmt.Fprintf(stdout, "Client %s connected\n", client)
+			}
+
+			options.OnDisconnect = func(client string, err error) {
+				if err != nil {
+					fmt.Fprintf(stdout, "Client %s disconnected: %s\n", client, err)
+				} else {
+					fmt.Fprintf(stdout, "Client %s disconnected\n", client)
+				}
+			}
+		}
+
+		if websocketAddress == "" && tcpAddress == "" {
+			return errors.NewArgumentError("no address to listen on")
+		}
+
+		for _, address := range []string{websocketAddress, tcpAddress} {
+			if address != "" && isLoopbackAddress(address) == false && options.Username == "" && anonymous == false {
+				return errors.NewArgumentError("username and password required to listen on %s; use --allow-anonymous to accept any client", address)
+			}
+		}
+
+		b := broker.NewBroker(options)
+		failures := make(chan error, 2)
+
+		if websocketAddress != "" {
+			if listener, err := net.Listen("tcp", websocketAddress); err != nil {
+				return errors.WrapExecutionError(err, "cannot listen on %s", websocketAddress)
+			} else {
+				fmt.Fprintf(stdout, "Accepting websocket connections on ws://%s\n", listener.Addr())
+				go func() { failures <- b.ServeWebsocket(listener) }()
+			}
+		}
+
+		if tcpAddress != "" {
+			if listener, err := net.Listen("tcp", tcpAddress); err != nil {
+				b.Close()
+				return errors.WrapExecutionError(err, "cannot listen on %s", tcpAddress)
+			} else {
+				fmt.Fprintf(stdout, "Accepting plain connections on mqtt://%s\n", listener.Addr())
+				go func() { failures <- b.ServeTcp(listener) }()
+			}
+		}
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+
+		select {
+		case <-signals:
+			b.Close()
+			return nil
+		case err := <-failures:
+			b.Close()
+			return errors.WrapExecutionError(err, "cannot accept connections")
+		}
+	}
+}
+
+// Return if the given listening address only accepts connections from
+// the local host.
+//
+func isLoopbackAddress(address string) bool {
+	if host, _, err := net.SplitHostPort(address); err != nil {
+		return false
+	} else if host == "localhost" {
+		return true
+	} else if ip := net.ParseIP(host); ip == nil {
+		return false
+	} else {
+		return ip.IsLoopback()
+	}
+}
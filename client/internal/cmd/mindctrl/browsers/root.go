@@ -0,0 +1,90 @@
+package browsers
+
+import (
+	"context"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/options"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+var (
+	RootCommand = &cobra.Command{
+		Use:   "browsers",
+		Short: "List browsers on the intermediate MQTT server",
+		Long:  "List browsers on the intermediate MQTT server, together with whether they are alive and when their last heartbeat is published. The last heartbeat is unknown for browsers running older versions of the extension.",
+
+		DisableAutoGenTag:     true,
+		DisableFlagsInUseLine: true,
+	}
+)
+
+func init() {
+	RootCommand.Flags().Duration("wait", 1*time.Second, "time to wait for status messages from the browsers")
+
+	RootCommand.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if server, _ := cmd.Flags().GetString("server"); server == "" {
+			return errors.NewArgumentError("unknown url to intermediate mqtt server")
+		} else {
+			return nil
+		}
+	}
+
+	RootCommand.Args = func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.NewExcessArgumentError()
+		} else {
+			return nil
+		}
+	}
+
+	RootCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		server, _ := flags.GetString("server")
+		wait, _ := flags.GetDuration("wait")
+		timeout, _ := flags.GetDuration("timeout")
+		ctx := context.Background()
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout+wait)
+			defer cancel()
+		}
+
+		options := options.GetOptions(cmd)
+		options.DiscoveryWindow = wait
+
+		if servers, err := mindctrl.DiscoverServersContext(ctx, server, options); err != nil {
+			return errors.WrapExecutionError(err, "cannot discover browsers")
+		} else {
+			stdout := cmd.OutOrStdout()
+
+			if count := len(servers); count == 0 {
+				fmt.Fprintf(stdout, "No browsers found.\n\n")
+			} else {
+				fmt.Fprintf(stdout, "%d browsers found:\n\n", count)
+
+				for _, data := range servers {
+					state := "dead"
+					seen := "unknown"
+
+					if data.Alive {
+						state = "alive"
+					}
+
+					if data.LastSeen.IsZero() == false {
+						seen = data.LastSeen.Local().Format(time.RFC3339)
+					}
+
+					fmt.Fprintf(stdout, "%-24s | %-5s | %s\n", data.Name, state, seen)
+				}
+
+				fmt.Fprintf(stdout, "\n")
+			}
+
+			return nil
+		}
+	}
+}
@@ -37,6 +37,8 @@ func init() {
 		if transport, err := options.GetTransport(cmd); err != nil {
 			return errors.WrapExecutionError(err, "cannot connect to browser")
 		} else {
+			defer transport.Close()
+
 			download := options.ParseId(args[0])
 			operation := mindctrl.CancelDownload(download)
 			stdout := cmd.OutOrStdout()
@@ -46,6 +46,8 @@ func init() {
 		if transport, err := options.GetTransport(cmd); err != nil {
 			return errors.WrapExecutionError(err, "cannot connect to browser")
 		} else {
+			defer transport.Close()
+
 			url := args[0]
 			filename := args[1]
 			operation := mindctrl.CreateDownload(url, filename)
@@ -39,6 +39,8 @@ func init() {
 		if transport, err := options.GetTransport(cmd); err != nil {
 			return errors.WrapExecutionError(err, "cannot connect to browser")
 		} else {
+			defer transport.Close()
+
 			operation := mindctrl.FindDownloads()
 			stdout := cmd.OutOrStdout()
 			flags := cmd.Flags()
@@ -0,0 +1,115 @@
+package fakeserver
+
+import (
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/options"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var (
+	RootCommand = &cobra.Command{
+		Use:   "fake-server",
+		Short: "Run a fake browser for testing",
+		Long:  "Run a fake browser that connects to the intermediate MQTT server under the given browser name and answers requests from an in-memory set of tabs, windows and downloads. It is intended for trying out clients without a real browser.",
+
+		DisableAutoGenTag:     true,
+		DisableFlagsInUseLine: true,
+	}
+)
+
+func init() {
+	RootCommand.Flags().BoolP("verbose", "v", false, "print a line when a request is received")
+	RootCommand.Flags().String("client-key", "", "Ed25519 public key of the client in base64 for verifying requests")
+	RootCommand.Flags().String("token-key", "", "Ed25519 public key of the token issuer in base64; requires capability tokens bound to the client key if given")
+	RootCommand.Flags().StringSlice("url-pattern", nil, "match patterns of the pages clients may load, create and query; any page if omitted")
+	RootCommand.Flags().StringSlice("download-directory", nil, "directories clients may download files to; any directory if omitted")
+
+	RootCommand.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		server, _ := cmd.Flags().GetString("server")
+		browser, _ := cmd.Flags().GetString("browser")
+
+		signingKey, _ := cmd.Flags().GetString("signing-key")
+		clientKey, _ := cmd.Flags().GetString("client-key")
+		tokenKey, _ := cmd.Flags().GetString("token-key")
+
+		if err := options.CheckSigningKeys(cmd, "client-key", "token-key"); err != nil {
+			return err
+		} else if tokenKey != "" && (signingKey == "" || clientKey == "") {
+			return errors.NewArgumentError("token key requires signing key and client key")
+		} else if server == "" {
+			return errors.NewArgumentError("unknown url to intermediate mqtt server")
+		} else if browser == "" {
+			return errors.NewArgumentError("unknown browser name")
+		} else {
+			return nil
+		}
+	}
+
+	RootCommand.Args = func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.NewExcessArgumentError()
+		} else {
+			return nil
+		}
+	}
+
+	RootCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		url, _ := flags.GetString("server")
+		name, _ := flags.GetString("browser")
+		username, _ := flags.GetString("username")
+		password, _ := flags.GetString("password")
+		verbose, _ := flags.GetBool("verbose")
+		signingSecret, _ := flags.GetString("signing-secret")
+		urlPatterns, _ := flags.GetStringSlice("url-pattern")
+		downloadDirectories, _ := flags.GetStringSlice("download-directory")
+		stdout := cmd.OutOrStdout()
+		serverOptions := &mindctrltest.Options{}
+
+		if username != "" && password != "" {
+			serverOptions.Username = username
+			serverOptions.Password = password
+		}
+
+		if signingKey := options.GetSigningKey(cmd); signingKey != nil {
+			serverOptions.SigningKey = signingKey
+			serverOptions.ClientPublicKey = options.GetPublicKey(cmd, "client-key")
+		} else if signingSecret != "" {
+			serverOptions.SigningSecret = signingSecret
+		}
+
+		if tokenKey := options.GetPublicKey(cmd, "token-key"); tokenKey != nil {
+			serverOptions.TokenKey = tokenKey
+		}
+
+		serverOptions.Policy.UrlPatterns = urlPatterns
+		serverOptions.Policy.DownloadDirectories = downloadDirectories
+
+		if verbose {
+			serverOptions.OnRequest = func(request mindctrltest.Request) {
+				fmt.Fprintf(stdout, "Client %s called method %s with input %s\n", request.Client, request.Method, request.Input)
+			}
+		}
+
+		server, err := mindctrltest.NewServer(url, name, serverOptions)
+
+		if err != nil {
+			return errors.WrapExecutionError(err, "cannot connect to intermediate mqtt server")
+		}
+
+		fmt.Fprintf(stdout, "Fake browser %s connected to %s\n", name, url)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+
+		<-signals
+		server.Close()
+		return nil
+	}
+}
@@ -36,6 +36,8 @@ func init() {
 		} else if platform, err := mindctrl.GetPlatformInfo().Execute(transport); err != nil {
 			return errors.WrapExecutionError(err, "cannot fetch information on the platform")
 		} else {
+			defer transport.Close()
+
 			stdout := cmd.OutOrStdout()
 			fmt.Fprintf(stdout, "Browser Name: %s\n", browser.Name)
 			fmt.Fprintf(stdout, "Browser Version: %s\n", browser.Version)
@@ -34,6 +34,8 @@ func init() {
 		} else if browser, err := mindctrl.GetBrowserInfo().Execute(transport); err != nil {
 			return errors.WrapExecutionError(err, "cannot fetch information on the browser")
 		} else {
+			defer transport.Close()
+
 			stdout := cmd.OutOrStdout()
 			fmt.Fprintf(stdout, "Browser Name: %s\n", browser.Name)
 			fmt.Fprintf(stdout, "Browser Version: %s\n", browser.Version)
@@ -0,0 +1,50 @@
+package info
+
+import (
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/options"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var (
+	CapabilitiesCommand = &cobra.Command{
+		Use:   "capabilities",
+		Short: "Print capabilities of the extension",
+		Long:  "Print capabilities of the extension, including its version, the version of the protocol it speaks and the methods it supports",
+
+		DisableAutoGenTag:     true,
+		DisableFlagsInUseLine: true,
+	}
+)
+
+func init() {
+	CapabilitiesCommand.Args = func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.NewExcessArgumentError()
+		} else {
+			return nil
+		}
+	}
+
+	CapabilitiesCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		if transport, err := options.GetTransport(cmd); err != nil {
+			return errors.WrapExecutionError(err, "cannot connect to browser")
+		} else if capabilities, err := mindctrl.GetCapabilities().Execute(transport); err != nil {
+			return errors.WrapExecutionError(err, "cannot fetch capabilities of the extension")
+		} else {
+			defer transport.Close()
+
+			stdout := cmd.OutOrStdout()
+			fmt.Fprintf(stdout, "Extension Version: %s\n", capabilities.ExtensionVersion)
+			fmt.Fprintf(stdout, "Protocol Version: %d\n", capabilities.ProtocolVersion)
+			fmt.Fprintf(stdout, "Methods: %s\n", strings.Join(capabilities.Methods, ", "))
+			fmt.Fprintf(stdout, "Encodings: %s\n", strings.Join(capabilities.Encodings, ", "))
+			fmt.Fprintf(stdout, "Batch: %t\n", capabilities.Batch)
+			fmt.Fprintf(stdout, "\n")
+			return nil
+		}
+	}
+}
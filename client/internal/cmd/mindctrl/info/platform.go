@@ -34,6 +34,8 @@ func init() {
 		} else if platform, err := mindctrl.GetPlatformInfo().Execute(transport); err != nil {
 			return errors.WrapExecutionError(err, "cannot fetch information on the platform")
 		} else {
+			defer transport.Close()
+
 			stdout := cmd.OutOrStdout()
 			fmt.Fprintf(stdout, "Processor Architecture: %s\n", platform.Arch)
 			fmt.Fprintf(stdout, "Operating System: %s\n", platform.Os)
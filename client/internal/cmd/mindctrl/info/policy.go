@@ -0,0 +1,58 @@
+package info
+
+import (
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/options"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var (
+	PolicyCommand = &cobra.Command{
+		Use:   "policy",
+		Short: "Print sandbox policy of the extension",
+		Long:  "Print sandbox policy of the extension, including the match patterns of the pages that may be loaded, created and queried, and the directories that downloads may be saved to",
+
+		DisableAutoGenTag:     true,
+		DisableFlagsInUseLine: true,
+	}
+)
+
+func init() {
+	PolicyCommand.Args = func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.NewExcessArgumentError()
+		} else {
+			return nil
+		}
+	}
+
+	PolicyCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		if transport, err := options.GetTransport(cmd); err != nil {
+			return errors.WrapExecutionError(err, "cannot connect to browser")
+		} else if policy, err := mindctrl.GetPolicy().Execute(transport); err != nil {
+			return errors.WrapExecutionError(err, "cannot fetch policy of the extension")
+		} else {
+			defer transport.Close()
+
+			stdout := cmd.OutOrStdout()
+			fmt.Fprintf(stdout, "URL Patterns: %s\n", describeList(policy.UrlPatterns))
+			fmt.Fprintf(stdout, "Download Directories: %s\n", describeList(policy.DownloadDirectories))
+			fmt.Fprintf(stdout, "\n")
+			return nil
+		}
+	}
+}
+
+// Describe the given list of the policy, which puts no restriction
+// when it is empty.
+//
+func describeList(list []string) string {
+	if len(list) == 0 {
+		return "(unrestricted)"
+	} else {
+		return strings.Join(list, ", ")
+	}
+}
@@ -17,5 +17,7 @@ func init() {
 
 	RootCommand.AddCommand(AllCommand)
 	RootCommand.AddCommand(BrowserCommand)
+	RootCommand.AddCommand(CapabilitiesCommand)
 	RootCommand.AddCommand(PlatformCommand)
+	RootCommand.AddCommand(PolicyCommand)
 }
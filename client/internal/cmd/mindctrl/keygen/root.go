@@ -0,0 +1,49 @@
+package keygen
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"github.com/spf13/cobra"
+)
+
+var (
+	RootCommand = &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate a key pair for signing messages",
+		Long:  "Generate an Ed25519 key pair for signing messages between the clients and the browser. The private key is given to the side that signs with it, as the --signing-key flag or the signing key in the extension options; the public key is given to the other side, as the --server-key flag or the client keys in the extension options.",
+
+		DisableAutoGenTag:     true,
+		DisableFlagsInUseLine: true,
+	}
+)
+
+func init() {
+	RootCommand.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return nil
+	}
+
+	RootCommand.Args = func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.NewExcessArgumentError()
+		} else {
+			return nil
+		}
+	}
+
+	RootCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		stdout := cmd.OutOrStdout()
+
+		if publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader); err != nil {
+			return errors.WrapExecutionError(err, "cannot generate key pair")
+		} else if encoded, err := protocol.EncodePrivateKey(privateKey); err != nil {
+			return errors.WrapExecutionError(err, "cannot encode private key")
+		} else {
+			fmt.Fprintf(stdout, "Private key: %s\n", encoded)
+			fmt.Fprintf(stdout, "Public key:  %s\n", protocol.EncodePublicKey(publicKey))
+			return nil
+		}
+	}
+}
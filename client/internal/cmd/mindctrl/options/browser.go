@@ -0,0 +1,37 @@
+package options
+
+import (
+	"context"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/spf13/cobra"
+	"strings"
+	"time"
+)
+
+func SelectBrowser(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	server, _ := flags.GetString("server")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if servers, err := mindctrl.DiscoverServersContext(ctx, server, GetOptions(cmd)); err != nil {
+		return errors.WrapExecutionError(err, "cannot discover browsers")
+	} else {
+		alive := make([]string, 0)
+
+		for _, candidate := range servers {
+			if candidate.Alive {
+				alive = append(alive, candidate.Name)
+			}
+		}
+
+		if len(alive) == 0 {
+			return errors.NewArgumentError("unknown browser name; no live browser found")
+		} else if len(alive) > 1 {
+			return errors.NewArgumentError("unknown browser name; multiple live browsers found: %s", strings.Join(alive, ", "))
+		} else {
+			return flags.Set("browser", alive[0])
+		}
+	}
+}
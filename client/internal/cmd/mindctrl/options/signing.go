@@ -0,0 +1,49 @@
+package options
+
+import (
+	"crypto/ed25519"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"github.com/spf13/cobra"
+)
+
+func CheckSigningKeys(cmd *cobra.Command, names ...string) error {
+	flags := cmd.Flags()
+	signingKey, _ := flags.GetString("signing-key")
+
+	if signingKey != "" {
+		if _, err := protocol.DecodePrivateKey(signingKey); err != nil {
+			return errors.NewArgumentError("invalid signing key: %s", err)
+		}
+	}
+
+	for _, name := range names {
+		if publicKey, _ := flags.GetString(name); publicKey != "" {
+			if _, err := protocol.DecodePublicKey(publicKey); err != nil {
+				return errors.NewArgumentError("invalid %s: %s", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func GetSigningKey(cmd *cobra.Command) ed25519.PrivateKey {
+	if encoded, _ := cmd.Flags().GetString("signing-key"); encoded == "" {
+		return nil
+	} else if key, err := protocol.DecodePrivateKey(encoded); err != nil {
+		return nil
+	} else {
+		return key
+	}
+}
+
+func GetPublicKey(cmd *cobra.Command, name string) ed25519.PublicKey {
+	if encoded, _ := cmd.Flags().GetString(name); encoded == "" {
+		return nil
+	} else if key, err := protocol.DecodePublicKey(encoded); err != nil {
+		return nil
+	} else {
+		return key
+	}
+}
@@ -1,6 +1,7 @@
 package options
 
 import (
+	"context"
 	"fmt"
 	"github.com/kmchan2018/mindctrl/client"
 	"github.com/spf13/cobra"
@@ -12,19 +13,72 @@ func GetTransport(cmd *cobra.Command) (*mindctrl.Transport, error) {
 	flags := cmd.Flags()
 	server, _ := flags.GetString("server")
 	browser, _ := flags.GetString("browser")
-	username, _ := flags.GetString("username")
-	password, _ := flags.GetString("password")
+	timeout, _ := flags.GetDuration("timeout")
 
 	pid := os.Getpid()
 	now := time.Now().UnixMilli()
 	name := fmt.Sprintf("mindctrl_golang_%d_%d", pid, now)
+	options := GetOptions(cmd)
+
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		options.RequestTimeout = timeout
+		return mindctrl.NewTransportContext(ctx, server, name, browser, options)
+	} else {
+		return mindctrl.NewTransport(server, name, browser, options)
+	}
+}
+
+func GetOptions(cmd *cobra.Command) *mindctrl.Options {
+	flags := cmd.Flags()
+	username, _ := flags.GetString("username")
+	password, _ := flags.GetString("password")
+	caFile, _ := flags.GetString("ca-file")
+	cert, _ := flags.GetString("cert")
+	key, _ := flags.GetString("key")
+	record, _ := flags.GetString("record")
+	replay, _ := flags.GetString("replay")
+	replayStrict, _ := flags.GetBool("replay-strict")
+	strictProtocol, _ := flags.GetBool("strict-protocol")
+	compression, _ := flags.GetString("compression")
+	chunkSize, _ := flags.GetInt("chunk-size")
+	signingSecret, _ := flags.GetString("signing-secret")
+	skipServerVerification, _ := flags.GetBool("skip-server-verification")
+	token, _ := flags.GetString("token")
+	options := &mindctrl.Options{StrictProtocol: strictProtocol, Compression: compression, ChunkSize: chunkSize, Token: token}
+
+	if signingKey := GetSigningKey(cmd); signingKey != nil {
+		options.SigningKey = signingKey
+		options.ServerPublicKey = GetPublicKey(cmd, "server-key")
+		options.SkipServerVerify = skipServerVerification
+	} else if signingSecret != "" {
+		options.SigningSecret = signingSecret
+	}
 
 	if username != "" && password != "" {
-		options := &mindctrl.Options{}
 		options.Username = username
 		options.Password = password
-		return mindctrl.NewTransport(server, name, browser, options)
-	} else {
-		return mindctrl.NewTransport(server, name, browser, nil)
 	}
+
+	if caFile != "" {
+		options.TlsCaFile = caFile
+	}
+
+	if cert != "" && key != "" {
+		options.TlsCertFile = cert
+		options.TlsKeyFile = key
+	}
+
+	if record != "" {
+		options.RecordFile = record
+		options.RecordAppend = true
+	}
+
+	if replay != "" {
+		options.ReplayFile = replay
+		options.ReplayStrict = replayStrict
+	}
+
+	return options
 }
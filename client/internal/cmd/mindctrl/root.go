@@ -1,13 +1,20 @@
 package mindctrl
 
 import (
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/broker"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/browsers"
 	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/downloads"
 	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/fakeserver"
 	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/info"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/keygen"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/options"
 	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/tabs"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/token"
 	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/windows"
 	"github.com/spf13/cobra"
 	"os"
+	"time"
 )
 
 var (
@@ -29,11 +36,24 @@ func init() {
 	RootCommand.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		server, _ := cmd.Flags().GetString("server")
 		browser, _ := cmd.Flags().GetString("browser")
+		record, _ := cmd.Flags().GetString("record")
+		replay, _ := cmd.Flags().GetString("replay")
+		signingKey, _ := cmd.Flags().GetString("signing-key")
+		serverKey, _ := cmd.Flags().GetString("server-key")
+		skipServerVerification, _ := cmd.Flags().GetBool("skip-server-verification")
 
-		if server == "" {
+		if err := options.CheckSigningKeys(cmd, "server-key"); err != nil {
+			return err
+		} else if signingKey != "" && serverKey == "" && skipServerVerification == false {
+			return errors.NewArgumentError("server key required for verifying messages signed with the signing key")
+		} else if record != "" && replay != "" {
+			return errors.NewArgumentError("cannot record and replay at the same time")
+		} else if replay != "" {
+			return nil
+		} else if server == "" {
 			return errors.NewArgumentError("unknown url to intermediate mqtt server")
 		} else if browser == "" {
-			return errors.NewArgumentError("unknown browser name")
+			return options.SelectBrowser(cmd)
 		} else {
 			return nil
 		}
@@ -43,16 +63,44 @@ func init() {
 	BROWSER := os.Getenv("MINDCTRL_BROWSER")
 	USERNAME := os.Getenv("MINDCTRL_USERNAME")
 	PASSWORD := os.Getenv("MINDCTRL_PASSWORD")
+	CA_FILE := os.Getenv("MINDCTRL_CA_FILE")
+	CERT := os.Getenv("MINDCTRL_CERT")
+	KEY := os.Getenv("MINDCTRL_KEY")
+	SIGNING_SECRET := os.Getenv("MINDCTRL_SIGNING_SECRET")
+	SIGNING_KEY := os.Getenv("MINDCTRL_SIGNING_KEY")
+	SERVER_KEY := os.Getenv("MINDCTRL_SERVER_KEY")
+	TOKEN := os.Getenv("MINDCTRL_TOKEN")
 
-	RootCommand.PersistentFlags().StringP("server", "s", SERVER, "url to the intermediate MQTT server")
-	RootCommand.PersistentFlags().StringP("browser", "b", BROWSER, "name for the browser")
+	RootCommand.PersistentFlags().StringP("server", "s", SERVER, "url to the intermediate MQTT server (ws, wss, mqtt, mqtts, tcp or ssl)")
+	RootCommand.PersistentFlags().StringP("browser", "b", BROWSER, "name for the browser; the only live browser on the server if omitted")
 	RootCommand.PersistentFlags().StringP("username", "u", USERNAME, "username for the intermediate MQTT server")
 	RootCommand.PersistentFlags().StringP("password", "p", PASSWORD, "password for the intermediate MQTT server")
+	RootCommand.PersistentFlags().String("ca-file", CA_FILE, "path to the CA bundle for verifying the intermediate MQTT server")
+	RootCommand.PersistentFlags().String("cert", CERT, "path to the client certificate for the intermediate MQTT server")
+	RootCommand.PersistentFlags().String("key", KEY, "path to the private key of the client certificate")
+	RootCommand.PersistentFlags().Duration("timeout", 5*time.Minute, "maximum time to wait for each request to complete; 0 to wait forever")
+	RootCommand.PersistentFlags().String("record", "", "path to the cassette where requests and responses are appended to")
+	RootCommand.PersistentFlags().String("replay", "", "path to the cassette where recorded responses are replayed from, instead of contacting the browser")
+	RootCommand.PersistentFlags().Bool("replay-strict", false, "serve each recorded response at most once and only for exactly matching requests")
+	RootCommand.PersistentFlags().Bool("strict-protocol", false, "refuse to talk to browsers speaking another protocol version")
+	RootCommand.PersistentFlags().String("compression", "", "encoding to compress large requests with (gzip or deflate); requires a recent extension")
+	RootCommand.PersistentFlags().Int("chunk-size", 0, "maximum size of response messages before the browser splits them into chunks; 0 for 256 KiB, negative to disable")
+	RootCommand.PersistentFlags().String("signing-secret", SIGNING_SECRET, "secret shared with the browser for signing messages with HMAC-SHA256")
+	RootCommand.PersistentFlags().String("signing-key", SIGNING_KEY, "Ed25519 private key in base64 for signing messages; overrides the signing secret")
+	RootCommand.PersistentFlags().String("server-key", SERVER_KEY, "Ed25519 public key of the browser in base64 for verifying messages")
+	RootCommand.PersistentFlags().Bool("skip-server-verification", false, "accept messages from the browser unverified when signing with a key but no server key")
+	RootCommand.PersistentFlags().String("token", TOKEN, "capability token permitting the methods this client may call")
 	RootCommand.MarkFlagsRequiredTogether("username", "password")
+	RootCommand.MarkFlagsRequiredTogether("cert", "key")
 
 	RootCommand.SetUsageTemplate(RootCommand.UsageTemplate() + "\n")
+	RootCommand.AddCommand(broker.RootCommand)
+	RootCommand.AddCommand(browsers.RootCommand)
 	RootCommand.AddCommand(downloads.RootCommand)
+	RootCommand.AddCommand(fakeserver.RootCommand)
 	RootCommand.AddCommand(info.RootCommand)
+	RootCommand.AddCommand(keygen.RootCommand)
 	RootCommand.AddCommand(tabs.RootCommand)
+	RootCommand.AddCommand(token.RootCommand)
 	RootCommand.AddCommand(windows.RootCommand)
 }
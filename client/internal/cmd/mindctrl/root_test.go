@@ -0,0 +1,51 @@
+package mindctrl_test
+
+import (
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/options"
+	"github.com/spf13/pflag"
+	"io"
+	"testing"
+)
+
+// Reset the flags of the root command to their defaults when the test
+// finishes, so that tests do not see the flags parsed by others.
+//
+func resetFlags(t *testing.T) {
+	t.Cleanup(func() {
+		mindctrl.RootCommand.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+			flag.Value.Set(flag.DefValue)
+			flag.Changed = false
+		})
+	})
+}
+
+func TestTlsFlags(t *testing.T) {
+	resetFlags(t)
+	args := []string{"--ca-file", "ca.crt", "--cert", "client.crt", "--key", "client.key"}
+
+	if err := mindctrl.RootCommand.ParseFlags(args); err != nil {
+		t.Fatalf("cannot parse flags: %v", err)
+	}
+
+	parsed := options.GetOptions(mindctrl.RootCommand)
+
+	if parsed.TlsCaFile != "ca.crt" {
+		t.Errorf("ca file is %q, expected %q", parsed.TlsCaFile, "ca.crt")
+	}
+
+	if parsed.TlsCertFile != "client.crt" || parsed.TlsKeyFile != "client.key" {
+		t.Errorf("client certificate is %q and %q, expected %q and %q", parsed.TlsCertFile, parsed.TlsKeyFile, "client.crt", "client.key")
+	}
+}
+
+func TestCertFlagRequiresKey(t *testing.T) {
+	resetFlags(t)
+	mindctrl.RootCommand.SetArgs([]string{"--cert", "client.crt", "keygen"})
+	mindctrl.RootCommand.SetOut(io.Discard)
+	mindctrl.RootCommand.SetErr(io.Discard)
+
+	if err := mindctrl.RootCommand.Execute(); err == nil {
+		t.Errorf("command runs with a client certificate but no key")
+	}
+}
@@ -41,6 +41,8 @@ func init() {
 		if transport, err := options.GetTransport(cmd); err != nil {
 			return errors.WrapExecutionError(err, "cannot connect to browser")
 		} else {
+			defer transport.Close()
+
 			operation := mindctrl.FindTabs()
 			stdout := cmd.OutOrStdout()
 			flags := cmd.Flags()
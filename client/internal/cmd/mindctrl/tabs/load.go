@@ -46,6 +46,8 @@ func init() {
 		if transport, err := options.GetTransport(cmd); err != nil {
 			return errors.WrapExecutionError(err, "cannot connect to browser")
 		} else {
+			defer transport.Close()
+
 			tab := 0
 			url := ""
 			operation := mindctrl.LoadTab(tab, url)
@@ -41,6 +41,8 @@ func init() {
 		if transport, err := options.GetTransport(cmd); err != nil {
 			return errors.WrapExecutionError(err, "cannot connect to browser")
 		} else {
+			defer transport.Close()
+
 			tab := 0
 			index := 0
 			operation := mindctrl.MoveTab(tab, index)
@@ -40,6 +40,8 @@ func init() {
 		if transport, err := options.GetTransport(cmd); err != nil {
 			return errors.WrapExecutionError(err, "cannot connect to browser")
 		} else {
+			defer transport.Close()
+
 			tab := 0
 			operation := mindctrl.ReloadTab(tab)
 			stdout := cmd.OutOrStdout()
@@ -36,6 +36,8 @@ func init() {
 		if transport, err := options.GetTransport(cmd); err != nil {
 			return errors.WrapExecutionError(err, "cannot connect to browser")
 		} else {
+			defer transport.Close()
+
 			tab := 0
 			operation := mindctrl.RemoveTab(tab)
 			stdout := cmd.OutOrStdout()
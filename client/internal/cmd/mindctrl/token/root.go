@@ -0,0 +1,86 @@
+package token
+
+import (
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/internal/cmd/mindctrl/errors"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+var (
+	RootCommand = &cobra.Command{
+		Use:   "token",
+		Short: "Issue a capability token for a client",
+		Long:  "Issue a capability token that permits a client to call the given methods only. The token is signed with the Ed25519 private key of the issuer, generated by the keygen command; the browser must be configured with the matching public key as the token key. The token is bound to the Ed25519 public key of the client, and is only honoured in requests signed with the matching private key, so that it cannot be replayed by others who see it on the MQTT server. Patterns like tabs.* match every method with the prefix, and * matches every method. The ping and info.get_capabilities methods are always permitted.",
+
+		DisableAutoGenTag:     true,
+		DisableFlagsInUseLine: true,
+	}
+)
+
+func init() {
+	RootCommand.Flags().String("issuer-key", os.Getenv("MINDCTRL_ISSUER_KEY"), "Ed25519 private key of the issuer in base64")
+	RootCommand.Flags().String("client-key", "", "Ed25519 public key of the client in base64 the token is bound to")
+	RootCommand.Flags().StringSlice("methods", nil, "methods or patterns of methods the token permits")
+	RootCommand.Flags().String("client", "", "name of the client the token is restricted to; any client if omitted")
+	RootCommand.Flags().Duration("expires", 0, "duration after which the token expires; 0 to never expire")
+
+	RootCommand.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		issuerKey, _ := cmd.Flags().GetString("issuer-key")
+		clientKey, _ := cmd.Flags().GetString("client-key")
+		methods, _ := cmd.Flags().GetStringSlice("methods")
+
+		if issuerKey == "" {
+			return errors.NewArgumentError("unknown issuer key")
+		} else if _, err := protocol.DecodePrivateKey(issuerKey); err != nil {
+			return errors.NewArgumentError("invalid issuer key: %s", err)
+		} else if clientKey == "" {
+			return errors.NewArgumentError("unknown client key")
+		} else if _, err := protocol.DecodePublicKey(clientKey); err != nil {
+			return errors.NewArgumentError("invalid client key: %s", err)
+		} else if len(methods) == 0 {
+			return errors.NewArgumentError("no method permitted")
+		} else {
+			return nil
+		}
+	}
+
+	RootCommand.Args = func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.NewExcessArgumentError()
+		} else {
+			return nil
+		}
+	}
+
+	RootCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		issuerKey, _ := flags.GetString("issuer-key")
+		clientKey, _ := flags.GetString("client-key")
+		methods, _ := flags.GetStringSlice("methods")
+		client, _ := flags.GetString("client")
+		expires, _ := flags.GetDuration("expires")
+		key, _ := protocol.DecodePrivateKey(issuerKey)
+		now := time.Now()
+
+		claims := protocol.TokenClaims{
+			Key:      clientKey,
+			Client:   client,
+			Methods:  methods,
+			IssuedAt: now.Unix(),
+		}
+
+		if expires > 0 {
+			claims.Expiry = now.Add(expires).Unix()
+		}
+
+		if token, err := protocol.IssueToken(claims, key); err != nil {
+			return errors.WrapExecutionError(err, "cannot issue token")
+		} else {
+			fmt.Fprintln(cmd.OutOrStdout(), token)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package mindctrltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"strings"
+)
+
+// Register the built-in handlers of the downloads methods. Downloads
+// complete instantly in the fake browser unless the caller asks the
+// method not to wait, in which case they stay in progress until they
+// are cancelled.
+//
+func (server *Server) registerDownloadMethods() {
+	server.register(protocol.FindDownloadsMethod, server.findDownloads)
+	server.register(protocol.GetDownloadMethod, server.getDownload)
+	server.register(protocol.CreateDownloadMethod, server.createDownload)
+	server.register(protocol.PauseDownloadMethod, server.pauseDownload)
+	server.register(protocol.ResumeDownloadMethod, server.resumeDownload)
+	server.register(protocol.CancelDownloadMethod, server.cancelDownload)
+	server.register(protocol.RemoveDownloadMethod, server.removeDownload)
+}
+
+// Handle the downloads.find method.
+//
+func (server *Server) findDownloads(input json.RawMessage) (interface{}, error) {
+	params := protocol.FindDownloadsInput{}
+
+	if err := decode(input, &params); err != nil {
+		return nil, err
+	} else if params.Url != nil && isMatchPattern(*params.Url) == false {
+		return nil, errInvalidInput
+	} else if params.State != nil && *params.State != "in_progress" && *params.State != "interrupted" && *params.State != "complete" {
+		return nil, errInvalidInput
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	output := protocol.FindDownloadsOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: make([]protocol.Download, 0)}
+
+	for _, download := range server.state.downloads {
+		if params.Url != nil && matchUrl(*params.Url, download.Url) == false {
+			continue
+		} else if params.State != nil && download.State != *params.State {
+			continue
+		} else {
+			output.Result = append(output.Result, *download)
+		}
+	}
+
+	return output, nil
+}
+
+// Handle the downloads.get method.
+//
+func (server *Server) getDownload(input json.RawMessage) (interface{}, error) {
+	params := protocol.GetDownloadInput{}
+
+	if err := decode(input, &params, "downloadId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if download, err := server.state.findDownload(params.DownloadId); err != nil {
+		return nil, err
+	} else {
+		return protocol.GetDownloadOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *download}, nil
+	}
+}
+
+// Handle the downloads.create method.
+//
+func (server *Server) createDownload(input json.RawMessage) (interface{}, error) {
+	params := protocol.CreateDownloadInput{}
+
+	if err := decode(input, &params, "url", "filename"); err != nil {
+		return nil, err
+	} else if strings.TrimSpace(params.Url) == "" || strings.TrimSpace(params.Filename) == "" {
+		return nil, errInvalidInput
+	} else if params.Referrer != nil && strings.TrimSpace(*params.Referrer) == "" {
+		return nil, errInvalidInput
+	} else if server.permitsFilename(params.Filename) == false {
+		return nil, newPolicyViolation("filename %s not permitted by policy", params.Filename)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	download := protocol.Download{Url: params.Url, Filename: params.Filename, State: "complete"}
+
+	if params.Referrer != nil {
+		download.Referrer = *params.Referrer
+	}
+
+	if params.NoWait != nil && *params.NoWait {
+		download.State = "in_progress"
+	}
+
+	return protocol.CreateDownloadOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: server.state.addDownload(download)}, nil
+}
+
+// Handle the downloads.pause method.
+//
+func (server *Server) pauseDownload(input json.RawMessage) (interface{}, error) {
+	params := protocol.PauseDownloadInput{}
+
+	if err := decode(input, &params, "downloadId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if download, err := server.state.findDownload(params.DownloadId); err != nil {
+		return nil, err
+	} else if download.State != "in_progress" {
+		return nil, fmt.Errorf("Download must be in progress")
+	} else {
+		download.Paused = true
+		download.CanResume = true
+		return protocol.PauseDownloadOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *download}, nil
+	}
+}
+
+// Handle the downloads.resume method. The download completes on
+// resume unless the caller asks the method not to wait.
+//
+func (server *Server) resumeDownload(input json.RawMessage) (interface{}, error) {
+	params := protocol.ResumeDownloadInput{}
+
+	if err := decode(input, &params, "downloadId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if download, err := server.state.findDownload(params.DownloadId); err != nil {
+		return nil, err
+	} else if download.CanResume == false {
+		return nil, fmt.Errorf("Download cannot be resumed")
+	} else {
+		download.Paused = false
+		download.CanResume = false
+		download.Error = ""
+
+		if params.NoWait != nil && *params.NoWait {
+			download.State = "in_progress"
+		} else {
+			download.State = "complete"
+		}
+
+		return protocol.ResumeDownloadOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *download}, nil
+	}
+}
+
+// Handle the downloads.cancel method.
+//
+func (server *Server) cancelDownload(input json.RawMessage) (interface{}, error) {
+	params := protocol.CancelDownloadInput{}
+
+	if err := decode(input, &params, "downloadId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if download, err := server.state.findDownload(params.DownloadId); err != nil {
+		return nil, err
+	} else {
+		if download.State == "in_progress" {
+			download.State = "interrupted"
+			download.Error = "USER_CANCELED"
+			download.Paused = false
+			download.CanResume = false
+		}
+
+		return protocol.CancelDownloadOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *download}, nil
+	}
+}
+
+// Handle the downloads.remove method.
+//
+func (server *Server) removeDownload(input json.RawMessage) (interface{}, error) {
+	params := protocol.RemoveDownloadInput{}
+
+	if err := decode(input, &params, "downloadId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if _, err := server.state.findDownload(params.DownloadId); err != nil {
+		return nil, err
+	}
+
+	downloads := make([]*protocol.Download, 0, len(server.state.downloads))
+
+	for _, download := range server.state.downloads {
+		if download.Id != params.DownloadId {
+			downloads = append(downloads, download)
+		}
+	}
+
+	server.state.downloads = downloads
+	return protocol.RemoveDownloadOutput{GenericOutput: protocol.GenericOutput{Success: true}}, nil
+}
@@ -0,0 +1,48 @@
+// Package mindctrltest provides a fake Mindctrl web extension for
+// testing code that automates browsers through the mindctrl package.
+//
+// The fake server speaks the same protocol as the web extension: it
+// receives request packets on the server topic, publishes response
+// packets to the client topic (or the response topic given in the
+// request), and maintains the alive and dead messages on the status
+// topic. Instead of controlling a real browser, it keeps tabs,
+// windows and downloads in memory.
+//
+// Basic Usage
+//
+// The [Start] function starts a fake server together with an
+// embedded broker listening on the loopback interface, so that
+// tests are hermetic:
+//
+//	server, err := mindctrltest.Start("browser", nil)
+//	...
+//	defer server.Close()
+//
+//	transport, err := server.NewTransport(nil)
+//	...
+//	defer transport.Close()
+//
+//	tabs, err := mindctrl.FindTabs().Execute(transport)
+//
+// The [NewServer] function connects a fake server to an existing
+// broker instead.
+//
+// Scripting
+//
+// The in-memory state can be prepared before the test with functions
+// like [Server.AddWindow], [Server.AddTab] and [Server.AddDownload],
+// and inspected after the test with functions like [Server.Tabs] and
+// [Server.Requests].
+//
+// Any method can be replaced by a custom handler with [Server.Handle],
+// for example to simulate failures:
+//
+//	server.Handle(protocol.LoadTabMethod, func(input json.RawMessage) interface{} {
+//		return mindctrltest.Failure("execution", "network error")
+//	})
+//
+// Like the web extension, the fake server executes requests
+// concurrently, and reports unknown methods and malformed input with
+// the "dispatch" and "validation" error categories respectively.
+//
+package mindctrltest
@@ -0,0 +1,86 @@
+package mindctrltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"sort"
+)
+
+// Extension version reported by the fake server in the result of
+// info.get_capabilities. The method list in the result reflects the
+// handlers registered at the moment, so methods removed by
+// [Server.Handle] are not reported.
+//
+const ExtensionVersion = "0.0.0-mindctrltest"
+
+// Register the built-in handlers of the info and ping methods.
+//
+func (server *Server) registerInfoMethods() {
+	server.register(protocol.PingMethod, func(input json.RawMessage) (interface{}, error) {
+		return protocol.PingOutput{GenericOutput: protocol.GenericOutput{Success: true}}, nil
+	})
+
+	server.register(protocol.GetBrowserInfoMethod, func(input json.RawMessage) (interface{}, error) {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		return protocol.GetBrowserInfoOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: server.state.browser}, nil
+	})
+
+	server.register(protocol.GetPlatformInfoMethod, func(input json.RawMessage) (interface{}, error) {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		return protocol.GetPlatformInfoOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: server.state.platform}, nil
+	})
+
+	server.register(protocol.GetCapabilitiesMethod, func(input json.RawMessage) (interface{}, error) {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+
+		capabilities := protocol.Capabilities{
+			ExtensionVersion: ExtensionVersion,
+			ProtocolVersion:  protocol.ProtocolVersion,
+			Methods:          make([]string, 0, len(server.handlers)),
+			Encodings:        []string{protocol.GzipEncoding, protocol.DeflateEncoding},
+			Batch:            true,
+		}
+
+		for method := range server.handlers {
+			capabilities.Methods = append(capabilities.Methods, method)
+		}
+
+		sort.Strings(capabilities.Methods)
+		return protocol.GetCapabilitiesOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: capabilities}, nil
+	})
+
+	server.register(protocol.GetPolicyMethod, func(input json.RawMessage) (interface{}, error) {
+		return protocol.GetPolicyOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: server.options.getPolicy()}, nil
+	})
+}
+
+// Register the built-in handlers of the documents methods. Since the
+// fake browser does not load any page, the result of documents.query
+// has to be prepared by [Server.SetQueryResult].
+//
+func (server *Server) registerDocumentMethods() {
+	server.register(protocol.QueryDocumentMethod, func(input json.RawMessage) (interface{}, error) {
+		params := protocol.QueryDocumentInput{}
+
+		if err := decode(input, &params, "tabId", "query"); err != nil {
+			return nil, err
+		}
+
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+
+		if tab, err := server.state.findTab(params.TabId); err != nil {
+			return nil, err
+		} else if server.permitsUrl(tab.Url) == false {
+			return nil, newPolicyViolation("page in tab %d not permitted by policy", params.TabId)
+		} else if result, found := server.state.results[params.TabId]; found == false {
+			return nil, fmt.Errorf("no query result prepared for tab %d", params.TabId)
+		} else {
+			return protocol.QueryDocumentOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: result}, nil
+		}
+	})
+}
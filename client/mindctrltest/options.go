@@ -0,0 +1,172 @@
+package mindctrltest
+
+import (
+	"crypto/ed25519"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"time"
+)
+
+// Options for creating a new fake server.
+//
+// The 'Username' and 'Password' fields contains the credentials
+// used to authenticate with the intermediate MQTT broker. When the
+// server is started with its own broker by [Start], the broker will
+// require the same credentials from clients. By default, they are
+// both empty meaning no authentication is needed.
+//
+// The 'HeartbeatInterval' field contains the interval at which the
+// server republishes its alive message. The default is 60 seconds,
+// which matches the web extension.
+//
+// The 'CompressionThreshold' field contains the minimum size of the
+// outputs compressed for clients that accept compressed outputs. The
+// default is 16 KiB, which matches the web extension.
+//
+// The 'SigningSecret' field contains a secret shared with clients.
+// When it is set, requests must be signed with HMAC-SHA256 and the
+// responses are signed in the same way. Alternatively, the 'SigningKey'
+// field contains the Ed25519 private key of the server for signing the
+// responses, and the 'ClientPublicKey' field contains the Ed25519 public
+// key of the client for verifying the requests; requests are not
+// verified if the latter is not set. The 'SignatureWindow' field
+// contains the maximum clock difference accepted for signed requests,
+// 5 minutes by default. Requests that are unsigned, badly signed,
+// stale or replayed are ignored like the web extension does.
+//
+// The 'TokenKey' field contains the Ed25519 public key of the issuer
+// of capability tokens. When it is set, every request must carry a
+// token issued with the matching private key that permits the method
+// and is bound to the 'ClientPublicKey' field, or the call fails with
+// the "forbidden" error category like the web extension does. Tokens
+// thus require the 'SigningKey' and the 'ClientPublicKey' fields; the
+// server cannot be created without them. By default, tokens are not
+// required.
+//
+// The 'Policy' field contains the sandbox policy of the server, which
+// is reported by info.get_policy. Like the web extension, calls that
+// load, reload or query pages not matching the URL patterns, or
+// download to files outside the download directories, fail with the
+// "policy" error category. By default, nothing is restricted.
+//
+// The 'OnRequest' field contains a function that is invoked whenever
+// the server receives a valid request packet, before the request is
+// handled. It is invoked from the goroutine handling the request.
+//
+type Options struct {
+	Username             string             // username for the intermediate MQTT broker
+	Password             string             // password for the intermediate MQTT broker
+	HeartbeatInterval    time.Duration      // interval of alive messages
+	CompressionThreshold int                // minimum size of outputs to be compressed
+	SigningSecret        string             // secret shared with clients for HMAC signatures
+	SigningKey           ed25519.PrivateKey // private key of the server for Ed25519 signatures
+	ClientPublicKey      ed25519.PublicKey  // public key of the client for Ed25519 signatures
+	SignatureWindow      time.Duration      // maximum clock difference of signed requests
+	TokenKey             ed25519.PublicKey  // public key of the issuer of capability tokens
+	Policy               protocol.Policy    // sandbox policy of the server
+	OnRequest            func(Request)      // hook invoked for each request
+}
+
+func (options *Options) getUsername() string {
+	if options == nil {
+		return ""
+	} else {
+		return options.Username
+	}
+}
+
+func (options *Options) getPassword() string {
+	if options == nil {
+		return ""
+	} else {
+		return options.Password
+	}
+}
+
+func (options *Options) getHeartbeatInterval() time.Duration {
+	if options == nil {
+		return 60 * time.Second
+	} else if options.HeartbeatInterval <= 0 {
+		return 60 * time.Second
+	} else {
+		return options.HeartbeatInterval
+	}
+}
+
+func (options *Options) getCompressionThreshold() int {
+	if options == nil {
+		return 16 * 1024
+	} else if options.CompressionThreshold <= 0 {
+		return 16 * 1024
+	} else {
+		return options.CompressionThreshold
+	}
+}
+
+func (options *Options) getSignatureAlgorithm() string {
+	if options == nil {
+		return ""
+	} else if len(options.SigningKey) > 0 {
+		return protocol.Ed25519Signature
+	} else if options.SigningSecret != "" {
+		return protocol.HmacSha256Signature
+	} else {
+		return ""
+	}
+}
+
+func (options *Options) getSigningKey() []byte {
+	if options == nil {
+		return nil
+	} else if len(options.SigningKey) > 0 {
+		return options.SigningKey
+	} else {
+		return []byte(options.SigningSecret)
+	}
+}
+
+func (options *Options) getVerifyingKey() []byte {
+	if options == nil {
+		return nil
+	} else if len(options.SigningKey) > 0 {
+		return options.ClientPublicKey
+	} else {
+		return []byte(options.SigningSecret)
+	}
+}
+
+func (options *Options) getSignatureWindow() time.Duration {
+	if options == nil {
+		return 5 * time.Minute
+	} else if options.SignatureWindow <= 0 {
+		return 5 * time.Minute
+	} else {
+		return options.SignatureWindow
+	}
+}
+
+func (options *Options) getTokenKey() ed25519.PublicKey {
+	if options == nil {
+		return nil
+	} else {
+		return options.TokenKey
+	}
+}
+
+func (options *Options) getPolicy() protocol.Policy {
+	policy := protocol.Policy{UrlPatterns: []string{}, DownloadDirectories: []string{}}
+
+	if options != nil {
+		policy.UrlPatterns = append(policy.UrlPatterns, options.Policy.UrlPatterns...)
+		policy.DownloadDirectories = append(policy.DownloadDirectories, options.Policy.DownloadDirectories...)
+	}
+
+	return policy
+}
+
+func (options *Options) getOnRequest() func(Request) {
+	if options == nil {
+		return nil
+	} else {
+		return options.OnRequest
+	}
+}
@@ -0,0 +1,93 @@
+package mindctrltest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Regular expression used for parsing match patterns. Match patterns
+// are text patterns web extensions use to match an URL. The syntax
+// is described in:
+//
+//   - https://developer.chrome.com/docs/extensions/mv3/match_patterns/
+//   - https://developer.mozilla.org/en-US/docs/Mozilla/Add-ons/WebExtensions/Match_patterns
+//
+// Like the web extension, the special <all_urls> value is NOT
+// supported.
+//
+var matchPatternExtractor = regexp.MustCompile(`(?i)^(http|https|ws|wss|ftp|data|file|\*)://((?:(?:\*|[A-Za-z0-9\x2d]+)(?:\x2e[A-Za-z0-9\x2d]+)*)?)(/[^?]*)(\?.*)?$`)
+
+// Characters that need not be escaped in the regular expression
+// generated from a match pattern.
+//
+const regexpWhitelist = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_#&%@"
+
+// Check if the given string is a valid match pattern. The pattern
+// should follow the required syntax, and only the file scheme may
+// have an empty host.
+//
+func isMatchPattern(pattern string) bool {
+	if parts := matchPatternExtractor.FindStringSubmatch(pattern); parts == nil {
+		return false
+	} else if parts[2] == "" && parts[1] != "file" {
+		return false
+	} else {
+		return true
+	}
+}
+
+// Convert a match pattern to the corresponding regular expression.
+// The conversion mirrors the one done by the web extension.
+//
+func compileMatchPattern(pattern string) (*regexp.Regexp, error) {
+	parts := matchPatternExtractor.FindStringSubmatch(pattern)
+
+	if parts == nil {
+		return nil, fmt.Errorf("invalid match pattern")
+	}
+
+	scheme, host, path, query := parts[1], parts[2], parts[3], parts[4]
+	output := strings.Builder{}
+	output.WriteString("^")
+
+	if scheme != "*" {
+		output.WriteString(scheme)
+		output.WriteString(`\x3a\x2f\x2f`)
+	} else {
+		output.WriteString("(http|https)")
+		output.WriteString(`\x3a\x2f\x2f`)
+	}
+
+	if host == "*" {
+		output.WriteString(`[A-Za-z0-9_\x2d\x2e]+`)
+	} else if strings.HasPrefix(host, "*.") {
+		output.WriteString(`[A-Za-z0-9_\x2d\x2e]+`)
+		output.WriteString(`\x2e`)
+		convertWildcardStringInto(host[2:], "", &output)
+	} else if host != "" {
+		convertWildcardStringInto(host, "", &output)
+	}
+
+	convertWildcardStringInto(path, `[^\?]*`, &output)
+	convertWildcardStringInto(query, ".*", &output)
+	output.WriteString("$")
+
+	return regexp.Compile(output.String())
+}
+
+// Translate a wildcard string to the corresponding regular expression
+// fragment. Asterisks are replaced by the given substitute unless it
+// is empty, in which case the string is treated as a literal.
+//
+func convertWildcardStringInto(wildcard string, subst string, output *strings.Builder) {
+	for _, char := range wildcard {
+		if char == '*' && subst != "" {
+			output.WriteString(subst)
+		} else if strings.ContainsRune(regexpWhitelist, char) {
+			output.WriteRune(char)
+		} else {
+			fmt.Fprintf(output, `\x{%x}`, char)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package mindctrltest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error returned by built-in handlers when the call violates the
+// sandbox policy of the server. It is reported to the client with the
+// "policy" error category.
+//
+type policyViolation struct {
+	message string
+}
+
+func newPolicyViolation(format string, args ...interface{}) *policyViolation {
+	return &policyViolation{message: fmt.Sprintf(format, args...)}
+}
+
+func (err *policyViolation) Error() string {
+	return err.message
+}
+
+// Check if the sandbox policy permits the given URL. Like the web
+// extension, blank pages are always permitted, and invalid patterns
+// never match anything.
+//
+func (server *Server) permitsUrl(url string) bool {
+	policy := server.options.getPolicy()
+
+	if len(policy.UrlPatterns) == 0 || url == "about:blank" {
+		return true
+	}
+
+	for _, pattern := range policy.UrlPatterns {
+		if isMatchPattern(pattern) && matchUrl(pattern, url) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if the sandbox policy permits the given download filename.
+// Like the web extension, filenames escaping the download folder with
+// ".." are never permitted.
+//
+func (server *Server) permitsFilename(filename string) bool {
+	policy := server.options.getPolicy()
+	normalized := normalizePath(filename)
+
+	for _, segment := range strings.FieldsFunc(filename, isPathSeparator) {
+		if segment == ".." {
+			return false
+		}
+	}
+
+	restricted := false
+
+	for _, directory := range policy.DownloadDirectories {
+		if directory := normalizePath(directory); directory == "" {
+			continue
+		} else if strings.HasPrefix(normalized, directory+"/") {
+			return true
+		} else {
+			restricted = true
+		}
+	}
+
+	return restricted == false
+}
+
+// Normalize the given relative path by unifying the separators and
+// removing empty and "." segments.
+//
+func normalizePath(path string) string {
+	segments := make([]string, 0)
+
+	for _, segment := range strings.FieldsFunc(strings.TrimSpace(path), isPathSeparator) {
+		if segment != "." {
+			segments = append(segments, segment)
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func isPathSeparator(char rune) bool {
+	return char == '/' || char == '\\'
+}
@@ -0,0 +1,602 @@
+package mindctrltest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/broker"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Handler executes a method on the fake server. It receives the raw
+// input of the call and returns the output of the call, which will
+// be encoded as the result in the response packet. Like the outputs
+// in the protocol package, the output should contain the fields in
+// [protocol.GenericOutput].
+//
+type Handler func(input json.RawMessage) interface{}
+
+// Request records a request received by the fake server.
+//
+type Request struct {
+	Method  string          // method to be called
+	Client  string          // client who makes the call
+	Input   json.RawMessage // input of the call
+	Batched bool            // whether the call arrives in a batch packet
+}
+
+// Server is a fake Mindctrl web extension. The server is safe for
+// concurrent use by multiple goroutines.
+//
+// The in-memory state, the handlers and the recorded requests are
+// protected by the mutex. Handlers are executed without the mutex
+// held; the built-in handlers acquire it themselves.
+//
+type Server struct {
+	url       string
+	name      string
+	options   *Options
+	broker    *broker.Broker
+	mqtt      *paho.Client
+	mutex     sync.Mutex
+	handlers  map[string]Handler
+	requests  []Request
+	state     state
+	clients   uint64
+	heartbeat *time.Ticker
+	guard     *protocol.ReplayGuard
+	done      chan struct{}
+	closed    bool
+}
+
+// Start a fake server with the given name, together with an embedded
+// broker that accepts websocket connections on a random port of the
+// loopback interface. The url of the broker can be retrieved with
+// [Server.Url]. Both the server and the broker are stopped when the
+// server is closed.
+//
+func Start(name string, options *Options) (*Server, error) {
+	b := broker.NewBroker(&broker.Options{
+		Username: options.getUsername(),
+		Password: options.getPassword(),
+	})
+
+	if listener, err := net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return nil, err
+	} else {
+		go b.ServeWebsocket(listener)
+
+		if server, err := newServer(fmt.Sprintf("ws://%s", listener.Addr()), name, options, b); err != nil {
+			b.Close()
+			return nil, err
+		} else {
+			return server, nil
+		}
+	}
+}
+
+// Create a fake server with the given name and connect it to the
+// intermediate MQTT broker at the given url.
+//
+func NewServer(url string, name string, options *Options) (*Server, error) {
+	return newServer(url, name, options, nil)
+}
+
+// Create a fake server and connect it to the broker. The broker is
+// owned by the server if it is given.
+//
+func newServer(url string, name string, options *Options, b *broker.Broker) (*Server, error) {
+	if options.getTokenKey() != nil && (options.getSignatureAlgorithm() != protocol.Ed25519Signature || len(options.getVerifyingKey()) == 0) {
+		return nil, errors.New("capability tokens require ed25519 signing keys")
+	}
+
+	server := &Server{
+		url:      url,
+		name:     name,
+		options:  options,
+		broker:   b,
+		handlers: make(map[string]Handler),
+		requests: make([]Request, 0),
+		state:    newState(),
+		guard:    protocol.NewReplayGuard(options.getSignatureWindow()),
+		done:     make(chan struct{}),
+	}
+
+	server.registerInfoMethods()
+	server.registerDocumentMethods()
+	server.registerDownloadMethods()
+	server.registerTabMethods()
+	server.registerWindowMethods()
+
+	if err := server.connect(); err != nil {
+		return nil, err
+	} else {
+		return server, nil
+	}
+}
+
+// Connect to the broker, subscribe to the server topic, publish the
+// alive message and start the heartbeat.
+//
+func (server *Server) connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	connection, err := codec.Dial(ctx, server.url, nil)
+
+	if err != nil {
+		return err
+	}
+
+	server.mqtt = paho.NewClient(paho.ClientConfig{
+		Conn: connection,
+		Router: paho.NewSingleHandlerRouter(func(m *paho.Publish) {
+			go server.receive(m)
+		}),
+	})
+
+	connectPacket := &paho.Connect{
+		KeepAlive:  30,
+		ClientID:   protocol.GenerateMqttClientId(server.name),
+		CleanStart: true,
+		WillMessage: &paho.WillMessage{
+			Topic:   protocol.GetServerStatusTopic(server.name),
+			Payload: []byte("dead"),
+			Retain:  true,
+		},
+	}
+
+	if username, password := server.options.getUsername(), server.options.getPassword(); username != "" || password != "" {
+		connectPacket.UsernameFlag = true
+		connectPacket.PasswordFlag = true
+		connectPacket.Username = username
+		connectPacket.Password = []byte(password)
+	}
+
+	subscribePacket := &paho.Subscribe{
+		Subscriptions: map[string]paho.SubscribeOptions{
+			protocol.GetServerRpcTopic(server.name): {QoS: 2},
+		},
+	}
+
+	if ack, err := server.mqtt.Connect(ctx, connectPacket); err != nil {
+		connection.Close()
+		return err
+	} else if ack.ReasonCode != 0 {
+		connection.Close()
+		return fmt.Errorf("connection refused with reason code %d", ack.ReasonCode)
+	} else if _, err := server.mqtt.Subscribe(ctx, subscribePacket); err != nil {
+		server.mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
+		return err
+	} else if err := server.publishStatus("alive"); err != nil {
+		server.mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
+		return err
+	} else {
+		server.heartbeat = time.NewTicker(server.options.getHeartbeatInterval())
+		go server.beat()
+		return nil
+	}
+}
+
+// Republish the alive message periodically until the server is
+// closed.
+//
+func (server *Server) beat() {
+	for {
+		select {
+		case <-server.heartbeat.C:
+			server.publishStatus("alive")
+		case <-server.done:
+			return
+		}
+	}
+}
+
+// Publish the given status message to the status topic. Like the
+// web extension, the time of the message is attached as a user
+// property. The message is published with QoS 1, so that the broker
+// has retained the alive message by the time the server is started
+// and clients connecting afterwards never miss it.
+//
+func (server *Server) publishStatus(status string) error {
+	_, err := server.mqtt.Publish(context.Background(), &paho.Publish{
+		Topic:   protocol.GetServerStatusTopic(server.name),
+		QoS:     1,
+		Payload: []byte(status),
+		Retain:  true,
+		Properties: &paho.PublishProperties{
+			User: paho.UserProperties{{Key: protocol.StatusTimeProperty, Value: time.Now().UTC().Format(time.RFC3339Nano)}},
+		},
+	})
+
+	return err
+}
+
+// Return the url of the intermediate MQTT broker the server is
+// connected to.
+//
+func (server *Server) Url() string {
+	return server.url
+}
+
+// Return the name of the server.
+//
+func (server *Server) Name() string {
+	return server.name
+}
+
+// Create a new transport connected to the server. The credentials
+// of the server are used unless the given options specify their own,
+// and so is the shared secret for signing if the options specify no
+// key for signing at all. The interceptors are passed to [mindctrl.NewTransport] as is.
+//
+func (server *Server) NewTransport(options *mindctrl.Options, interceptors ...mindctrl.Interceptor) (*mindctrl.Transport, error) {
+	merged := &mindctrl.Options{}
+
+	if options != nil {
+		*merged = *options
+	}
+
+	if merged.Username == "" && merged.Password == "" {
+		merged.Username = server.options.getUsername()
+		merged.Password = server.options.getPassword()
+	}
+
+	if merged.SigningSecret == "" && len(merged.SigningKey) == 0 && server.options.getSignatureAlgorithm() == protocol.HmacSha256Signature {
+		merged.SigningSecret = string(server.options.getSigningKey())
+	}
+
+	client := fmt.Sprintf("mindctrltest_%d", atomic.AddUint64(&server.clients, 1))
+	return mindctrl.NewTransport(server.url, client, server.name, merged, interceptors...)
+}
+
+// Replace the handler of the given method. A nil handler removes
+// the method, so that calls to it fail with the "dispatch" error
+// category like calls to unknown methods.
+//
+func (server *Server) Handle(method string, handler Handler) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if handler == nil {
+		delete(server.handlers, method)
+	} else {
+		server.handlers[method] = handler
+	}
+}
+
+// Return the requests received by the server thus far, in the order
+// they are received.
+//
+func (server *Server) Requests() []Request {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	requests := make([]Request, len(server.requests))
+	copy(requests, server.requests)
+	return requests
+}
+
+// Close the server. The server publishes the dead message to its
+// status topic before disconnecting from the broker, like the web
+// extension does when it stops. If the server is started with its
+// own broker, the broker is closed as well.
+//
+func (server *Server) Close() error {
+	server.mutex.Lock()
+
+	if server.closed {
+		server.mutex.Unlock()
+		return nil
+	}
+
+	server.closed = true
+	server.mutex.Unlock()
+
+	close(server.done)
+	server.heartbeat.Stop()
+	server.publishStatus("dead")
+	err := server.mqtt.Disconnect(&paho.Disconnect{ReasonCode: 0})
+
+	if server.broker != nil {
+		server.broker.Close()
+	}
+
+	return err
+}
+
+// Process a message received from the broker. The message carries
+// either a single request packet or a batch packet; the requests in
+// a batch packet are executed concurrently, and each of them is replied
+// with its own response that carries the request id as correlation
+// data. Messages that are not valid request packets, as well as
+// invalid entries in batch packets, are ignored like the web
+// extension does. So are messages that fail the signature check when
+// signing is enabled.
+//
+func (server *Server) receive(received *paho.Publish) {
+	batch := []json.RawMessage{}
+	payload, ok := server.verify(received.Payload)
+
+	if ok == false {
+		return
+	} else if err := json.Unmarshal(payload, &batch); err != nil {
+		server.serve(received, payload, false)
+	} else {
+		group := sync.WaitGroup{}
+
+		for _, payload := range batch {
+			group.Add(1)
+
+			go func(payload json.RawMessage) {
+				defer group.Done()
+				server.serve(received, payload, true)
+			}(payload)
+		}
+
+		group.Wait()
+	}
+}
+
+// Execute the given request packet and publish the response.
+//
+func (server *Server) serve(received *paho.Publish, payload []byte, batched bool) {
+	input := json.RawMessage{}
+	packet := protocol.RequestPacket{Input: &input}
+
+	if err := json.Unmarshal(payload, &packet); err != nil {
+		return
+	} else if packet.Type != "request" || packet.Id == "" || packet.Method == "" || packet.Client == "" {
+		return
+	} else if packet.Server != server.name || len(input) == 0 {
+		return
+	}
+
+	if packet.Encoding != "" {
+		if decompressed, err := protocol.DecompressBody(packet.Encoding, input); err != nil {
+			server.respond(received, &packet, batched, Failure("validation", fmt.Sprintf("invalid input for method %s", packet.Method)))
+			return
+		} else {
+			input = decompressed
+		}
+	}
+
+	if key := server.options.getTokenKey(); key != nil && isUnrestricted(packet.Method) == false {
+		if packet.Token == "" {
+			server.respond(received, &packet, batched, Failure("forbidden", "capability token required"))
+			return
+		} else if claims, err := protocol.VerifyToken(packet.Token, key); err != nil {
+			server.respond(received, &packet, batched, Failure("forbidden", err.Error()))
+			return
+		} else if claims.Binds(server.options.getVerifyingKey()) == false {
+			server.respond(received, &packet, batched, Failure("forbidden", "capability token not bound to the signing key"))
+			return
+		} else if claims.Permits(packet.Client, packet.Method, time.Now()) == false {
+			server.respond(received, &packet, batched, Failure("forbidden", fmt.Sprintf("method %s not permitted by capability token", packet.Method)))
+			return
+		}
+	}
+
+	request := Request{Method: packet.Method, Client: packet.Client, Input: input, Batched: batched}
+
+	if hook := server.options.getOnRequest(); hook != nil {
+		hook(request)
+	}
+
+	server.mutex.Lock()
+	server.requests = append(server.requests, request)
+	handler := server.handlers[packet.Method]
+	server.mutex.Unlock()
+
+	var output interface{}
+
+	if handler == nil {
+		output = Failure("dispatch", fmt.Sprintf("unknown method %s", packet.Method))
+	} else {
+		output = handler(input)
+	}
+
+	if encoded, err := json.Marshal(output); err != nil {
+		server.respond(received, &packet, batched, Failure("internal", fmt.Sprintf("unexpected exception thrown when calling method %s", packet.Method)))
+	} else {
+		server.respond(received, &packet, batched, json.RawMessage(encoded))
+	}
+}
+
+// Publish the response of the given request. The response is routed
+// in the same way as the web extension does: to the response topic
+// of the request if one is given, or to the client topic otherwise.
+// The correlation data is copied from the message, or the request id
+// for requests in batch packets. Large outputs are compressed when the
+// request accepts a supported encoding, and responses larger than the
+// chunk size of the request are split into chunk packets. Every
+// message is signed when signing is enabled.
+//
+func (server *Server) respond(received *paho.Publish, request *protocol.RequestPacket, batched bool, output interface{}) {
+	encoded, _ := json.Marshal(output)
+
+	packet := &protocol.ResponsePacket{
+		Type:   "response",
+		Id:     request.Id,
+		Method: request.Method,
+		Client: request.Client,
+		Server: request.Server,
+		Output: encoded,
+	}
+
+	if encoding := getAcceptedEncoding(request.Accept); encoding != "" && len(encoded) >= server.options.getCompressionThreshold() {
+		if compressed, err := protocol.CompressBody(encoding, encoded); err == nil {
+			packet.Output = compressed
+			packet.Encoding = encoding
+		}
+	}
+
+	mPacket, err := json.Marshal(packet)
+
+	if err != nil {
+		return
+	}
+
+	topic := protocol.GetClientTopic(request.Client)
+	properties := (*paho.PublishProperties)(nil)
+
+	if received.Properties != nil && received.Properties.ResponseTopic != "" {
+		topic = received.Properties.ResponseTopic
+		properties = &paho.PublishProperties{CorrelationData: received.Properties.CorrelationData}
+
+		if batched {
+			properties.CorrelationData = []byte(request.Id)
+		}
+	}
+
+	measure := func(message []byte) int {
+		if signed, err := server.sign(message); err != nil {
+			return len(message) + getPublishOverhead(topic, properties)
+		} else {
+			return len(signed) + getPublishOverhead(topic, properties)
+		}
+	}
+
+	messages := [][]byte{mPacket}
+
+	if chunks := protocol.SplitResponse(packet, mPacket, request.ChunkSize, measure); chunks != nil {
+		messages = messages[:0]
+
+		for _, chunk := range chunks {
+			mChunk, _ := json.Marshal(chunk)
+			messages = append(messages, mChunk)
+		}
+	}
+
+	for _, message := range messages {
+		if signed, err := server.sign(message); err != nil {
+			return
+		} else {
+			server.mqtt.Publish(context.Background(), &paho.Publish{
+				Topic:      topic,
+				QoS:        received.QoS,
+				Payload:    signed,
+				Properties: properties,
+			})
+		}
+	}
+}
+
+// Sign the given message when signing is enabled, or return it as is
+// otherwise.
+//
+func (server *Server) sign(message []byte) ([]byte, error) {
+	if algorithm := server.options.getSignatureAlgorithm(); algorithm != "" {
+		return protocol.SignMessage(message, algorithm, server.options.getSigningKey())
+	} else {
+		return message, nil
+	}
+}
+
+// Return the room taken by a PUBLISH packet to the given topic with
+// the given properties besides the message, which is the fixed header,
+// the packet identifier, the property length and the length prefixes
+// of the topic and the correlation data, together with the topic and
+// the correlation data themselves.
+//
+func getPublishOverhead(topic string, properties *paho.PublishProperties) int {
+	if properties != nil {
+		return 16 + len(topic) + len(properties.CorrelationData)
+	} else {
+		return 16 + len(topic)
+	}
+}
+
+// Verify the signature of the given message and return the message
+// wrapped inside, like [codec.Codec] does for responses.
+//
+func (server *Server) verify(message []byte) ([]byte, bool) {
+	algorithm := server.options.getSignatureAlgorithm()
+	key := server.options.getVerifyingKey()
+	packet, signed := protocol.OpenMessage(message)
+
+	if algorithm == "" || len(key) == 0 {
+		if signed {
+			return []byte(packet.Payload), true
+		} else {
+			return message, true
+		}
+	} else if signed == false {
+		return nil, false
+	} else if packet.Verify(algorithm, key) == false {
+		return nil, false
+	} else if server.guard.Check(packet, time.Now()) == false {
+		return nil, false
+	} else {
+		return []byte(packet.Payload), true
+	}
+}
+
+// Return the first supported encoding in the given accept field of
+// a request packet, or an empty string if there is none.
+//
+func getAcceptedEncoding(accept string) string {
+	for _, candidate := range strings.Split(accept, ",") {
+		if encoding := strings.TrimSpace(candidate); encoding == protocol.GzipEncoding || encoding == protocol.DeflateEncoding {
+			return encoding
+		}
+	}
+
+	return ""
+}
+
+// Return whether the given method can be called without a capability
+// token.
+//
+func isUnrestricted(method string) bool {
+	for _, unrestricted := range protocol.UnrestrictedMethods {
+		if method == unrestricted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return the output of a failed call with the given error category
+// and message. The categories used by the web extension are:
+//
+//   - "dispatch" for calls to unknown methods
+//   - "validation" for calls with invalid input
+//   - "internal" for unexpected failures in the extension
+//   - "execution" for failures reported by the browser
+//   - "forbidden" for calls not permitted by the capability token
+//   - "policy" for calls violating the sandbox policy
+//
+func Failure(category string, message string) protocol.GenericOutput {
+	return protocol.GenericOutput{Success: false, Category: category, Message: message}
+}
+
+// Register the built-in handler of the given method. The input is
+// decoded into a new instance of the input type, and the call fails
+// with the "validation" error category if the input cannot be
+// decoded, or the "policy" error category if the call violates the
+// sandbox policy.
+//
+func (server *Server) register(method string, handler func(input json.RawMessage) (interface{}, error)) {
+	server.handlers[method] = func(input json.RawMessage) interface{} {
+		if output, err := handler(input); err == errInvalidInput {
+			return Failure("validation", fmt.Sprintf("invalid input for method %s", method))
+		} else if violation, ok := err.(*policyViolation); ok {
+			return Failure("policy", violation.message)
+		} else if err != nil {
+			return Failure("execution", err.Error())
+		} else {
+			return output
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package mindctrltest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"testing"
+)
+
+// Start a fake server with the given options and connect a new
+// transport to it. Both are closed when the test finishes.
+//
+func start(t *testing.T, options *mindctrltest.Options) (*mindctrltest.Server, *mindctrl.Transport) {
+	t.Helper()
+
+	server, err := mindctrltest.Start("test", options)
+
+	if err != nil {
+		t.Fatalf("cannot start server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	transport, err := server.NewTransport(nil)
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+	return server, transport
+}
+
+func TestScriptedTabs(t *testing.T) {
+	server, transport := start(t, nil)
+	window := server.AddWindow(protocol.Window{Tabs: []protocol.Tab{
+		{Url: "https://example.com/first"},
+		{Url: "https://example.org/second"},
+	}})
+
+	tabs, err := mindctrl.FindTabs().SetWindowId(true, window.Id).SetUrl(true, "*://example.com/*").Execute(transport)
+
+	if err != nil {
+		t.Fatalf("cannot find tabs: %v", err)
+	} else if len(tabs) != 1 || tabs[0].Url != "https://example.com/first" {
+		t.Fatalf("found %+v, expected the first tab only", tabs)
+	}
+
+	if tab, err := mindctrl.LoadTab(tabs[0].Id, "https://example.net/").Execute(transport); err != nil {
+		t.Fatalf("cannot load tab: %v", err)
+	} else if tab.Url != "https://example.net/" {
+		t.Errorf("loaded tab has url %s, expected https://example.net/", tab.Url)
+	}
+
+	for _, tab := range server.Tabs() {
+		if tab.Id == tabs[0].Id && tab.Url != "https://example.net/" {
+			t.Errorf("server keeps url %s after load, expected https://example.net/", tab.Url)
+		}
+	}
+
+	requests := server.Requests()
+
+	if len(requests) != 2 || requests[0].Method != protocol.FindTabsMethod || requests[1].Method != protocol.LoadTabMethod {
+		t.Errorf("server records %+v, expected tabs.find and tabs.load", requests)
+	}
+}
+
+func TestScriptedDownloads(t *testing.T) {
+	server, transport := start(t, nil)
+
+	if download, err := mindctrl.CreateDownload("https://example.com/file.zip", "file.zip").Execute(transport); err != nil {
+		t.Fatalf("cannot create download: %v", err)
+	} else if download.State != "complete" {
+		t.Errorf("download in state %s, expected complete", download.State)
+	}
+
+	if downloads := server.Downloads(); len(downloads) != 1 || downloads[0].Filename != "file.zip" {
+		t.Errorf("server keeps downloads %+v, expected file.zip", downloads)
+	}
+}
+
+func TestCustomHandler(t *testing.T) {
+	server, transport := start(t, nil)
+
+	server.Handle(protocol.LoadTabMethod, func(input json.RawMessage) interface{} {
+		return mindctrltest.Failure("execution", "network error")
+	})
+
+	if _, err := mindctrl.LoadTab(1, "https://example.com/").Execute(transport); errors.Is(err, mindctrl.ErrExecution) == false {
+		t.Errorf("load fails with %v, expected %v", err, mindctrl.ErrExecution)
+	}
+
+	server.Handle(protocol.LoadTabMethod, nil)
+
+	if _, err := mindctrl.LoadTab(1, "https://example.com/").Execute(transport); errors.Is(err, mindctrl.ErrUnknownMethod) == false {
+		t.Errorf("load fails with %v, expected %v", err, mindctrl.ErrUnknownMethod)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	server, transport := start(t, &mindctrltest.Options{Policy: protocol.Policy{
+		UrlPatterns:         []string{"*://example.com/*"},
+		DownloadDirectories: []string{"downloads"},
+	}})
+
+	if _, err := mindctrl.LoadTab(1, "https://example.com/").Execute(transport); err != nil {
+		t.Errorf("cannot load permitted page: %v", err)
+	}
+
+	if _, err := mindctrl.LoadTab(1, "https://example.org/").Execute(transport); errors.Is(err, mindctrl.ErrPolicyViolation) == false {
+		t.Errorf("load fails with %v, expected %v", err, mindctrl.ErrPolicyViolation)
+	}
+
+	if _, err := mindctrl.CreateDownload("https://example.com/file.zip", "downloads/../file.zip").Execute(transport); errors.Is(err, mindctrl.ErrPolicyViolation) == false {
+		t.Errorf("download fails with %v, expected %v", err, mindctrl.ErrPolicyViolation)
+	}
+
+	// Pages already in the tabs, like those the user navigated to, are
+	// checked before reloading.
+
+	window := server.AddWindow(protocol.Window{Tabs: []protocol.Tab{
+		{Url: "https://example.com/first"},
+		{Url: "https://example.org/second"},
+	}})
+
+	if _, err := mindctrl.ReloadTab(window.Tabs[0].Id).Execute(transport); err != nil {
+		t.Errorf("cannot reload permitted page: %v", err)
+	}
+
+	if _, err := mindctrl.ReloadTab(window.Tabs[1].Id).Execute(transport); errors.Is(err, mindctrl.ErrPolicyViolation) == false {
+		t.Errorf("reload fails with %v, expected %v", err, mindctrl.ErrPolicyViolation)
+	}
+}
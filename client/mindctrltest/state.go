@@ -0,0 +1,508 @@
+package mindctrltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"runtime"
+	"time"
+)
+
+// Error returned by built-in handlers when the input of the call is
+// not acceptable. It is reported to the client with the "validation"
+// error category.
+//
+var errInvalidInput = errors.New("invalid input")
+
+// In-memory state of the fake browser. Tabs are kept in a single
+// list ordered by their position in their windows; the tab list
+// of each window is assembled on demand.
+//
+type state struct {
+	browser      protocol.BrowserInfo
+	platform     protocol.PlatformInfo
+	windows      []*protocol.Window
+	tabs         []*protocol.Tab
+	downloads    []*protocol.Download
+	results      map[int]interface{}
+	lastWindow   int
+	lastTab      int
+	lastDownload int
+}
+
+// Create the initial state of the fake browser, which contains a
+// single focused window with a single blank tab.
+//
+func newState() state {
+	s := state{
+		browser:   protocol.BrowserInfo{Name: "Fake", Version: "1.0"},
+		platform:  protocol.PlatformInfo{Os: runtime.GOOS, Arch: runtime.GOARCH},
+		windows:   make([]*protocol.Window, 0),
+		tabs:      make([]*protocol.Tab, 0),
+		downloads: make([]*protocol.Download, 0),
+		results:   make(map[int]interface{}),
+	}
+
+	s.addWindow(protocol.Window{Focused: true})
+	return s
+}
+
+// Add a window to the state. A blank tab is added to the window if
+// it does not come with any tab, since a browser window always has
+// at least one tab.
+//
+func (s *state) addWindow(window protocol.Window) protocol.Window {
+	s.lastWindow++
+
+	tabs := window.Tabs
+	added := window
+	added.Id = s.lastWindow
+	added.Tabs = nil
+
+	if added.Type == "" {
+		added.Type = "normal"
+	}
+
+	if added.State == "" {
+		added.State = "normal"
+	}
+
+	if added.Width == 0 && added.Height == 0 {
+		added.Width = 1280
+		added.Height = 720
+	}
+
+	if added.Focused {
+		for _, other := range s.windows {
+			other.Focused = false
+		}
+	}
+
+	s.windows = append(s.windows, &added)
+
+	if len(tabs) == 0 {
+		s.addTab(protocol.Tab{WindowId: added.Id, Url: "about:blank", Active: true})
+	} else {
+		for _, tab := range tabs {
+			tab.WindowId = added.Id
+			s.addTab(tab)
+		}
+	}
+
+	return s.window(added.Id)
+}
+
+// Add a tab to the end of its window. The tab is added to the current
+// window if it does not specify one. The tab is activated if it is
+// the only tab in the window.
+//
+func (s *state) addTab(tab protocol.Tab) protocol.Tab {
+	s.lastTab++
+
+	added := tab
+	added.Id = s.lastTab
+
+	if added.WindowId == 0 {
+		if current := s.currentWindow(); current != nil {
+			added.WindowId = current.Id
+		}
+	}
+
+	if added.Url == "" {
+		added.Url = "about:blank"
+	}
+
+	if added.Status == "" {
+		added.Status = "complete"
+	}
+
+	if added.Width == 0 && added.Height == 0 {
+		added.Width = 1280
+		added.Height = 720
+	}
+
+	if len(s.tabsOf(added.WindowId)) == 0 {
+		added.Active = true
+	}
+
+	s.tabs = append(s.tabs, &added)
+
+	if added.Active {
+		s.activate(&added)
+	}
+
+	s.reindex()
+	return added
+}
+
+// Add a download to the state.
+//
+func (s *state) addDownload(download protocol.Download) protocol.Download {
+	s.lastDownload++
+
+	added := download
+	added.Id = s.lastDownload
+
+	if added.State == "" {
+		added.State = "complete"
+	}
+
+	if added.StartTime == "" {
+		added.StartTime = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	s.downloads = append(s.downloads, &added)
+	return added
+}
+
+// Return the window with the given id, together with its tabs. An
+// empty window with zero id is returned if the window does not
+// exist.
+//
+func (s *state) window(windowId int) protocol.Window {
+	for _, window := range s.windows {
+		if window.Id == windowId {
+			output := *window
+			output.Tabs = make([]protocol.Tab, 0)
+
+			for _, tab := range s.tabsOf(windowId) {
+				output.Tabs = append(output.Tabs, *tab)
+			}
+
+			return output
+		}
+	}
+
+	return protocol.Window{}
+}
+
+// Find the window with the given id.
+//
+func (s *state) findWindow(windowId int) (*protocol.Window, error) {
+	for _, window := range s.windows {
+		if window.Id == windowId {
+			return window, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Invalid window ID: %d", windowId)
+}
+
+// Find the tab with the given id.
+//
+func (s *state) findTab(tabId int) (*protocol.Tab, error) {
+	for _, tab := range s.tabs {
+		if tab.Id == tabId {
+			return tab, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Invalid tab ID: %d", tabId)
+}
+
+// Find the download with the given id.
+//
+func (s *state) findDownload(downloadId int) (*protocol.Download, error) {
+	for _, download := range s.downloads {
+		if download.Id == downloadId {
+			return download, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown download id %d", downloadId)
+}
+
+// Return the current window, which is the focused window, or the
+// first window if no window is focused.
+//
+func (s *state) currentWindow() *protocol.Window {
+	for _, window := range s.windows {
+		if window.Focused {
+			return window
+		}
+	}
+
+	if len(s.windows) > 0 {
+		return s.windows[0]
+	} else {
+		return nil
+	}
+}
+
+// Return the tabs of the given window in order.
+//
+func (s *state) tabsOf(windowId int) []*protocol.Tab {
+	tabs := make([]*protocol.Tab, 0)
+
+	for _, tab := range s.tabs {
+		if tab.WindowId == windowId {
+			tabs = append(tabs, tab)
+		}
+	}
+
+	return tabs
+}
+
+// Make the given tab the only active tab in its window.
+//
+func (s *state) activate(tab *protocol.Tab) {
+	for _, other := range s.tabs {
+		if other.WindowId == tab.WindowId {
+			other.Active = false
+			other.Highlighted = false
+		}
+	}
+
+	tab.Active = true
+	tab.Highlighted = true
+}
+
+// Move the given tab to the given index of the given window. Like
+// the browser, an index of -1 or beyond the end of the window moves
+// the tab to the end of the window.
+//
+func (s *state) move(tab *protocol.Tab, windowId int, index int) {
+	source := tab.WindowId
+	remaining := make([]*protocol.Tab, 0, len(s.tabs))
+
+	for _, other := range s.tabs {
+		if other != tab {
+			remaining = append(remaining, other)
+		}
+	}
+
+	tab.WindowId = windowId
+	position := len(remaining)
+	count := 0
+
+	for i, other := range remaining {
+		if other.WindowId == windowId {
+			if count == index {
+				position = i
+				break
+			}
+
+			count++
+		}
+	}
+
+	s.tabs = append(remaining[:position], append([]*protocol.Tab{tab}, remaining[position:]...)...)
+
+	if source != windowId {
+		tab.Active = false
+		s.ensureActive(source)
+		s.ensureActive(windowId)
+	}
+
+	s.reindex()
+}
+
+// Remove the given tab. The window of the tab is removed as well if
+// the tab is the last one in the window.
+//
+func (s *state) removeTab(tab *protocol.Tab) {
+	remaining := make([]*protocol.Tab, 0, len(s.tabs))
+
+	for _, other := range s.tabs {
+		if other != tab {
+			remaining = append(remaining, other)
+		}
+	}
+
+	s.tabs = remaining
+	delete(s.results, tab.Id)
+
+	if len(s.tabsOf(tab.WindowId)) == 0 {
+		s.removeWindow(tab.WindowId)
+	} else {
+		s.ensureActive(tab.WindowId)
+		s.reindex()
+	}
+}
+
+// Remove the given window together with all its tabs.
+//
+func (s *state) removeWindow(windowId int) {
+	windows := make([]*protocol.Window, 0, len(s.windows))
+	tabs := make([]*protocol.Tab, 0, len(s.tabs))
+
+	for _, window := range s.windows {
+		if window.Id != windowId {
+			windows = append(windows, window)
+		}
+	}
+
+	for _, tab := range s.tabs {
+		if tab.WindowId != windowId {
+			tabs = append(tabs, tab)
+		} else {
+			delete(s.results, tab.Id)
+		}
+	}
+
+	s.windows = windows
+	s.tabs = tabs
+	s.reindex()
+}
+
+// Make sure that the given window has an active tab if it has any
+// tab at all.
+//
+func (s *state) ensureActive(windowId int) {
+	tabs := s.tabsOf(windowId)
+
+	for _, tab := range tabs {
+		if tab.Active {
+			return
+		}
+	}
+
+	if len(tabs) > 0 {
+		s.activate(tabs[len(tabs)-1])
+	}
+}
+
+// Update the index of all tabs to match their position in their
+// windows.
+//
+func (s *state) reindex() {
+	counts := make(map[int]int)
+
+	for _, tab := range s.tabs {
+		tab.Index = counts[tab.WindowId]
+		counts[tab.WindowId]++
+	}
+}
+
+// Decode the input of a call into the given target. The input
+// should be a JSON object containing all the required fields;
+// otherwise errInvalidInput is returned.
+//
+func decode(input json.RawMessage, target interface{}, required ...string) error {
+	fields := make(map[string]json.RawMessage)
+
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return errInvalidInput
+	}
+
+	for _, field := range required {
+		if value, found := fields[field]; found == false {
+			return errInvalidInput
+		} else if bytes.Equal(value, []byte("null")) {
+			return errInvalidInput
+		}
+	}
+
+	if err := json.Unmarshal(input, target); err != nil {
+		return errInvalidInput
+	} else {
+		return nil
+	}
+}
+
+// Set the browser details reported by the info.get_browser method.
+//
+func (server *Server) SetBrowserInfo(info protocol.BrowserInfo) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.browser = info
+}
+
+// Set the platform details reported by the info.get_platform method.
+//
+func (server *Server) SetPlatformInfo(info protocol.PlatformInfo) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.platform = info
+}
+
+// Add a window to the fake browser and return its details. The id
+// of the window is assigned by the server. The tabs of the given
+// window are added to the new window as well; if there are none, a
+// blank tab is added instead. The new window takes the focus if it
+// is marked as focused.
+//
+func (server *Server) AddWindow(window protocol.Window) protocol.Window {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.state.addWindow(window)
+}
+
+// Add a tab to the end of a window of the fake browser and return
+// its details. The id and index of the tab are assigned by the
+// server. The tab is added to the current window if its window id
+// is zero. The new tab becomes the active tab of its window if it
+// is marked as active.
+//
+func (server *Server) AddTab(tab protocol.Tab) protocol.Tab {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.state.addTab(tab)
+}
+
+// Add a download to the fake browser and return its details. The
+// id of the download is assigned by the server. The state of the
+// download defaults to "complete".
+//
+func (server *Server) AddDownload(download protocol.Download) protocol.Download {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.state.addDownload(download)
+}
+
+// Set the result returned by the documents.query method for the
+// given tab. Queries against tabs without a result fail with the
+// "execution" error category.
+//
+func (server *Server) SetQueryResult(tabId int, result interface{}) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.state.results[tabId] = result
+}
+
+// Return all windows of the fake browser together with their tabs.
+//
+func (server *Server) Windows() []protocol.Window {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	windows := make([]protocol.Window, 0, len(server.state.windows))
+
+	for _, window := range server.state.windows {
+		windows = append(windows, server.state.window(window.Id))
+	}
+
+	return windows
+}
+
+// Return all tabs of the fake browser.
+//
+func (server *Server) Tabs() []protocol.Tab {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	tabs := make([]protocol.Tab, 0, len(server.state.tabs))
+
+	for _, tab := range server.state.tabs {
+		tabs = append(tabs, *tab)
+	}
+
+	return tabs
+}
+
+// Return all downloads of the fake browser.
+//
+func (server *Server) Downloads() []protocol.Download {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	downloads := make([]protocol.Download, 0, len(server.state.downloads))
+
+	for _, download := range server.state.downloads {
+		downloads = append(downloads, *download)
+	}
+
+	return downloads
+}
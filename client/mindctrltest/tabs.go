@@ -0,0 +1,314 @@
+package mindctrltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"strings"
+)
+
+// Register the built-in handlers of the tabs methods. Like the web
+// extension, the tabs.deactivate method is NOT registered. Pages
+// load instantly in the fake browser, so tabs are always in the
+// "complete" status.
+//
+func (server *Server) registerTabMethods() {
+	server.register(protocol.FindTabsMethod, server.findTabs)
+	server.register(protocol.GetTabMethod, server.getTab)
+	server.register(protocol.GetCurrentTabMethod, server.getCurrentTab)
+	server.register(protocol.CreateTabMethod, server.createTab)
+	server.register(protocol.LoadTabMethod, server.loadTab)
+	server.register(protocol.ReloadTabMethod, server.reloadTab)
+	server.register(protocol.MoveTabMethod, server.moveTab)
+	server.register(protocol.DiscardTabMethod, server.discardTab)
+	server.register(protocol.RemoveTabMethod, server.removeTab)
+
+	server.register(protocol.ActivateTabMethod, server.updateTab(func(s *state, tab *protocol.Tab) {
+		s.activate(tab)
+	}))
+
+	server.register(protocol.MuteTabMethod, server.updateTab(func(s *state, tab *protocol.Tab) {
+		tab.Muted = protocol.MutedInfo{Muted: true, Reason: "extension"}
+	}))
+
+	server.register(protocol.UnmuteTabMethod, server.updateTab(func(s *state, tab *protocol.Tab) {
+		tab.Muted = protocol.MutedInfo{Muted: false}
+	}))
+
+	server.register(protocol.PinTabMethod, server.updateTab(func(s *state, tab *protocol.Tab) {
+		tab.Pinned = true
+	}))
+
+	server.register(protocol.UnpinTabMethod, server.updateTab(func(s *state, tab *protocol.Tab) {
+		tab.Pinned = false
+	}))
+}
+
+// Handle the tabs.find method. Only tabs in normal windows are
+// reported, like the web extension does.
+//
+func (server *Server) findTabs(input json.RawMessage) (interface{}, error) {
+	params := protocol.FindTabsInput{}
+
+	if err := decode(input, &params); err != nil {
+		return nil, err
+	} else if params.Url != nil && isMatchPattern(*params.Url) == false {
+		return nil, errInvalidInput
+	} else if params.Status != nil && *params.Status != "loading" && *params.Status != "complete" {
+		return nil, errInvalidInput
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	output := protocol.FindTabsOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: make([]protocol.Tab, 0)}
+
+	for _, tab := range server.state.tabs {
+		if window, err := server.state.findWindow(tab.WindowId); err != nil || window.Type != "normal" {
+			continue
+		} else if params.WindowId != nil && *params.WindowId != 0 && tab.WindowId != *params.WindowId {
+			continue
+		} else if params.Url != nil && matchUrl(*params.Url, tab.Url) == false {
+			continue
+		} else if params.Status != nil && tab.Status != *params.Status {
+			continue
+		} else if params.Active != nil && tab.Active != *params.Active {
+			continue
+		} else if params.Audible != nil && tab.Audible != *params.Audible {
+			continue
+		} else if params.Discarded != nil && tab.Discarded != *params.Discarded {
+			continue
+		} else if params.Muted != nil && tab.Muted.Muted != *params.Muted {
+			continue
+		} else if params.Pinned != nil && tab.Pinned != *params.Pinned {
+			continue
+		} else {
+			output.Result = append(output.Result, *tab)
+		}
+	}
+
+	return output, nil
+}
+
+// Handle the tabs.get method.
+//
+func (server *Server) getTab(input json.RawMessage) (interface{}, error) {
+	params := protocol.GetTabInput{}
+
+	if err := decode(input, &params, "tabId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if tab, err := server.state.findTab(params.TabId); err != nil {
+		return nil, err
+	} else {
+		return protocol.GetTabOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *tab}, nil
+	}
+}
+
+// Handle the tabs.get_current method. The current tab is the active
+// tab of the current window.
+//
+func (server *Server) getCurrentTab(input json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if window := server.state.currentWindow(); window != nil {
+		for _, tab := range server.state.tabsOf(window.Id) {
+			if tab.Active {
+				return protocol.GetCurrentTabOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *tab}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("current tab cannot be found")
+}
+
+// Handle the tabs.create method.
+//
+func (server *Server) createTab(input json.RawMessage) (interface{}, error) {
+	params := protocol.CreateTabInput{}
+
+	if err := decode(input, &params); err != nil {
+		return nil, err
+	} else if params.Url != nil && strings.TrimSpace(*params.Url) == "" {
+		return nil, errInvalidInput
+	} else if params.Url != nil && server.permitsUrl(*params.Url) == false {
+		return nil, newPolicyViolation("url %s not permitted by policy", *params.Url)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	tab := protocol.Tab{Url: "about:blank"}
+
+	if params.WindowId != nil && *params.WindowId != 0 {
+		if _, err := server.state.findWindow(*params.WindowId); err != nil {
+			return nil, err
+		} else {
+			tab.WindowId = *params.WindowId
+		}
+	}
+
+	if params.Url != nil {
+		tab.Url = *params.Url
+	}
+
+	if params.Active != nil {
+		tab.Active = *params.Active
+	}
+
+	return protocol.CreateTabOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: server.state.addTab(tab)}, nil
+}
+
+// Handle the tabs.load method.
+//
+func (server *Server) loadTab(input json.RawMessage) (interface{}, error) {
+	params := protocol.LoadTabInput{}
+
+	if err := decode(input, &params, "tabId", "url"); err != nil {
+		return nil, err
+	} else if strings.TrimSpace(params.Url) == "" {
+		return nil, errInvalidInput
+	} else if server.permitsUrl(params.Url) == false {
+		return nil, newPolicyViolation("url %s not permitted by policy", params.Url)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if tab, err := server.state.findTab(params.TabId); err != nil {
+		return nil, err
+	} else {
+		tab.Url = params.Url
+		tab.Title = ""
+		tab.Discarded = false
+		return protocol.LoadTabOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *tab}, nil
+	}
+}
+
+// Handle the tabs.reload method.
+//
+func (server *Server) reloadTab(input json.RawMessage) (interface{}, error) {
+	params := protocol.ReloadTabInput{}
+
+	if err := decode(input, &params, "tabId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if tab, err := server.state.findTab(params.TabId); err != nil {
+		return nil, err
+	} else if server.permitsUrl(tab.Url) == false {
+		return nil, newPolicyViolation("page in tab %d not permitted by policy", params.TabId)
+	} else {
+		tab.Discarded = false
+		return protocol.ReloadTabOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *tab}, nil
+	}
+}
+
+// Handle the tabs.move method.
+//
+func (server *Server) moveTab(input json.RawMessage) (interface{}, error) {
+	params := protocol.MoveTabInput{}
+
+	if err := decode(input, &params, "tabId", "index"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if tab, err := server.state.findTab(params.TabId); err != nil {
+		return nil, err
+	} else if params.WindowId == nil || *params.WindowId == 0 {
+		server.state.move(tab, tab.WindowId, params.Index)
+		return protocol.MoveTabOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *tab}, nil
+	} else if _, err := server.state.findWindow(*params.WindowId); err != nil {
+		return nil, err
+	} else {
+		server.state.move(tab, *params.WindowId, params.Index)
+		return protocol.MoveTabOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *tab}, nil
+	}
+}
+
+// Handle the tabs.discard method. Like the browser, active tabs
+// cannot be discarded.
+//
+func (server *Server) discardTab(input json.RawMessage) (interface{}, error) {
+	params := protocol.DiscardTabInput{}
+
+	if err := decode(input, &params, "tabId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if tab, err := server.state.findTab(params.TabId); err != nil {
+		return nil, err
+	} else if tab.Active {
+		return nil, fmt.Errorf("Cannot discard active tab %d", tab.Id)
+	} else {
+		tab.Discarded = true
+		return protocol.DiscardTabOutput{GenericOutput: protocol.GenericOutput{Success: true}}, nil
+	}
+}
+
+// Handle the tabs.remove method.
+//
+func (server *Server) removeTab(input json.RawMessage) (interface{}, error) {
+	params := protocol.RemoveTabInput{}
+
+	if err := decode(input, &params, "tabId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if tab, err := server.state.findTab(params.TabId); err != nil {
+		return nil, err
+	} else {
+		server.state.removeTab(tab)
+		return protocol.RemoveTabOutput{GenericOutput: protocol.GenericOutput{Success: true}}, nil
+	}
+}
+
+// Create a handler for methods that update a single tab and report
+// its details back to the caller.
+//
+func (server *Server) updateTab(update func(s *state, tab *protocol.Tab)) func(input json.RawMessage) (interface{}, error) {
+	return func(input json.RawMessage) (interface{}, error) {
+		params := protocol.GetTabInput{}
+
+		if err := decode(input, &params, "tabId"); err != nil {
+			return nil, err
+		}
+
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+
+		if tab, err := server.state.findTab(params.TabId); err != nil {
+			return nil, err
+		} else {
+			update(&server.state, tab)
+			return protocol.GetTabOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *tab}, nil
+		}
+	}
+}
+
+// Check if the given url matches the given match pattern.
+//
+func matchUrl(pattern string, url string) bool {
+	if compiled, err := compileMatchPattern(pattern); err != nil {
+		return false
+	} else {
+		return compiled.MatchString(url)
+	}
+}
@@ -0,0 +1,254 @@
+package mindctrltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"strings"
+)
+
+// Input for the windows.create method. The input type in the protocol
+// package does not carry any field yet, so the fields accepted by the
+// web extension are listed here instead.
+//
+type createWindowInput struct {
+	Url     *string `json:"url,omitempty"`
+	State   *string `json:"state,omitempty"`
+	Focused *bool   `json:"focused,omitempty"`
+	Top     *int    `json:"top,omitempty"`
+	Left    *int    `json:"left,omitempty"`
+	Width   *int    `json:"width,omitempty"`
+	Height  *int    `json:"height,omitempty"`
+}
+
+// Register the built-in handlers of the windows methods.
+//
+func (server *Server) registerWindowMethods() {
+	server.register(protocol.FindWindowsMethod, server.findWindows)
+	server.register(protocol.GetWindowMethod, server.getWindow)
+	server.register(protocol.GetCurrentWindowMethod, server.getCurrentWindow)
+	server.register(protocol.CreateWindowMethod, server.createWindow)
+	server.register(protocol.MoveWindowMethod, server.moveWindow)
+	server.register(protocol.ResizeWindowMethod, server.resizeWindow)
+	server.register(protocol.RemoveWindowMethod, server.removeWindow)
+
+	server.register(protocol.MinimizeWindowMethod, server.updateWindow(func(s *state, window *protocol.Window) {
+		window.State = "minimized"
+		window.Focused = false
+	}))
+
+	server.register(protocol.MaximizeWindowMethod, server.updateWindow(func(s *state, window *protocol.Window) {
+		window.State = "maximized"
+	}))
+
+	server.register(protocol.FullscreenWindowMethod, server.updateWindow(func(s *state, window *protocol.Window) {
+		window.State = "fullscreen"
+	}))
+
+	server.register(protocol.RestoreWindowMethod, server.updateWindow(func(s *state, window *protocol.Window) {
+		window.State = "normal"
+	}))
+
+	server.register(protocol.FocusWindowMethod, server.updateWindow(func(s *state, window *protocol.Window) {
+		for _, other := range s.windows {
+			other.Focused = false
+		}
+
+		window.Focused = true
+	}))
+
+	server.register(protocol.UnfocusWindowMethod, server.updateWindow(func(s *state, window *protocol.Window) {
+		window.Focused = false
+	}))
+}
+
+// Handle the windows.find method. Only normal windows are reported,
+// like the web extension does.
+//
+func (server *Server) findWindows(input json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	output := protocol.FindWindowsOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: make([]protocol.Window, 0)}
+
+	for _, window := range server.state.windows {
+		if window.Type == "normal" {
+			output.Result = append(output.Result, server.state.window(window.Id))
+		}
+	}
+
+	return output, nil
+}
+
+// Handle the windows.get method.
+//
+func (server *Server) getWindow(input json.RawMessage) (interface{}, error) {
+	params := protocol.GetWindowInput{}
+
+	if err := decode(input, &params, "windowId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if _, err := server.state.findWindow(params.WindowId); err != nil {
+		return nil, err
+	} else {
+		return protocol.GetWindowOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: server.state.window(params.WindowId)}, nil
+	}
+}
+
+// Handle the windows.get_current method.
+//
+func (server *Server) getCurrentWindow(input json.RawMessage) (interface{}, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if window := server.state.currentWindow(); window == nil {
+		return nil, fmt.Errorf("current window cannot be found")
+	} else {
+		return protocol.GetCurrentWindowOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: server.state.window(window.Id)}, nil
+	}
+}
+
+// Handle the windows.create method. Like the web extension, the
+// focus, position and size options are only honored for windows in
+// the normal state, and the URL must be permitted by the policy.
+//
+func (server *Server) createWindow(input json.RawMessage) (interface{}, error) {
+	params := createWindowInput{}
+
+	if err := decode(input, &params); err != nil {
+		return nil, err
+	} else if params.Url != nil && strings.TrimSpace(*params.Url) == "" {
+		return nil, errInvalidInput
+	} else if params.Url != nil && server.permitsUrl(*params.Url) == false {
+		return nil, newPolicyViolation("url %s not permitted by policy", *params.Url)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	window := protocol.Window{State: "normal"}
+	tab := protocol.Tab{Url: "about:blank", Active: true}
+
+	if params.Url != nil {
+		tab.Url = *params.Url
+	}
+
+	if params.State != nil {
+		window.State = *params.State
+	}
+
+	if window.State == "normal" {
+		if params.Focused != nil {
+			window.Focused = *params.Focused
+		}
+
+		if params.Top != nil {
+			window.Top = *params.Top
+		}
+
+		if params.Left != nil {
+			window.Left = *params.Left
+		}
+
+		if params.Width != nil {
+			window.Width = *params.Width
+		}
+
+		if params.Height != nil {
+			window.Height = *params.Height
+		}
+	}
+
+	window.Tabs = []protocol.Tab{tab}
+	return protocol.CreateWindowOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: server.state.addWindow(window)}, nil
+}
+
+// Handle the windows.move method.
+//
+func (server *Server) moveWindow(input json.RawMessage) (interface{}, error) {
+	params := protocol.MoveWindowInput{}
+
+	if err := decode(input, &params, "windowId", "left", "top"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if window, err := server.state.findWindow(params.WindowId); err != nil {
+		return nil, err
+	} else {
+		window.Left = params.Left
+		window.Top = params.Top
+		return protocol.MoveWindowOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *window}, nil
+	}
+}
+
+// Handle the windows.resize method.
+//
+func (server *Server) resizeWindow(input json.RawMessage) (interface{}, error) {
+	params := protocol.ResizeWindowInput{}
+
+	if err := decode(input, &params, "windowId", "width", "height"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if window, err := server.state.findWindow(params.WindowId); err != nil {
+		return nil, err
+	} else {
+		window.Width = params.Width
+		window.Height = params.Height
+		return protocol.ResizeWindowOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *window}, nil
+	}
+}
+
+// Handle the windows.remove method.
+//
+func (server *Server) removeWindow(input json.RawMessage) (interface{}, error) {
+	params := protocol.RemoveWindowInput{}
+
+	if err := decode(input, &params, "windowId"); err != nil {
+		return nil, err
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if _, err := server.state.findWindow(params.WindowId); err != nil {
+		return nil, err
+	} else {
+		server.state.removeWindow(params.WindowId)
+		return protocol.RemoveWindowOutput{GenericOutput: protocol.GenericOutput{Success: true}}, nil
+	}
+}
+
+// Create a handler for methods that update a single window and
+// report its details back to the caller. Like the web extension,
+// the reported details do not include the tabs of the window.
+//
+func (server *Server) updateWindow(update func(s *state, window *protocol.Window)) func(input json.RawMessage) (interface{}, error) {
+	return func(input json.RawMessage) (interface{}, error) {
+		params := protocol.GetWindowInput{}
+
+		if err := decode(input, &params, "windowId"); err != nil {
+			return nil, err
+		}
+
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+
+		if window, err := server.state.findWindow(params.WindowId); err != nil {
+			return nil, err
+		} else {
+			update(&server.state, window)
+			return protocol.GetWindowOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: *window}, nil
+		}
+	}
+}
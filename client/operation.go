@@ -1,9 +1,27 @@
 package mindctrl
 
+import (
+	"context"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+)
+
+// Executor executes remote calls on behalf of operations. It is
+// implemented by [Transport], which talks to a single server, and
+// [Pool], which spreads the calls across several servers. Every
+// operation can be executed or started over either of them, and so
+// can batches of operations.
+//
+type Executor interface {
+	call(ctx context.Context, method string, args interface{}, reply interface{}) error
+	start(ctx context.Context, method string, args interface{}, reply interface{}, callback Callback)
+	batch(ctx context.Context, calls []batchCall)
+}
+
 // Embeddable struct that provides a partial implementation of
 // operations.
 //
 type GenericOperation struct {
+	method   string
 	started  bool
 	finished bool
 	err      error
@@ -37,21 +55,41 @@ func (op *GenericOperation) doEnsureFinished() {
 	}
 }
 
-func (op *GenericOperation) doStart(transport *Transport, method string, arguments, reply interface{}, callback Callback) {
+func (op *GenericOperation) doStart(ctx context.Context, transport Executor, method string, arguments, reply interface{}, callback Callback) {
 	if op.started == true {
 		panic("operation already started")
 	} else {
 		op.started = true
-		transport.start(method, arguments, reply, callback)
+		op.method = method
+		transport.start(ctx, method, arguments, reply, callback)
 	}
 }
 
-func (op *GenericOperation) doExecute(transport *Transport, method string, arguments, reply interface{}) error {
+func (op *GenericOperation) doGo(ctx context.Context, transport Executor, method string, arguments, reply interface{}, output *protocol.GenericOutput) *Future {
+	future := newFuture()
+
+	op.doStart(ctx, transport, method, arguments, reply, func(m string, a, r interface{}, err error) {
+		op.doFinish(err)
+
+		if err != nil {
+			future.finish(err)
+		} else if output.Success == false {
+			future.finish(op.doGetOutputError(output))
+		} else {
+			future.finish(nil)
+		}
+	})
+
+	return future
+}
+
+func (op *GenericOperation) doExecute(ctx context.Context, transport Executor, method string, arguments, reply interface{}) error {
 	if op.started == true {
 		panic("operation already started")
 	} else {
 		op.started = true
-		op.err = transport.call(method, arguments, reply)
+		op.method = method
+		op.err = transport.call(ctx, method, arguments, reply)
 		op.finished = true
 		return op.err
 	}
@@ -71,3 +109,7 @@ func (op *GenericOperation) doFinish(err error) {
 func (op *GenericOperation) doGetError() error {
 	return op.err
 }
+
+func (op *GenericOperation) doGetOutputError(output *protocol.GenericOutput) error {
+	return getOutputError(op.method, output)
+}
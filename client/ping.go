@@ -1,7 +1,7 @@
 package mindctrl
 
 import (
-	"errors"
+	"context"
 	"github.com/kmchan2018/mindctrl/client/protocol"
 )
 
@@ -19,25 +19,45 @@ func Ping() *PingOperation {
 	return op
 }
 
-func (op *PingOperation) Start(transport *Transport, callback func(op *PingOperation)) {
-	op.doStart(transport, protocol.PingMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *PingOperation) Start(transport Executor, callback func(op *PingOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *PingOperation) StartContext(ctx context.Context, transport Executor, callback func(op *PingOperation)) {
+	op.doStart(ctx, transport, protocol.PingMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *PingOperation) StartChannel(transport *Transport, channel chan *PingOperation) {
-	op.doStart(transport, protocol.PingMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *PingOperation) StartChannel(transport Executor, channel chan *PingOperation) {
+	op.doStart(context.Background(), transport, protocol.PingMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *PingOperation) Execute(transport *Transport) error {
-	if err := op.doExecute(transport, protocol.PingMethod, &op.input, &op.output); err != nil {
+func (op *PingOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *PingOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.PingMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *PingOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.PingMethod, &op.input, &op.output)
+}
+
+func (op *PingOperation) Execute(transport Executor) error {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *PingOperation) ExecuteContext(ctx context.Context, transport Executor) error {
+	if err := op.doExecute(ctx, transport, protocol.PingMethod, &op.input, &op.output); err != nil {
 		return err
 	} else if op.output.Success == false {
-		return errors.New(op.output.Message)
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
@@ -46,8 +66,10 @@ func (op *PingOperation) Execute(transport *Transport) error {
 func (op *PingOperation) Result() error {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return err
+	} else if op.output.Success == false {
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
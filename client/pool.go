@@ -0,0 +1,455 @@
+package mindctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"sort"
+	"sync"
+)
+
+// Error reported by [Pool] to indicate that no server in the pool is
+// alive, so the call cannot be executed anywhere.
+//
+var ErrNoServer = errors.New("no server available")
+
+// Strategy used by [Pool] to pick the server for each call.
+//
+type PoolStrategy int
+
+const (
+	RoundRobin PoolStrategy = iota // take turns among the servers
+	LeastBusy                      // pick the server with the fewest calls in flight
+)
+
+// PoolOptions contains additional data for the pool.
+//
+// The 'Servers' field contains the names of the servers in the pool.
+// If it is empty, every server found on the intermediate MQTT broker
+// joins the pool.
+//
+// The 'Strategy' field contains the strategy to pick the server for
+// each call. The default is [RoundRobin].
+//
+// The 'Limit' field contains the maximum number of calls in flight
+// on each server; calls beyond the limit wait until a server is free.
+// The 'Limits' field overrides the limit of individual servers. The
+// default is 0, which means no limit.
+//
+type PoolOptions struct {
+	Servers  []string       // names of the servers in the pool
+	Strategy PoolStrategy   // strategy to pick the server for each call
+	Limit    int            // maximum number of calls in flight on each server
+	Limits   map[string]int // maximum number of calls in flight on individual servers
+}
+
+func (options *PoolOptions) getServers() []string {
+	if options != nil {
+		return options.Servers
+	} else {
+		return nil
+	}
+}
+
+func (options *PoolOptions) getStrategy() PoolStrategy {
+	if options != nil {
+		return options.Strategy
+	} else {
+		return RoundRobin
+	}
+}
+
+func (options *PoolOptions) getLimit(server string) int {
+	if options == nil {
+		return 0
+	} else if limit, found := options.Limits[server]; found {
+		return limit
+	} else {
+		return options.Limit
+	}
+}
+
+// Pool spreads calls across the transports of several servers on
+// the same intermediate MQTT broker. Like [Transport], it implements
+// [Executor], so every operation can be executed or started over the
+// pool; each call is sent to one of the servers picked according to
+// the strategy in the pool options.
+//
+// The pool follows the status messages of the servers. A server
+// joins the pool when it is alive, and leaves the pool when it
+// turns dead or its transport becomes unusable. It joins the pool
+// again when it is back alive.
+//
+// Unlike the transport, callbacks of async calls over the pool may
+// be invoked concurrently when the calls are sent to different
+// servers.
+//
+type Pool struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	url          string
+	client       string
+	options      *Options
+	poolOptions  *PoolOptions
+	interceptors []Interceptor
+	watcher      *codec.Watcher
+	mutex        sync.Mutex
+	members      map[string]*poolMember
+	changed      chan struct{}
+	cursor       int
+	closed       bool
+}
+
+// Member of the pool. The transport is nil while the server is not
+// alive or the transport is being created.
+//
+type poolMember struct {
+	name       string
+	transport  *Transport
+	connecting bool
+	active     int
+	limit      int
+}
+
+// Create a new pool with the given options. The url points to the
+// intermediate MQTT broker like [NewTransport]. The client name is
+// used as the prefix of the client names of the transports, and the
+// transport options and the interceptors are used for every
+// transport in the pool.
+//
+func NewPool(url string, client string, options *Options, poolOptions *PoolOptions, interceptors ...Interceptor) (*Pool, error) {
+	return NewPoolContext(context.Background(), url, client, options, poolOptions, interceptors...)
+}
+
+// Create a new pool with the given options. The function discovers
+// the servers on the broker first, which takes the duration of the
+// 'DiscoveryWindow' option, and then waits until the servers alive
+// at the moment have joined the pool. If the given context is
+// cancelled or its deadline passes before that, the function gives
+// up and returns the context error.
+//
+func NewPoolContext(ctx context.Context, url string, client string, options *Options, poolOptions *PoolOptions, interceptors ...Interceptor) (*Pool, error) {
+	poolCtx, cancel := context.WithCancel(context.Background())
+
+	pool := &Pool{
+		ctx:          poolCtx,
+		cancel:       cancel,
+		url:          url,
+		client:       client,
+		options:      options,
+		poolOptions:  poolOptions,
+		interceptors: interceptors,
+		members:      make(map[string]*poolMember),
+		changed:      make(chan struct{}),
+	}
+
+	for _, name := range poolOptions.getServers() {
+		pool.members[name] = &poolMember{name: name, limit: poolOptions.getLimit(name)}
+	}
+
+	// The servers found during the discovery join the pool as their
+	// retained status messages arrive, so the result of the
+	// discovery itself is not needed. Closing the pool on failure
+	// closes the transports created in the meantime.
+
+	_, watcher, err := codec.DiscoverAndWatch(ctx, url, options, func(server ServerInfo) {
+		pool.mutex.Lock()
+		defer pool.mutex.Unlock()
+		pool.update(server)
+	})
+
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	pool.mutex.Lock()
+	pool.watcher = watcher
+	pool.mutex.Unlock()
+
+	if err := pool.settle(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// Wait until no member of the pool is connecting, so that the servers
+// alive when the pool is created have joined the pool.
+//
+func (pool *Pool) settle(ctx context.Context) error {
+	for {
+		pool.mutex.Lock()
+		connecting := false
+		changed := pool.changed
+
+		for _, member := range pool.members {
+			connecting = connecting || member.connecting
+		}
+
+		pool.mutex.Unlock()
+
+		if connecting == false {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Return the names of the servers in the pool that can take calls at
+// the moment, in the order of their names.
+//
+func (pool *Pool) Servers() []string {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	names := make([]string, 0, len(pool.members))
+
+	for _, member := range pool.members {
+		if member.transport != nil {
+			names = append(names, member.name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Close the pool and all transports in it. Calls in flight fail with
+// the error [codec.ErrClosed], and so do calls made afterwards.
+//
+// Closing the pool more than once returns [codec.ErrClosed].
+//
+func (pool *Pool) Close() error {
+	pool.mutex.Lock()
+
+	if pool.closed {
+		pool.mutex.Unlock()
+		return codec.ErrClosed
+	}
+
+	pool.closed = true
+	transports := make([]*Transport, 0, len(pool.members))
+
+	for _, member := range pool.members {
+		if member.transport != nil {
+			transports = append(transports, member.transport)
+			member.transport = nil
+		}
+	}
+
+	watcher := pool.watcher
+	pool.notify()
+	pool.mutex.Unlock()
+
+	pool.cancel()
+
+	if watcher != nil {
+		watcher.Close()
+	}
+
+	for _, transport := range transports {
+		transport.Close()
+	}
+
+	return nil
+}
+
+// Process the status of a server reported by the watcher. A live
+// server without transport gets one created in the background, and
+// a dead server leaves the pool. Servers not listed in the options
+// are ignored unless the list is empty. The caller must hold the
+// mutex.
+//
+func (pool *Pool) update(server ServerInfo) {
+	member, found := pool.members[server.Name]
+
+	if pool.closed {
+		return
+	} else if found == false && len(pool.poolOptions.getServers()) > 0 {
+		return
+	} else if found == false {
+		member = &poolMember{name: server.Name, limit: pool.poolOptions.getLimit(server.Name)}
+		pool.members[server.Name] = member
+	}
+
+	if server.Alive && member.transport == nil && member.connecting == false {
+		member.connecting = true
+		go pool.connect(member)
+	} else if server.Alive == false && member.transport != nil {
+		go member.transport.Close()
+		member.transport = nil
+		pool.notify()
+	}
+}
+
+// Create the transport of the given member. On failure, the member
+// stays out of the pool until the next alive message of the server.
+//
+func (pool *Pool) connect(member *poolMember) {
+	client := fmt.Sprintf("%s_%s", pool.client, member.name)
+	transport, err := NewTransportContext(pool.ctx, pool.url, client, member.name, pool.options, pool.interceptors...)
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	member.connecting = false
+	pool.notify()
+
+	if err != nil {
+		return
+	} else if pool.closed {
+		go transport.Close()
+	} else {
+		member.transport = transport
+		go pool.watch(member, transport)
+	}
+}
+
+// Remove the transport of the given member from the pool once the
+// transport becomes unusable.
+//
+func (pool *Pool) watch(member *poolMember, transport *Transport) {
+	<-transport.Done()
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	if member.transport == transport {
+		member.transport = nil
+		pool.notify()
+	}
+}
+
+// Wake up the calls waiting for a change in the pool. The caller must
+// hold the mutex.
+//
+func (pool *Pool) notify() {
+	close(pool.changed)
+	pool.changed = make(chan struct{})
+}
+
+// Pick a server for a call and reserve a slot on it. If every live
+// server is at its limit, the function waits until a slot is freed.
+// It fails with [ErrNoServer] if no server is alive.
+//
+func (pool *Pool) acquire(ctx context.Context) (*poolMember, *Transport, error) {
+	for {
+		pool.mutex.Lock()
+
+		if pool.closed {
+			pool.mutex.Unlock()
+			return nil, nil, codec.ErrClosed
+		}
+
+		member, alive := pool.pick()
+
+		if member != nil {
+			member.active++
+			transport := member.transport
+			pool.mutex.Unlock()
+			return member, transport, nil
+		} else if alive == false {
+			pool.mutex.Unlock()
+			return nil, nil, ErrNoServer
+		}
+
+		changed := pool.changed
+		pool.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+// Release the slot reserved on the given member.
+//
+func (pool *Pool) release(member *poolMember) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	member.active--
+	pool.notify()
+}
+
+// Pick the member for the next call according to the strategy. The
+// function returns nil if no member can take the call, together with
+// whether any member is alive at all. The caller must hold the mutex.
+//
+func (pool *Pool) pick() (*poolMember, bool) {
+	names := make([]string, 0, len(pool.members))
+
+	for name, member := range pool.members {
+		if member.transport != nil {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, false
+	}
+
+	sort.Strings(names)
+	var picked *poolMember
+	var index int
+
+	for offset := range names {
+		position := (pool.cursor + offset) % len(names)
+		member := pool.members[names[position]]
+
+		if member.limit > 0 && member.active >= member.limit {
+			continue
+		} else if picked == nil || member.active < picked.active {
+			picked = member
+			index = position
+		}
+
+		if pool.poolOptions.getStrategy() == RoundRobin {
+			break
+		}
+	}
+
+	if picked != nil {
+		pool.cursor = index + 1
+	}
+
+	return picked, true
+}
+
+// Call a remote method synchronously on one of the servers.
+//
+func (pool *Pool) call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	if member, transport, err := pool.acquire(ctx); err != nil {
+		return err
+	} else {
+		defer pool.release(member)
+		return transport.call(ctx, method, args, reply)
+	}
+}
+
+// Call a remote method asynchronously on one of the servers. The call
+// waits for a free server in a goroutine of its own, and the callback
+// is invoked by the dispatcher goroutine of the chosen transport, or
+// by the waiting goroutine if no server can take the call.
+//
+func (pool *Pool) start(ctx context.Context, method string, args interface{}, reply interface{}, callback Callback) {
+	go func() {
+		if member, transport, err := pool.acquire(ctx); err != nil {
+			callback(method, args, reply, err)
+		} else {
+			transport.start(ctx, method, args, reply, func(method string, args interface{}, reply interface{}, err error) {
+				pool.release(member)
+				callback(method, args, reply, err)
+			})
+		}
+	}()
+}
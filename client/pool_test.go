@@ -0,0 +1,115 @@
+package mindctrl_test
+
+import (
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Start a fake server with the given name on the broker at the given
+// url. The server is closed when the test finishes unless it is closed
+// by the test itself.
+//
+func startServer(t *testing.T, url string, name string) *mindctrltest.Server {
+	t.Helper()
+
+	server, err := mindctrltest.NewServer(url, name, nil)
+
+	if err != nil {
+		t.Fatalf("cannot start fake server %s: %v", name, err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// Create a new pool over the servers on the broker at the given url.
+// The pool is closed when the test finishes.
+//
+func startPool(t *testing.T, url string, poolOptions *mindctrl.PoolOptions) *mindctrl.Pool {
+	t.Helper()
+
+	pool, err := mindctrl.NewPool(url, "test", &mindctrl.Options{DiscoveryWindow: 100 * time.Millisecond}, poolOptions)
+
+	if err != nil {
+		t.Fatalf("cannot create pool: %v", err)
+	}
+
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+// Wait until the pool contains exactly the given servers.
+//
+func waitServers(t *testing.T, pool *mindctrl.Pool, expected ...string) {
+	t.Helper()
+
+	within(t, 10*time.Second, func() {
+		for strings.Join(pool.Servers(), " ") != strings.Join(expected, " ") {
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestPoolSpreadsCalls(t *testing.T) {
+	_, url := startBroker(t, nil)
+	first := startServer(t, url, "first")
+	second := startServer(t, url, "second")
+	pool := startPool(t, url, nil)
+	waitServers(t, pool, "first", "second")
+
+	for index := 0; index < 4; index++ {
+		if err := mindctrl.Ping().Execute(pool); err != nil {
+			t.Fatalf("cannot ping over pool: %v", err)
+		}
+	}
+
+	if len(first.Requests()) != 2 || len(second.Requests()) != 2 {
+		t.Errorf("servers receive %d and %d calls, expected 2 each", len(first.Requests()), len(second.Requests()))
+	}
+}
+
+func TestPoolFailover(t *testing.T) {
+	_, url := startBroker(t, nil)
+	first := startServer(t, url, "first")
+	second := startServer(t, url, "second")
+	pool := startPool(t, url, nil)
+	waitServers(t, pool, "first", "second")
+
+	second.Close()
+	waitServers(t, pool, "first")
+
+	for index := 0; index < 4; index++ {
+		if err := mindctrl.Ping().Execute(pool); err != nil {
+			t.Fatalf("cannot ping over pool after failover: %v", err)
+		}
+	}
+
+	if len(first.Requests()) != 4 {
+		t.Errorf("remaining server receives %d calls, expected 4", len(first.Requests()))
+	}
+
+	revived := startServer(t, url, "second")
+	waitServers(t, pool, "first", "second")
+
+	for index := 0; index < 2; index++ {
+		if err := mindctrl.Ping().Execute(pool); err != nil {
+			t.Fatalf("cannot ping over pool after revival: %v", err)
+		}
+	}
+
+	if len(revived.Requests()) != 1 {
+		t.Errorf("revived server receives %d calls, expected 1", len(revived.Requests()))
+	}
+
+	first.Close()
+	revived.Close()
+	waitServers(t, pool)
+
+	if err := mindctrl.Ping().Execute(pool); errors.Is(err, mindctrl.ErrNoServer) == false {
+		t.Errorf("ping fails with %v, expected %v", err, mindctrl.ErrNoServer)
+	}
+}
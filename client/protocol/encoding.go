@@ -0,0 +1,86 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Encodings for compressed inputs and outputs. The gzip encoding
+// refers to the gzip format (RFC 1952), and the deflate encoding to
+// the zlib format (RFC 1950) like in HTTP.
+//
+const (
+	GzipEncoding    = "gzip"
+	DeflateEncoding = "deflate"
+)
+
+// Encodings accepted for the output of calls, as sent in the accept
+// field of request packets by the clients in this module.
+//
+const AcceptedEncodings = GzipEncoding + ", " + DeflateEncoding
+
+// Compress the given JSON body with the given encoding. The result is
+// the compressed body encoded in base64, wrapped as a JSON string so
+// that it can take the place of the body in the packet.
+//
+func CompressBody(encoding string, body []byte) (json.RawMessage, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+
+	switch encoding {
+	case GzipEncoding:
+		writer = gzip.NewWriter(&buffer)
+	case DeflateEncoding:
+		writer = zlib.NewWriter(&buffer)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	} else if err := writer.Close(); err != nil {
+		return nil, err
+	} else {
+		return json.Marshal(base64.StdEncoding.EncodeToString(buffer.Bytes()))
+	}
+}
+
+// Decompress the given body produced by [CompressBody] or its
+// counterpart in the web extension, and return the original JSON
+// body.
+//
+func DecompressBody(encoding string, body json.RawMessage) (json.RawMessage, error) {
+	var encoded string
+	var reader io.ReadCloser
+
+	if err := json.Unmarshal(body, &encoded); err != nil {
+		return nil, err
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch encoding {
+	case GzipEncoding:
+		reader, err = gzip.NewReader(bytes.NewReader(compressed))
+	case DeflateEncoding:
+		reader, err = zlib.NewReader(bytes.NewReader(compressed))
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -1,5 +1,13 @@
 package protocol
 
+// Version of the RPC protocol spoken by this package. The version is
+// bumped whenever a change to the methods or the packets breaks the
+// compatibility between the client and the server; additions of new
+// methods do not count, since they can be detected from the method
+// list reported by info.get_capabilities.
+//
+const ProtocolVersion = 1
+
 // Names of the RPC service methods recognized by the server. Each
 // method will have the corresponding input and output structures
 // for the args and reply of the RPC call.
@@ -17,6 +25,8 @@ const (
 
 	GetBrowserInfoMethod  = "info.get_browser"
 	GetPlatformInfoMethod = "info.get_platform"
+	GetCapabilitiesMethod = "info.get_capabilities"
+	GetPolicyMethod       = "info.get_policy"
 
 	PingMethod = "ping"
 
@@ -74,6 +84,52 @@ type PlatformInfo struct {
 	Arch string `json:"arch"` // architecture of the processor the browser is running on
 }
 
+// Capabilities of the server. It contains the version of the web
+// extension, the version of the RPC protocol it speaks, the names
+// of the methods it supports, the encodings it accepts for
+// compressed inputs and whether it accepts batch packets. The
+// encodings are empty for extensions that predate compression, and
+// the batch flag is false for extensions that predate batches.
+//
+type Capabilities struct {
+	ExtensionVersion string   `json:"extensionVersion"`    // version of the web extension
+	ProtocolVersion  int      `json:"protocolVersion"`     // version of the RPC protocol
+	Methods          []string `json:"methods"`             // names of the supported methods
+	Encodings        []string `json:"encodings,omitempty"` // encodings accepted for compressed inputs
+	Batch            bool     `json:"batch,omitempty"`     // whether batch packets are accepted
+}
+
+// Return if the server supports the given method.
+//
+func (capabilities *Capabilities) Supports(method string) bool {
+	for _, candidate := range capabilities.Methods {
+		if candidate == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sandbox policy of the server. The 'UrlPatterns' field contains the
+// match patterns of the pages the tabs.load, tabs.create, tabs.reload,
+// windows.create and documents.query methods may touch, and the
+// 'DownloadDirectories' field contains the directories, relative to
+// the download folder, the downloads.create method may save files to.
+// Each list puts no restriction when it is empty. Calls violating the
+// policy fail with the "policy" error category.
+//
+// Pages loaded by tabs.load, tabs.create and tabs.reload are checked
+// again after redirects once loaded, and the tab is left on a blank
+// page if the final URL is not permitted. Calls with the 'NoWait'
+// option and windows.create return before the page is loaded, so only
+// the requested URL is checked.
+//
+type Policy struct {
+	UrlPatterns         []string `json:"urlPatterns"`         // match patterns of the permitted pages
+	DownloadDirectories []string `json:"downloadDirectories"` // permitted download directories
+}
+
 // Details of a single download.
 //
 // The structure is adapted from the downloads.DownloadItem type of
@@ -174,6 +230,14 @@ type GenericOutput struct {
 	Message  string `json:"message"`  // explanation of the error; exists only when success is false
 }
 
+// Return the common fields of the output. The method is promoted to
+// the output structs embedding the fields, so that code handling
+// outputs generically can access the fields.
+//
+func (output *GenericOutput) Generic() *GenericOutput {
+	return output
+}
+
 // Input for document.query RPC method. Currently the method
 // requires ID of the target tab and the GraphQL query to be
 // executed. The method also optionally accepts the name of the
@@ -355,6 +419,38 @@ type GetPlatformInfoOutput struct {
 	Result PlatformInfo `json:"result"`
 }
 
+// Input for info.get_capabilities RPC method. The method does not
+// require any extra data.
+//
+type GetCapabilitiesInput struct {
+	// empty
+}
+
+// Output for info.get_capabilities RPC method. Besides the usual
+// fields, the output also contains the capabilities of the server
+// which would be populated if the method is completed successfully.
+//
+type GetCapabilitiesOutput struct {
+	GenericOutput
+	Result Capabilities `json:"result"`
+}
+
+// Input for info.get_policy RPC method. The method does not require
+// any extra data.
+//
+type GetPolicyInput struct {
+	// empty
+}
+
+// Output for info.get_policy RPC method. Besides the usual fields,
+// the output also contains the sandbox policy of the server which
+// would be populated if the method is completed successfully.
+//
+type GetPolicyOutput struct {
+	GenericOutput
+	Result Policy `json:"result"`
+}
+
 // Input for ping RPC method. The method does not require any extra
 // data.
 //
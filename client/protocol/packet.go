@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"encoding/base64"
 	"encoding/json"
 )
 
@@ -18,15 +19,43 @@ import (
 // 3. The Input field is called "params" on the wire to make the
 // message JSON-RPC compliant.
 //
+// 4. The Encoding field is set when the input is compressed. In that
+// case, the input is a string containing the compressed JSON input
+// in base64.
+//
+// 5. The Accept field lists the encodings the client accepts for the
+// output, separated by commas. Servers may then compress large
+// outputs with one of them; servers that predate compression ignore
+// the field and always send plain JSON.
+//
+// 6. The ChunkSize field contains the maximum size of the response
+// messages the client can receive. Servers split larger responses
+// into chunk packets of at most that size; see [ChunkPacket]. When
+// the field is absent, the response is always sent whole.
+//
+// 7. The Token field contains the capability token of the client; see
+// [TokenClaims]. Extensions that do not require tokens ignore it.
+//
 type RequestPacket struct {
-	Type   string      `json:"type"`   // type of the packet; always "request"
-	Id     string      `json:"id"`     // unique id of the call
-	Method string      `json:"method"` // method to be called
-	Client string      `json:"client"` // client who makes the call
-	Server string      `json:"server"` // server who executes the call
-	Input  interface{} `json:"params"` // input of the call
+	Type      string      `json:"type"`                // type of the packet; always "request"
+	Id        string      `json:"id"`                  // unique id of the call
+	Method    string      `json:"method"`              // method to be called
+	Client    string      `json:"client"`              // client who makes the call
+	Server    string      `json:"server"`              // server who executes the call
+	Input     interface{} `json:"params"`              // input of the call
+	Encoding  string      `json:"encoding,omitempty"`  // encoding of the input, if compressed
+	Accept    string      `json:"accept,omitempty"`    // encodings accepted for the output
+	ChunkSize int         `json:"chunkSize,omitempty"` // maximum size of response messages
+	Token     string      `json:"token,omitempty"`     // capability token of the client
 }
 
+// Batch packet carries several requests in one message, like a batch
+// request of JSON-RPC. The server executes the requests and replies
+// to each of them with its own response packet, using the id of the
+// request as the correlation data of the response.
+//
+type BatchPacket []RequestPacket
+
 // Response packet defines the shape of the on-the-wire response data.
 // The fields are mostly self explanatory, but some needs further
 // explanation:
@@ -41,11 +70,116 @@ type RequestPacket struct {
 // 3. The Output field is called "result" on the wire to make the
 // message JSON-RPC compliant.
 //
+// 4. The Encoding field is set when the output is compressed. In that
+// case, the output is a string containing the compressed JSON output
+// in base64.
+//
 type ResponsePacket struct {
-	Type   string          `json:"type"`   // type of the packet; always "response"
-	Id     string          `json:"id"`     // unique id of the call
-	Method string          `json:"method"` // method to be called
-	Client string          `json:"client"` // client who makes the call
-	Server string          `json:"server"` // server who executes the call
-	Output json.RawMessage `json:"result"` // output of the call
+	Type     string          `json:"type"`               // type of the packet; always "response"
+	Id       string          `json:"id"`                 // unique id of the call
+	Method   string          `json:"method"`             // method to be called
+	Client   string          `json:"client"`             // client who makes the call
+	Server   string          `json:"server"`             // server who executes the call
+	Output   json.RawMessage `json:"result"`             // output of the call
+	Encoding string          `json:"encoding,omitempty"` // encoding of the output, if compressed
+}
+
+// Chunk packet carries a piece of a response message too large to be
+// published at once. The fields are mostly self explanatory, but some
+// needs further explanation:
+//
+// 1. The Type field should always contain the string "chunk".
+//
+// 2. The Id, Method, Client and Server fields are copied from the
+// response. The chunks are published like the response itself, with
+// the same response topic and correlation data.
+//
+// 3. The Index field contains the position of the chunk, starting from
+// 0, and the Count field the total number of chunks of the response.
+//
+// 4. The Data field contains the piece of the serialized response
+// packet in base64. The client concatenates the pieces in order and
+// processes the result as if the response were received whole.
+//
+type ChunkPacket struct {
+	Type   string `json:"type"`   // type of the packet; always "chunk"
+	Id     string `json:"id"`     // unique id of the call
+	Method string `json:"method"` // method to be called
+	Client string `json:"client"` // client who makes the call
+	Server string `json:"server"` // server who executes the call
+	Index  int    `json:"index"`  // position of the chunk
+	Count  int    `json:"count"`  // number of chunks of the response
+	Data   string `json:"data"`   // piece of the response in base64
+}
+
+// Smallest chunk size clients may ask for.
+//
+const MinimumChunkSize = 4096
+
+// Split the given serialized response packet into chunk packets whose
+// messages fit into the given size. The size of a message is found by
+// the given function, which accounts for anything added to a packet
+// when it is published, like the signature and the MQTT headers and
+// properties. The routing fields of the chunks are copied from the
+// given response. The function returns nil if the message fits as it
+// is, or if the size is too small to be honoured.
+//
+// The room left for the data is measured with an empty chunk packet,
+// whose index and count are as wide as the count of chunks. The count
+// grows with the room taken by its own digits, and it is refined until
+// it no longer grows. Note that the data in base64 is never escaped in
+// the message, so every byte of it takes exactly one byte of room.
+//
+func SplitResponse(response *ResponsePacket, message []byte, size int, measure func(packet []byte) int) []ChunkPacket {
+	if size < MinimumChunkSize || measure(message) <= size {
+		return nil
+	}
+
+	count := 1
+	piece := 0
+
+	for {
+		empty, _ := json.Marshal(&ChunkPacket{
+			Type:   "chunk",
+			Id:     response.Id,
+			Method: response.Method,
+			Client: response.Client,
+			Server: response.Server,
+			Index:  count,
+			Count:  count,
+		})
+
+		if piece = (size - measure(empty)) / 4 * 3; piece <= 0 {
+			return nil
+		} else if needed := (len(message) + piece - 1) / piece; needed <= count {
+			count = needed
+			break
+		} else {
+			count = needed
+		}
+	}
+
+	chunks := make([]ChunkPacket, 0, count)
+
+	for index := 0; index < count; index++ {
+		start := index * piece
+		end := start + piece
+
+		if end > len(message) {
+			end = len(message)
+		}
+
+		chunks = append(chunks, ChunkPacket{
+			Type:   "chunk",
+			Id:     response.Id,
+			Method: response.Method,
+			Client: response.Client,
+			Server: response.Server,
+			Index:  index,
+			Count:  count,
+			Data:   base64.StdEncoding.EncodeToString(message[start:end]),
+		})
+	}
+
+	return chunks
 }
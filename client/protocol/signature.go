@@ -0,0 +1,245 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Algorithms for signed packets. The HMAC algorithm uses a secret
+// shared by the client and the extension, while the Ed25519 algorithm
+// uses a key pair on each side: messages are signed with the private
+// key of the sender and verified with the public key of the sender.
+//
+const (
+	HmacSha256Signature = "hmac-sha256"
+	Ed25519Signature    = "ed25519"
+)
+
+// Signed packet wraps a message, like a request, batch, response or
+// chunk packet, together with its signature. The fields are mostly
+// self explanatory, but some needs further explanation:
+//
+// 1. The Type field should always contain the string "signed".
+//
+// 2. The Payload field contains the wrapped message as a string, so
+// that the signature covers its exact text and no canonical form of
+// JSON is needed.
+//
+// 3. The Timestamp field contains the time of signing in milliseconds
+// since the Unix epoch, and the Nonce field a random string unique to
+// the message. Receivers reject messages signed too long ago, and
+// messages whose nonce is seen before, so that captured messages
+// cannot be replayed.
+//
+// 4. The Signature field contains the signature in base64 over the
+// algorithm, the timestamp, the nonce and the payload, separated by
+// newlines.
+//
+type SignedPacket struct {
+	Type      string `json:"type"`      // type of the packet; always "signed"
+	Algorithm string `json:"algorithm"` // signature algorithm
+	Timestamp int64  `json:"timestamp"` // time of signing in milliseconds
+	Nonce     string `json:"nonce"`     // random string unique to the message
+	Payload   string `json:"payload"`   // wrapped message
+	Signature string `json:"signature"` // signature in base64
+}
+
+// Sign the given message with the given algorithm and key, and return
+// the signed packet wrapping the message. The key is the shared secret
+// for [HmacSha256Signature], or the Ed25519 private key of the sender
+// for [Ed25519Signature].
+//
+func SignMessage(message []byte, algorithm string, key []byte) ([]byte, error) {
+	nonce := make([]byte, 16)
+
+	if _, err := crand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	packet := &SignedPacket{
+		Type:      "signed",
+		Algorithm: algorithm,
+		Timestamp: time.Now().UnixMilli(),
+		Nonce:     hex.EncodeToString(nonce),
+		Payload:   string(message),
+	}
+
+	switch algorithm {
+	case HmacSha256Signature:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(packet.signingInput())
+		packet.Signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	case Ed25519Signature:
+		if len(key) != ed25519.PrivateKeySize {
+			return nil, errors.New("invalid ed25519 private key")
+		} else {
+			packet.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(ed25519.PrivateKey(key), packet.signingInput()))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm %q", algorithm)
+	}
+
+	return json.Marshal(packet)
+}
+
+// Parse the given message as a signed packet. The function returns
+// false if the message is not a signed packet.
+//
+func OpenMessage(message []byte) (*SignedPacket, bool) {
+	packet := &SignedPacket{}
+
+	if err := json.Unmarshal(message, packet); err != nil {
+		return nil, false
+	} else if packet.Type != "signed" {
+		return nil, false
+	} else {
+		return packet, true
+	}
+}
+
+// Verify the signature of the packet with the given algorithm and
+// key. The key is the shared secret for [HmacSha256Signature], or the
+// Ed25519 public key of the sender for [Ed25519Signature]. Packets
+// signed with another algorithm fail the verification.
+//
+func (packet *SignedPacket) Verify(algorithm string, key []byte) bool {
+	signature, err := base64.StdEncoding.DecodeString(packet.Signature)
+
+	if err != nil || packet.Algorithm != algorithm {
+		return false
+	}
+
+	switch algorithm {
+	case HmacSha256Signature:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(packet.signingInput())
+		return hmac.Equal(signature, mac.Sum(nil))
+	case Ed25519Signature:
+		if len(key) != ed25519.PublicKeySize {
+			return false
+		} else {
+			return ed25519.Verify(ed25519.PublicKey(key), packet.signingInput(), signature)
+		}
+	default:
+		return false
+	}
+}
+
+// Return the time of signing of the packet.
+//
+func (packet *SignedPacket) Time() time.Time {
+	return time.UnixMilli(packet.Timestamp)
+}
+
+// Return the data covered by the signature of the packet.
+//
+func (packet *SignedPacket) signingInput() []byte {
+	input := make([]byte, 0, len(packet.Algorithm)+len(packet.Nonce)+len(packet.Payload)+24)
+	input = append(input, packet.Algorithm...)
+	input = append(input, '\n')
+	input = strconv.AppendInt(input, packet.Timestamp, 10)
+	input = append(input, '\n')
+	input = append(input, packet.Nonce...)
+	input = append(input, '\n')
+	input = append(input, packet.Payload...)
+	return input
+}
+
+// ReplayGuard rejects signed packets signed too long ago or too far
+// in the future, and packets whose nonce is seen before within the
+// window. Nonces are forgotten once their packets fall out of the
+// window, since such packets are rejected anyway.
+//
+type ReplayGuard struct {
+	window time.Duration
+	mutex  sync.Mutex
+	seen   map[string]time.Time
+}
+
+// Create a new replay guard that accepts packets signed within the
+// given window around the current time.
+//
+func NewReplayGuard(window time.Duration) *ReplayGuard {
+	return &ReplayGuard{window: window, seen: make(map[string]time.Time)}
+}
+
+// Check the given packet against the guard at the given time. The
+// function returns true and remembers the nonce of the packet if the
+// packet is fresh.
+//
+func (guard *ReplayGuard) Check(packet *SignedPacket, now time.Time) bool {
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+
+	for nonce, expiry := range guard.seen {
+		if expiry.Before(now) {
+			delete(guard.seen, nonce)
+		}
+	}
+
+	signed := packet.Time()
+
+	if signed.Before(now.Add(-guard.window)) || signed.After(now.Add(guard.window)) {
+		return false
+	} else if _, found := guard.seen[packet.Nonce]; found {
+		return false
+	} else {
+		guard.seen[packet.Nonce] = signed.Add(guard.window)
+		return true
+	}
+}
+
+// Encode the given Ed25519 private key as PKCS #8 in base64, the format
+// of private keys accepted by the web extension.
+//
+func EncodePrivateKey(key ed25519.PrivateKey) (string, error) {
+	if der, err := x509.MarshalPKCS8PrivateKey(key); err != nil {
+		return "", err
+	} else {
+		return base64.StdEncoding.EncodeToString(der), nil
+	}
+}
+
+// Decode the given Ed25519 private key encoded by [EncodePrivateKey].
+//
+func DecodePrivateKey(encoded string) (ed25519.PrivateKey, error) {
+	if der, err := base64.StdEncoding.DecodeString(encoded); err != nil {
+		return nil, err
+	} else if parsed, err := x509.ParsePKCS8PrivateKey(der); err != nil {
+		return nil, err
+	} else if key, ok := parsed.(ed25519.PrivateKey); ok == false {
+		return nil, errors.New("not an ed25519 private key")
+	} else {
+		return key, nil
+	}
+}
+
+// Encode the given Ed25519 public key as raw bytes in base64, the
+// format of public keys accepted by the web extension.
+//
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// Decode the given Ed25519 public key encoded by [EncodePublicKey].
+//
+func DecodePublicKey(encoded string) (ed25519.PublicKey, error) {
+	if raw, err := base64.StdEncoding.DecodeString(encoded); err != nil {
+		return nil, err
+	} else if len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("not an ed25519 public key")
+	} else {
+		return ed25519.PublicKey(raw), nil
+	}
+}
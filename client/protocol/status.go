@@ -1,5 +1,12 @@
 package protocol
 
+// Name of the MQTT v5 user property that carries the time of a
+// status message. Servers attach the property to their status
+// messages with the time formatted in RFC 3339, so that clients can
+// tell when a retained message is published.
+//
+const StatusTimeProperty = "timestamp"
+
 // Return if the given payload is an alive status message which
 // contains the literal string "alive".
 //
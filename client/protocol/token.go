@@ -0,0 +1,156 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Methods every client may call regardless of its capability token,
+// since they reveal nothing about the browser and are needed to talk
+// to the extension at all.
+//
+var UnrestrictedMethods = []string{PingMethod, GetCapabilitiesMethod}
+
+// Claims of a capability token, which grants a client the right to
+// call some methods of the extension. Tokens are issued with the
+// Ed25519 private key of an issuer, and extensions configured with
+// the public key of the issuer refuse calls that are not permitted by
+// the token in the request packet with the "forbidden" category.
+//
+// The 'Key' field binds the token to the Ed25519 public key of the
+// client, encoded by [EncodePublicKey]. Tokens travel in the clear
+// inside request packets, so anyone who can read the server topic
+// can copy them; extensions therefore honour a token only in requests
+// signed with the private key it is bound to, and refuse tokens that
+// are not bound to any key. Tokens hence require Ed25519 signing on
+// both ends.
+//
+// The 'Client' field restricts the token to the client of the given
+// name; the token can be used by any client if it is empty. Client
+// names are chosen by the clients themselves, so the restriction only
+// guards against mistakes. The 'Methods' field lists the permitted
+// methods; a pattern ending with ".*" matches every method with the
+// given prefix, and "*" matches every method. The 'Expiry' field
+// contains the time after which the token is no longer valid, in
+// seconds since the Unix epoch; the token never expires if it is
+// zero.
+//
+type TokenClaims struct {
+	Algorithm string   `json:"alg"`              // signature algorithm; always "ed25519"
+	Key       string   `json:"key,omitempty"`    // public key of the client the token is bound to
+	Client    string   `json:"client,omitempty"` // name of the client the token is restricted to
+	Methods   []string `json:"methods"`          // patterns of the permitted methods
+	IssuedAt  int64    `json:"iat"`              // time of issue in seconds
+	Expiry    int64    `json:"exp,omitempty"`    // time of expiry in seconds
+}
+
+// Issue a capability token with the given claims, signed with the
+// given Ed25519 private key of the issuer. The token consists of the
+// claims in JSON and the signature over the former, both encoded in
+// unpadded base64url and joined with a dot.
+//
+func IssueToken(claims TokenClaims, key ed25519.PrivateKey) (string, error) {
+	claims.Algorithm = Ed25519Signature
+
+	if len(key) != ed25519.PrivateKeySize {
+		return "", errors.New("invalid ed25519 private key")
+	} else if mClaims, err := json.Marshal(claims); err != nil {
+		return "", err
+	} else {
+		payload := base64.RawURLEncoding.EncodeToString(mClaims)
+		signature := base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(payload)))
+		return payload + "." + signature, nil
+	}
+}
+
+// Verify the given capability token with the given Ed25519 public key
+// of the issuer and return its claims. Expiry is not checked here;
+// see [TokenClaims.Permits].
+//
+func VerifyToken(token string, key ed25519.PublicKey) (*TokenClaims, error) {
+	payload, encoded, found := strings.Cut(token, ".")
+
+	if found == false {
+		return nil, errors.New("malformed token")
+	} else if signature, err := base64.RawURLEncoding.DecodeString(encoded); err != nil {
+		return nil, errors.New("malformed token")
+	} else if len(key) != ed25519.PublicKeySize || ed25519.Verify(key, []byte(payload), signature) == false {
+		return nil, errors.New("invalid token signature")
+	} else {
+		return ParseToken(token)
+	}
+}
+
+// Return the claims of the given capability token without verifying
+// its signature, so that clients can inspect their own tokens.
+//
+func ParseToken(token string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	payload, _, found := strings.Cut(token, ".")
+
+	if found == false {
+		return nil, errors.New("malformed token")
+	} else if mClaims, err := base64.RawURLEncoding.DecodeString(payload); err != nil {
+		return nil, errors.New("malformed token")
+	} else if err := json.Unmarshal(mClaims, claims); err != nil {
+		return nil, errors.New("malformed token")
+	} else if claims.Algorithm != Ed25519Signature {
+		return nil, errors.New("unsupported token algorithm")
+	} else {
+		return claims, nil
+	}
+}
+
+// Return whether the token is bound to the given Ed25519 public key
+// of a client.
+//
+func (claims *TokenClaims) Binds(key ed25519.PublicKey) bool {
+	if bound, err := DecodePublicKey(claims.Key); err != nil {
+		return false
+	} else {
+		return len(key) == ed25519.PublicKeySize && bytes.Equal(bound, key)
+	}
+}
+
+// Return whether the token permits the given client to call the given
+// method at the given time.
+//
+func (claims *TokenClaims) Permits(client string, method string, now time.Time) bool {
+	if claims.Expiry > 0 && now.Unix() > claims.Expiry {
+		return false
+	} else if claims.Client != "" && claims.Client != client {
+		return false
+	}
+
+	for _, unrestricted := range UnrestrictedMethods {
+		if method == unrestricted {
+			return true
+		}
+	}
+
+	for _, pattern := range claims.Methods {
+		if MatchMethod(pattern, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return whether the given method matches the given pattern of
+// permitted methods.
+//
+func MatchMethod(pattern string, method string) bool {
+	if pattern == "*" {
+		return true
+	} else if strings.HasSuffix(pattern, ".*") {
+		return strings.HasPrefix(method, strings.TrimSuffix(pattern, "*"))
+	} else {
+		return pattern == method
+	}
+}
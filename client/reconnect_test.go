@@ -0,0 +1,207 @@
+package mindctrl_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/broker"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Start a fake server on the broker at the given url, whose ping
+// handler blocks until the test finishes, and connect a new transport
+// with the given options to it. The returned channel receives a value
+// for every ping received by the server.
+//
+func startStalledServer(t *testing.T, url string, options *mindctrl.Options) (*mindctrltest.Server, *mindctrl.Transport, chan struct{}) {
+	t.Helper()
+
+	received := make(chan struct{}, 10)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	server, err := mindctrltest.NewServer(url, "test", nil)
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	server.Handle(protocol.PingMethod, func(input json.RawMessage) interface{} {
+		received <- struct{}{}
+		<-release
+		return protocol.GenericOutput{Success: true}
+	})
+
+	transport, err := server.NewTransport(options)
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+	return server, transport, received
+}
+
+func TestInflightCallsFailOnServerLoss(t *testing.T) {
+	options := &mindctrl.Options{Reconnect: true, ReconnectDelay: time.Hour}
+	_, url := startBroker(t, nil)
+	server, transport, received := startStalledServer(t, url, options)
+	future := mindctrl.Ping().Go(transport)
+
+	within(t, 10*time.Second, func() {
+		<-received
+	})
+
+	server.Close()
+
+	within(t, 10*time.Second, func() {
+		if err := future.Wait(context.Background()); errors.Is(err, codec.ErrServerDead) == false {
+			t.Errorf("call in flight fails with %v, expected %v", err, codec.ErrServerDead)
+		}
+	})
+
+	if err := mindctrl.Ping().Execute(transport); errors.Is(err, codec.ErrReconnecting) == false {
+		t.Errorf("call during reconnection fails with %v, expected %v", err, codec.ErrReconnecting)
+	}
+}
+
+func TestReconnectionGivesUpWithCause(t *testing.T) {
+	attempts := make(chan error, 10)
+
+	options := &mindctrl.Options{
+		Reconnect:         true,
+		ReconnectAttempts: 2,
+		ReconnectDelay:    10 * time.Millisecond,
+		OnReconnecting:    func(attempt int, cause error) { attempts <- cause },
+	}
+
+	b, url := startBroker(t, nil)
+	server, err := mindctrltest.NewServer(url, "test", nil)
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+	transport, err := server.NewTransport(options)
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+	b.Close()
+
+	within(t, 10*time.Second, func() {
+		<-transport.Done()
+	})
+
+	if err := transport.Err(); errors.Is(err, codec.ErrConnectionLost) == false {
+		t.Errorf("transport fails with %v, expected %v", err, codec.ErrConnectionLost)
+	}
+
+	if len(attempts) != 2 {
+		t.Errorf("%d reconnection attempts made, expected 2", len(attempts))
+	}
+}
+
+// Listener that keeps the connections it accepts, so that tests can
+// cut them to simulate the loss of the connection.
+//
+type cuttableListener struct {
+	net.Listener
+	mutex       sync.Mutex
+	connections []net.Conn
+}
+
+func (listener *cuttableListener) Accept() (net.Conn, error) {
+	if connection, err := listener.Listener.Accept(); err != nil {
+		return nil, err
+	} else {
+		listener.mutex.Lock()
+		defer listener.mutex.Unlock()
+		listener.connections = append(listener.connections, connection)
+		return connection, nil
+	}
+}
+
+// Close the connection accepted at the given position.
+//
+func (listener *cuttableListener) cut(index int) {
+	listener.mutex.Lock()
+	defer listener.mutex.Unlock()
+	listener.connections[index].Close()
+}
+
+func TestInflightCallsFailWithoutSession(t *testing.T) {
+	b := broker.NewBroker(nil)
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+
+	listener := &cuttableListener{Listener: inner}
+	go b.ServeTcp(listener)
+	t.Cleanup(func() { b.Close() })
+
+	options := &mindctrl.Options{
+		Reconnect:         true,
+		ReconnectDelay:    10 * time.Millisecond,
+		MqttQoS:           1,
+		MqttSessionExpiry: time.Minute,
+	}
+
+	_, transport, received := startStalledServer(t, fmt.Sprintf("mqtt://%s", inner.Addr()), options)
+	future := mindctrl.Ping().Go(transport)
+
+	within(t, 10*time.Second, func() {
+		<-received
+	})
+
+	listener.cut(1)
+
+	within(t, 10*time.Second, func() {
+		if err := future.Wait(context.Background()); errors.Is(err, codec.ErrConnectionLost) == false {
+			t.Errorf("call in flight fails with %v, expected %v", err, codec.ErrConnectionLost)
+		}
+	})
+}
+
+func TestConcurrentCallsWithQoS(t *testing.T) {
+	_, transport := startTransport(t, nil, &mindctrl.Options{MqttQoS: 2})
+	failures := make(chan error, 50)
+	group := sync.WaitGroup{}
+
+	for i := 0; i < 50; i++ {
+		group.Add(1)
+
+		go func() {
+			defer group.Done()
+
+			if _, err := mindctrl.FindTabs().Execute(transport); err != nil {
+				failures <- err
+			}
+		}()
+	}
+
+	within(t, 10*time.Second, func() {
+		group.Wait()
+	})
+
+	close(failures)
+
+	for err := range failures {
+		t.Errorf("concurrent call fails with %v", err)
+	}
+}
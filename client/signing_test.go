@@ -0,0 +1,134 @@
+package mindctrl_test
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"testing"
+	"time"
+)
+
+// Generate a new Ed25519 key pair for the test.
+//
+func generateKeys(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+
+	if public, private, err := ed25519.GenerateKey(nil); err != nil {
+		t.Fatalf("cannot generate keys: %v", err)
+		return nil, nil
+	} else {
+		return public, private
+	}
+}
+
+// Connect another transport with the given options to the given
+// server. The transport is closed when the test finishes.
+//
+func connectTransport(t *testing.T, server *mindctrltest.Server, options *mindctrl.Options) *mindctrl.Transport {
+	t.Helper()
+
+	transport, err := server.NewTransport(options)
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+	return transport
+}
+
+func TestSigningWithSecret(t *testing.T) {
+	server, transport := startTransport(t, &mindctrltest.Options{SigningSecret: "secret"}, nil)
+
+	if err := mindctrl.Ping().Execute(transport); err != nil {
+		t.Errorf("cannot ping with the shared secret: %v", err)
+	}
+
+	impostor := connectTransport(t, server, &mindctrl.Options{SigningSecret: "guess", RequestTimeout: 200 * time.Millisecond})
+
+	if err := mindctrl.Ping().Execute(impostor); errors.Is(err, codec.ErrTimeout) == false {
+		t.Errorf("ping with a wrong secret fails with %v, expected %v", err, codec.ErrTimeout)
+	}
+}
+
+func TestSigningWithKeys(t *testing.T) {
+	clientPublic, clientPrivate := generateKeys(t)
+	serverPublic, serverPrivate := generateKeys(t)
+	otherPublic, _ := generateKeys(t)
+
+	server, transport := startTransport(t, &mindctrltest.Options{SigningKey: serverPrivate, ClientPublicKey: clientPublic}, &mindctrl.Options{SigningKey: clientPrivate, ServerPublicKey: serverPublic})
+
+	if err := mindctrl.Ping().Execute(transport); err != nil {
+		t.Errorf("cannot ping with the key pairs: %v", err)
+	}
+
+	// Responses signed by the server fail the verification with the
+	// public key of another server, and are dropped.
+
+	misled := connectTransport(t, server, &mindctrl.Options{SigningKey: clientPrivate, ServerPublicKey: otherPublic, RequestTimeout: 200 * time.Millisecond})
+
+	if err := mindctrl.Ping().Execute(misled); errors.Is(err, codec.ErrTimeout) == false {
+		t.Errorf("ping verified with a wrong key fails with %v, expected %v", err, codec.ErrTimeout)
+	}
+}
+
+func TestSigningKeyRequiresServerKey(t *testing.T) {
+	clientPublic, clientPrivate := generateKeys(t)
+	_, serverPrivate := generateKeys(t)
+
+	server, err := mindctrltest.Start("test", &mindctrltest.Options{SigningKey: serverPrivate, ClientPublicKey: clientPublic})
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	if transport, err := server.NewTransport(&mindctrl.Options{SigningKey: clientPrivate}); err == nil {
+		transport.Close()
+		t.Errorf("transport created without the server key")
+	} else if errors.Is(err, codec.ErrMissingServerKey) == false {
+		t.Errorf("transport fails with %v, expected %v", err, codec.ErrMissingServerKey)
+	}
+
+	unverified := connectTransport(t, server, &mindctrl.Options{SigningKey: clientPrivate, SkipServerVerify: true})
+
+	if err := mindctrl.Ping().Execute(unverified); err != nil {
+		t.Errorf("cannot ping with verification skipped: %v", err)
+	}
+}
+
+func TestInvalidSigningKey(t *testing.T) {
+	clientPublic, clientPrivate := generateKeys(t)
+	serverPublic, serverPrivate := generateKeys(t)
+	issuerPublic, issuerPrivate := generateKeys(t)
+
+	server, err := mindctrltest.Start("test", &mindctrltest.Options{SigningKey: serverPrivate, ClientPublicKey: clientPublic, TokenKey: issuerPublic})
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	// Keys of the wrong size are refused up front, rather than
+	// panicking when the token is checked against them.
+
+	token := issueToken(t, issuerPrivate, clientPublic, "*")
+
+	for name, options := range map[string]*mindctrl.Options{
+		"short key":           {SigningKey: clientPrivate[:16], ServerPublicKey: serverPublic},
+		"long key":            {SigningKey: append(clientPrivate[:len(clientPrivate):len(clientPrivate)], 0), ServerPublicKey: serverPublic},
+		"short key and token": {SigningKey: clientPrivate[:16], ServerPublicKey: serverPublic, Token: token},
+		"public key as key":   {SigningKey: ed25519.PrivateKey(clientPublic), ServerPublicKey: serverPublic, Token: token},
+	} {
+		if transport, err := server.NewTransport(options); err == nil {
+			transport.Close()
+			t.Errorf("transport created with a %s", name)
+		} else if errors.Is(err, codec.ErrInvalidSigningKey) == false {
+			t.Errorf("transport with a %s fails with %v, expected %v", name, err, codec.ErrInvalidSigningKey)
+		}
+	}
+}
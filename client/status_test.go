@@ -0,0 +1,73 @@
+package mindctrl_test
+
+import (
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"testing"
+	"time"
+)
+
+func TestServerStatusFollowsHeartbeat(t *testing.T) {
+	serverOptions := &mindctrltest.Options{HeartbeatInterval: 50 * time.Millisecond}
+	options := &mindctrl.Options{HeartbeatInterval: 50 * time.Millisecond, HeartbeatMisses: 5}
+	_, transport := startTransport(t, serverOptions, options)
+	initial := transport.ServerStatus()
+
+	if initial.Alive == false {
+		t.Fatalf("server not alive after the transport is created")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	if status := transport.ServerStatus(); status.Alive == false {
+		t.Errorf("server not alive while it keeps beating")
+	} else if status.LastSeen.After(initial.LastSeen) == false {
+		t.Errorf("last seen time not updated by heartbeats")
+	}
+}
+
+func TestServerDeadOnStatusMessage(t *testing.T) {
+	_, url := startBroker(t, nil)
+	server, err := mindctrltest.NewServer(url, "test", nil)
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	transport, err := server.NewTransport(nil)
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+	server.Close()
+
+	within(t, 10*time.Second, func() {
+		<-transport.Done()
+	})
+
+	if err := transport.Err(); errors.Is(err, codec.ErrServerDead) == false {
+		t.Errorf("transport fails with %v, expected %v", err, codec.ErrServerDead)
+	} else if transport.ServerStatus().Alive {
+		t.Errorf("server still alive after its dead message")
+	}
+}
+
+func TestHeartbeatWatchdog(t *testing.T) {
+	serverOptions := &mindctrltest.Options{HeartbeatInterval: time.Hour}
+	options := &mindctrl.Options{HeartbeatInterval: 50 * time.Millisecond, HeartbeatMisses: 2}
+	_, transport := startTransport(t, serverOptions, options)
+
+	within(t, 10*time.Second, func() {
+		<-transport.Done()
+	})
+
+	if err := transport.Err(); errors.Is(err, codec.ErrServerDead) == false {
+		t.Errorf("transport fails with %v, expected %v", err, codec.ErrServerDead)
+	} else if transport.ServerStatus().Alive {
+		t.Errorf("server still alive after its heartbeat stops")
+	}
+}
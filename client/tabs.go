@@ -1,7 +1,7 @@
 package mindctrl
 
 import (
-	"errors"
+	"context"
 	"github.com/kmchan2018/mindctrl/client/protocol"
 )
 
@@ -195,25 +195,45 @@ func (op *FindTabsOperation) SetPinned(specified bool, pinned bool) *FindTabsOpe
 	}
 }
 
-func (op *FindTabsOperation) Start(transport *Transport, callback func(op *FindTabsOperation)) {
-	op.doStart(transport, protocol.FindTabsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FindTabsOperation) Start(transport Executor, callback func(op *FindTabsOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *FindTabsOperation) StartContext(ctx context.Context, transport Executor, callback func(op *FindTabsOperation)) {
+	op.doStart(ctx, transport, protocol.FindTabsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *FindTabsOperation) StartChannel(transport *Transport, channel chan *FindTabsOperation) {
-	op.doStart(transport, protocol.FindTabsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FindTabsOperation) StartChannel(transport Executor, channel chan *FindTabsOperation) {
+	op.doStart(context.Background(), transport, protocol.FindTabsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *FindTabsOperation) Execute(transport *Transport) ([]protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.FindTabsMethod, &op.input, &op.output); err != nil {
+func (op *FindTabsOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *FindTabsOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.FindTabsMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *FindTabsOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.FindTabsMethod, &op.input, &op.output)
+}
+
+func (op *FindTabsOperation) Execute(transport Executor) ([]protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *FindTabsOperation) ExecuteContext(ctx context.Context, transport Executor) ([]protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.FindTabsMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return op.output.Result, nil
 	}
@@ -222,8 +242,10 @@ func (op *FindTabsOperation) Execute(transport *Transport) ([]protocol.Tab, erro
 func (op *FindTabsOperation) Result() ([]protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return op.output.Result, nil
 	}
@@ -254,25 +276,45 @@ func (op *GetTabOperation) SetTabId(tabId int) *GetTabOperation {
 	return op
 }
 
-func (op *GetTabOperation) Start(transport *Transport, callback func(op *GetTabOperation)) {
-	op.doStart(transport, protocol.GetTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetTabOperation) Start(transport Executor, callback func(op *GetTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *GetTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *GetTabOperation)) {
+	op.doStart(ctx, transport, protocol.GetTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *GetTabOperation) StartChannel(transport *Transport, channel chan *GetTabOperation) {
-	op.doStart(transport, protocol.GetTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetTabOperation) StartChannel(transport Executor, channel chan *GetTabOperation) {
+	op.doStart(context.Background(), transport, protocol.GetTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *GetTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.GetTabMethod, &op.input, &op.output); err != nil {
+func (op *GetTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *GetTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.GetTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *GetTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.GetTabMethod, &op.input, &op.output)
+}
+
+func (op *GetTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *GetTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.GetTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -281,8 +323,10 @@ func (op *GetTabOperation) Execute(transport *Transport) (*protocol.Tab, error)
 func (op *GetTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -302,25 +346,45 @@ func GetCurrentTab() *GetCurrentTabOperation {
 	return op
 }
 
-func (op *GetCurrentTabOperation) Start(transport *Transport, callback func(op *GetCurrentTabOperation)) {
-	op.doStart(transport, protocol.GetCurrentTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetCurrentTabOperation) Start(transport Executor, callback func(op *GetCurrentTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *GetCurrentTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *GetCurrentTabOperation)) {
+	op.doStart(ctx, transport, protocol.GetCurrentTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *GetCurrentTabOperation) StartChannel(transport *Transport, channel chan *GetCurrentTabOperation) {
-	op.doStart(transport, protocol.GetCurrentTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetCurrentTabOperation) StartChannel(transport Executor, channel chan *GetCurrentTabOperation) {
+	op.doStart(context.Background(), transport, protocol.GetCurrentTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *GetCurrentTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.GetCurrentTabMethod, &op.input, &op.output); err != nil {
+func (op *GetCurrentTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *GetCurrentTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.GetCurrentTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *GetCurrentTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.GetCurrentTabMethod, &op.input, &op.output)
+}
+
+func (op *GetCurrentTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *GetCurrentTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.GetCurrentTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -329,8 +393,10 @@ func (op *GetCurrentTabOperation) Execute(transport *Transport) (*protocol.Tab,
 func (op *GetCurrentTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -438,25 +504,45 @@ func (op *CreateTabOperation) SetNoWait(specified bool, noWait bool) *CreateTabO
 	}
 }
 
-func (op *CreateTabOperation) Start(transport *Transport, callback func(op *CreateTabOperation)) {
-	op.doStart(transport, protocol.CreateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *CreateTabOperation) Start(transport Executor, callback func(op *CreateTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *CreateTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *CreateTabOperation)) {
+	op.doStart(ctx, transport, protocol.CreateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *CreateTabOperation) StartChannel(transport *Transport, channel chan *CreateTabOperation) {
-	op.doStart(transport, protocol.CreateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *CreateTabOperation) StartChannel(transport Executor, channel chan *CreateTabOperation) {
+	op.doStart(context.Background(), transport, protocol.CreateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *CreateTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.CreateTabMethod, &op.input, &op.output); err != nil {
+func (op *CreateTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *CreateTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.CreateTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *CreateTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.CreateTabMethod, &op.input, &op.output)
+}
+
+func (op *CreateTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *CreateTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.CreateTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -465,8 +551,10 @@ func (op *CreateTabOperation) Execute(transport *Transport) (*protocol.Tab, erro
 func (op *CreateTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -552,25 +640,45 @@ func (op *LoadTabOperation) SetNoWait(specified bool, noWait bool) *LoadTabOpera
 	}
 }
 
-func (op *LoadTabOperation) Start(transport *Transport, callback func(op *LoadTabOperation)) {
-	op.doStart(transport, protocol.LoadTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *LoadTabOperation) Start(transport Executor, callback func(op *LoadTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *LoadTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *LoadTabOperation)) {
+	op.doStart(ctx, transport, protocol.LoadTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *LoadTabOperation) StartChannel(transport *Transport, channel chan *LoadTabOperation) {
-	op.doStart(transport, protocol.LoadTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *LoadTabOperation) StartChannel(transport Executor, channel chan *LoadTabOperation) {
+	op.doStart(context.Background(), transport, protocol.LoadTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *LoadTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.LoadTabMethod, &op.input, &op.output); err != nil {
+func (op *LoadTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *LoadTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.LoadTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *LoadTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.LoadTabMethod, &op.input, &op.output)
+}
+
+func (op *LoadTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *LoadTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.LoadTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -579,8 +687,10 @@ func (op *LoadTabOperation) Execute(transport *Transport) (*protocol.Tab, error)
 func (op *LoadTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -655,25 +765,45 @@ func (op *ReloadTabOperation) SetNoWait(specified bool, noWait bool) *ReloadTabO
 	}
 }
 
-func (op *ReloadTabOperation) Start(transport *Transport, callback func(op *ReloadTabOperation)) {
-	op.doStart(transport, protocol.ReloadTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *ReloadTabOperation) Start(transport Executor, callback func(op *ReloadTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *ReloadTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *ReloadTabOperation)) {
+	op.doStart(ctx, transport, protocol.ReloadTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *ReloadTabOperation) StartChannel(transport *Transport, channel chan *ReloadTabOperation) {
-	op.doStart(transport, protocol.ReloadTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *ReloadTabOperation) StartChannel(transport Executor, channel chan *ReloadTabOperation) {
+	op.doStart(context.Background(), transport, protocol.ReloadTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *ReloadTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.ReloadTabMethod, &op.input, &op.output); err != nil {
+func (op *ReloadTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *ReloadTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.ReloadTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *ReloadTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.ReloadTabMethod, &op.input, &op.output)
+}
+
+func (op *ReloadTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *ReloadTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.ReloadTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -682,8 +812,10 @@ func (op *ReloadTabOperation) Execute(transport *Transport) (*protocol.Tab, erro
 func (op *ReloadTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -714,25 +846,45 @@ func (op *ActivateTabOperation) SetTabId(tabId int) *ActivateTabOperation {
 	return op
 }
 
-func (op *ActivateTabOperation) Start(transport *Transport, callback func(op *ActivateTabOperation)) {
-	op.doStart(transport, protocol.ActivateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *ActivateTabOperation) Start(transport Executor, callback func(op *ActivateTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *ActivateTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *ActivateTabOperation)) {
+	op.doStart(ctx, transport, protocol.ActivateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *ActivateTabOperation) StartChannel(transport *Transport, channel chan *ActivateTabOperation) {
-	op.doStart(transport, protocol.ActivateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *ActivateTabOperation) StartChannel(transport Executor, channel chan *ActivateTabOperation) {
+	op.doStart(context.Background(), transport, protocol.ActivateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *ActivateTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.ActivateTabMethod, &op.input, &op.output); err != nil {
+func (op *ActivateTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *ActivateTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.ActivateTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *ActivateTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.ActivateTabMethod, &op.input, &op.output)
+}
+
+func (op *ActivateTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *ActivateTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.ActivateTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -741,8 +893,10 @@ func (op *ActivateTabOperation) Execute(transport *Transport) (*protocol.Tab, er
 func (op *ActivateTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -773,25 +927,45 @@ func (op *DeactivateTabOperation) SetTabId(tabId int) *DeactivateTabOperation {
 	return op
 }
 
-func (op *DeactivateTabOperation) Start(transport *Transport, callback func(op *DeactivateTabOperation)) {
-	op.doStart(transport, protocol.DeactivateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *DeactivateTabOperation) Start(transport Executor, callback func(op *DeactivateTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *DeactivateTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *DeactivateTabOperation)) {
+	op.doStart(ctx, transport, protocol.DeactivateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *DeactivateTabOperation) StartChannel(transport *Transport, channel chan *DeactivateTabOperation) {
-	op.doStart(transport, protocol.DeactivateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *DeactivateTabOperation) StartChannel(transport Executor, channel chan *DeactivateTabOperation) {
+	op.doStart(context.Background(), transport, protocol.DeactivateTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *DeactivateTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.DeactivateTabMethod, &op.input, &op.output); err != nil {
+func (op *DeactivateTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *DeactivateTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.DeactivateTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *DeactivateTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.DeactivateTabMethod, &op.input, &op.output)
+}
+
+func (op *DeactivateTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *DeactivateTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.DeactivateTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -800,8 +974,10 @@ func (op *DeactivateTabOperation) Execute(transport *Transport) (*protocol.Tab,
 func (op *DeactivateTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -832,25 +1008,45 @@ func (op *MuteTabOperation) SetTabId(tabId int) *MuteTabOperation {
 	return op
 }
 
-func (op *MuteTabOperation) Start(transport *Transport, callback func(op *MuteTabOperation)) {
-	op.doStart(transport, protocol.MuteTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MuteTabOperation) Start(transport Executor, callback func(op *MuteTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *MuteTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *MuteTabOperation)) {
+	op.doStart(ctx, transport, protocol.MuteTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *MuteTabOperation) StartChannel(transport *Transport, channel chan *MuteTabOperation) {
-	op.doStart(transport, protocol.MuteTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MuteTabOperation) StartChannel(transport Executor, channel chan *MuteTabOperation) {
+	op.doStart(context.Background(), transport, protocol.MuteTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *MuteTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.MuteTabMethod, &op.input, &op.output); err != nil {
+func (op *MuteTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *MuteTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.MuteTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *MuteTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.MuteTabMethod, &op.input, &op.output)
+}
+
+func (op *MuteTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *MuteTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.MuteTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -859,8 +1055,10 @@ func (op *MuteTabOperation) Execute(transport *Transport) (*protocol.Tab, error)
 func (op *MuteTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -891,25 +1089,45 @@ func (op *UnmuteTabOperation) SetTabId(tabId int) *UnmuteTabOperation {
 	return op
 }
 
-func (op *UnmuteTabOperation) Start(transport *Transport, callback func(op *UnmuteTabOperation)) {
-	op.doStart(transport, protocol.UnmuteTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *UnmuteTabOperation) Start(transport Executor, callback func(op *UnmuteTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *UnmuteTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *UnmuteTabOperation)) {
+	op.doStart(ctx, transport, protocol.UnmuteTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *UnmuteTabOperation) StartChannel(transport *Transport, channel chan *UnmuteTabOperation) {
-	op.doStart(transport, protocol.UnmuteTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *UnmuteTabOperation) StartChannel(transport Executor, channel chan *UnmuteTabOperation) {
+	op.doStart(context.Background(), transport, protocol.UnmuteTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *UnmuteTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.UnmuteTabMethod, &op.input, &op.output); err != nil {
+func (op *UnmuteTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *UnmuteTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.UnmuteTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *UnmuteTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.UnmuteTabMethod, &op.input, &op.output)
+}
+
+func (op *UnmuteTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *UnmuteTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.UnmuteTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -918,8 +1136,10 @@ func (op *UnmuteTabOperation) Execute(transport *Transport) (*protocol.Tab, erro
 func (op *UnmuteTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -950,25 +1170,45 @@ func (op *PinTabOperation) SetTabId(tabId int) *PinTabOperation {
 	return op
 }
 
-func (op *PinTabOperation) Start(transport *Transport, callback func(op *PinTabOperation)) {
-	op.doStart(transport, protocol.PinTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *PinTabOperation) Start(transport Executor, callback func(op *PinTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *PinTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *PinTabOperation)) {
+	op.doStart(ctx, transport, protocol.PinTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *PinTabOperation) StartChannel(transport *Transport, channel chan *PinTabOperation) {
-	op.doStart(transport, protocol.PinTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *PinTabOperation) StartChannel(transport Executor, channel chan *PinTabOperation) {
+	op.doStart(context.Background(), transport, protocol.PinTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *PinTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.PinTabMethod, &op.input, &op.output); err != nil {
+func (op *PinTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *PinTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.PinTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *PinTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.PinTabMethod, &op.input, &op.output)
+}
+
+func (op *PinTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *PinTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.PinTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -977,8 +1217,10 @@ func (op *PinTabOperation) Execute(transport *Transport) (*protocol.Tab, error)
 func (op *PinTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -1009,25 +1251,45 @@ func (op *UnpinTabOperation) SetTabId(tabId int) *UnpinTabOperation {
 	return op
 }
 
-func (op *UnpinTabOperation) Start(transport *Transport, callback func(op *UnpinTabOperation)) {
-	op.doStart(transport, protocol.UnpinTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *UnpinTabOperation) Start(transport Executor, callback func(op *UnpinTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *UnpinTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *UnpinTabOperation)) {
+	op.doStart(ctx, transport, protocol.UnpinTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *UnpinTabOperation) StartChannel(transport *Transport, channel chan *UnpinTabOperation) {
-	op.doStart(transport, protocol.UnpinTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *UnpinTabOperation) StartChannel(transport Executor, channel chan *UnpinTabOperation) {
+	op.doStart(context.Background(), transport, protocol.UnpinTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *UnpinTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.UnpinTabMethod, &op.input, &op.output); err != nil {
+func (op *UnpinTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *UnpinTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.UnpinTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *UnpinTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.UnpinTabMethod, &op.input, &op.output)
+}
+
+func (op *UnpinTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *UnpinTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.UnpinTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -1036,8 +1298,10 @@ func (op *UnpinTabOperation) Execute(transport *Transport) (*protocol.Tab, error
 func (op *UnpinTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -1100,25 +1364,45 @@ func (op *MoveTabOperation) SetWindowId(specified bool, windowId int) *MoveTabOp
 	}
 }
 
-func (op *MoveTabOperation) Start(transport *Transport, callback func(op *MoveTabOperation)) {
-	op.doStart(transport, protocol.MoveTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MoveTabOperation) Start(transport Executor, callback func(op *MoveTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *MoveTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *MoveTabOperation)) {
+	op.doStart(ctx, transport, protocol.MoveTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *MoveTabOperation) StartChannel(transport *Transport, channel chan *MoveTabOperation) {
-	op.doStart(transport, protocol.MoveTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MoveTabOperation) StartChannel(transport Executor, channel chan *MoveTabOperation) {
+	op.doStart(context.Background(), transport, protocol.MoveTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *MoveTabOperation) Execute(transport *Transport) (*protocol.Tab, error) {
-	if err := op.doExecute(transport, protocol.MoveTabMethod, &op.input, &op.output); err != nil {
+func (op *MoveTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *MoveTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.MoveTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *MoveTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.MoveTabMethod, &op.input, &op.output)
+}
+
+func (op *MoveTabOperation) Execute(transport Executor) (*protocol.Tab, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *MoveTabOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Tab, error) {
+	if err := op.doExecute(ctx, transport, protocol.MoveTabMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -1127,8 +1411,10 @@ func (op *MoveTabOperation) Execute(transport *Transport) (*protocol.Tab, error)
 func (op *MoveTabOperation) Result() (*protocol.Tab, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -1159,25 +1445,45 @@ func (op *DiscardTabOperation) SetTabId(tabId int) *DiscardTabOperation {
 	return op
 }
 
-func (op *DiscardTabOperation) Start(transport *Transport, callback func(op *DiscardTabOperation)) {
-	op.doStart(transport, protocol.DiscardTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *DiscardTabOperation) Start(transport Executor, callback func(op *DiscardTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *DiscardTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *DiscardTabOperation)) {
+	op.doStart(ctx, transport, protocol.DiscardTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *DiscardTabOperation) StartChannel(transport *Transport, channel chan *DiscardTabOperation) {
-	op.doStart(transport, protocol.DiscardTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *DiscardTabOperation) StartChannel(transport Executor, channel chan *DiscardTabOperation) {
+	op.doStart(context.Background(), transport, protocol.DiscardTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *DiscardTabOperation) Execute(transport *Transport) error {
-	if err := op.doExecute(transport, protocol.DiscardTabMethod, &op.input, &op.output); err != nil {
+func (op *DiscardTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *DiscardTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.DiscardTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *DiscardTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.DiscardTabMethod, &op.input, &op.output)
+}
+
+func (op *DiscardTabOperation) Execute(transport Executor) error {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *DiscardTabOperation) ExecuteContext(ctx context.Context, transport Executor) error {
+	if err := op.doExecute(ctx, transport, protocol.DiscardTabMethod, &op.input, &op.output); err != nil {
 		return err
 	} else if op.output.Success == false {
-		return errors.New(op.output.Message)
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
@@ -1186,8 +1492,10 @@ func (op *DiscardTabOperation) Execute(transport *Transport) error {
 func (op *DiscardTabOperation) Result() error {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return err
+	} else if op.output.Success == false {
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
@@ -1218,25 +1526,45 @@ func (op *RemoveTabOperation) SetTabId(tabId int) *RemoveTabOperation {
 	return op
 }
 
-func (op *RemoveTabOperation) Start(transport *Transport, callback func(op *RemoveTabOperation)) {
-	op.doStart(transport, protocol.RemoveTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *RemoveTabOperation) Start(transport Executor, callback func(op *RemoveTabOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *RemoveTabOperation) StartContext(ctx context.Context, transport Executor, callback func(op *RemoveTabOperation)) {
+	op.doStart(ctx, transport, protocol.RemoveTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *RemoveTabOperation) StartChannel(transport *Transport, channel chan *RemoveTabOperation) {
-	op.doStart(transport, protocol.RemoveTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *RemoveTabOperation) StartChannel(transport Executor, channel chan *RemoveTabOperation) {
+	op.doStart(context.Background(), transport, protocol.RemoveTabMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *RemoveTabOperation) Execute(transport *Transport) error {
-	if err := op.doExecute(transport, protocol.RemoveTabMethod, &op.input, &op.output); err != nil {
+func (op *RemoveTabOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *RemoveTabOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.RemoveTabMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *RemoveTabOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.RemoveTabMethod, &op.input, &op.output)
+}
+
+func (op *RemoveTabOperation) Execute(transport Executor) error {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *RemoveTabOperation) ExecuteContext(ctx context.Context, transport Executor) error {
+	if err := op.doExecute(ctx, transport, protocol.RemoveTabMethod, &op.input, &op.output); err != nil {
 		return err
 	} else if op.output.Success == false {
-		return errors.New(op.output.Message)
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
@@ -1245,8 +1573,10 @@ func (op *RemoveTabOperation) Execute(transport *Transport) error {
 func (op *RemoveTabOperation) Result() error {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return err
+	} else if op.output.Success == false {
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
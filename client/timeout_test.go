@@ -0,0 +1,88 @@
+package mindctrl_test
+
+import (
+	"context"
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnectionRefused(t *testing.T) {
+	server, err := mindctrltest.Start("test", &mindctrltest.Options{Username: "user", Password: "secret"})
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	if transport, err := server.NewTransport(&mindctrl.Options{Username: "user", Password: "wrong"}); err == nil {
+		transport.Close()
+		t.Errorf("transport created with wrong credentials")
+	} else if errors.Is(err, codec.ErrConnectionRefused) == false {
+		t.Errorf("transport fails with %v, expected %v", err, codec.ErrConnectionRefused)
+	} else if strings.Contains(err.Error(), "0x86") == false {
+		t.Errorf("error %q does not name the reason code", err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	_, url := startBroker(t, nil)
+	_, transport, _ := startStalledServer(t, url, &mindctrl.Options{RequestTimeout: 100 * time.Millisecond})
+
+	within(t, 10*time.Second, func() {
+		if err := mindctrl.Ping().Execute(transport); errors.Is(err, codec.ErrTimeout) == false {
+			t.Errorf("call fails with %v, expected %v", err, codec.ErrTimeout)
+		}
+	})
+
+	if err := transport.Err(); err != nil {
+		t.Errorf("transport fails with %v after a call times out", err)
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	_, url := startBroker(t, nil)
+	_, transport, _ := startStalledServer(t, url, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	within(t, 10*time.Second, func() {
+		if err := mindctrl.Ping().ExecuteContext(ctx, transport); err != context.DeadlineExceeded {
+			t.Errorf("call fails with %v, expected %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestAbandonedCall(t *testing.T) {
+	_, url := startBroker(t, nil)
+	_, _, received := startStalledServer(t, url, nil)
+	c, err := codec.NewCodec(url, "abandoning", "test", nil)
+
+	if err != nil {
+		t.Fatalf("cannot create codec: %v", err)
+	}
+
+	client := rpc.NewClientWithCodec(c)
+	t.Cleanup(func() { client.Close() })
+
+	// The call is given up while the server is stuck on it, and net/rpc
+	// forgets the call at once instead of waiting for the response.
+
+	input := &codec.Abandonable{Input: protocol.PingInput{}}
+	call := client.Go(protocol.PingMethod, input, &protocol.PingOutput{}, make(chan *rpc.Call, 1))
+	<-received
+	c.Abandon(input)
+
+	within(t, 10*time.Second, func() {
+		if err := codec.RestoreError((<-call.Done).Error); errors.Is(err, codec.ErrAbandoned) == false {
+			t.Errorf("abandoned call fails with %v, expected %v", err, codec.ErrAbandoned)
+		}
+	})
+}
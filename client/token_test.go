@@ -0,0 +1,83 @@
+package mindctrl_test
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"testing"
+)
+
+// Issue a capability token permitting the given methods and bound to
+// the given public key of the client.
+//
+func issueToken(t *testing.T, issuer ed25519.PrivateKey, client ed25519.PublicKey, methods ...string) string {
+	t.Helper()
+
+	if token, err := protocol.IssueToken(protocol.TokenClaims{Key: protocol.EncodePublicKey(client), Methods: methods}, issuer); err != nil {
+		t.Fatalf("cannot issue token: %v", err)
+		return ""
+	} else {
+		return token
+	}
+}
+
+func TestToken(t *testing.T) {
+	clientPublic, clientPrivate := generateKeys(t)
+	serverPublic, serverPrivate := generateKeys(t)
+	issuerPublic, issuerPrivate := generateKeys(t)
+
+	serverOptions := &mindctrltest.Options{SigningKey: serverPrivate, ClientPublicKey: clientPublic, TokenKey: issuerPublic}
+	options := &mindctrl.Options{SigningKey: clientPrivate, ServerPublicKey: serverPublic, Token: issueToken(t, issuerPrivate, clientPublic, "tabs.*")}
+	_, transport := startTransport(t, serverOptions, options)
+
+	if _, err := mindctrl.FindTabs().Execute(transport); err != nil {
+		t.Errorf("cannot call a permitted method: %v", err)
+	}
+
+	if _, err := mindctrl.FindWindows().Execute(transport); errors.Is(err, mindctrl.ErrForbidden) == false {
+		t.Errorf("call to a method not permitted fails with %v, expected %v", err, mindctrl.ErrForbidden)
+	}
+}
+
+func TestTokenMustBeBound(t *testing.T) {
+	clientPublic, clientPrivate := generateKeys(t)
+	serverPublic, serverPrivate := generateKeys(t)
+	issuerPublic, issuerPrivate := generateKeys(t)
+	otherPublic, _ := generateKeys(t)
+
+	server, err := mindctrltest.Start("test", &mindctrltest.Options{SigningKey: serverPrivate, ClientPublicKey: clientPublic, TokenKey: issuerPublic})
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	// Tokens bound to another key, and tokens without signing, are
+	// refused before any call is made.
+
+	for name, options := range map[string]*mindctrl.Options{
+		"another key": {SigningKey: clientPrivate, ServerPublicKey: serverPublic, Token: issueToken(t, issuerPrivate, otherPublic, "*")},
+		"no signing":  {Token: issueToken(t, issuerPrivate, clientPublic, "*")},
+		"secret":      {SigningSecret: "secret", Token: issueToken(t, issuerPrivate, clientPublic, "*")},
+	} {
+		if transport, err := server.NewTransport(options); err == nil {
+			transport.Close()
+			t.Errorf("transport created with a token and %s", name)
+		} else if errors.Is(err, codec.ErrUnboundToken) == false {
+			t.Errorf("transport with a token and %s fails with %v, expected %v", name, err, codec.ErrUnboundToken)
+		}
+	}
+}
+
+func TestTokenKeyRequiresSigningKeys(t *testing.T) {
+	issuerPublic, _ := generateKeys(t)
+
+	if server, err := mindctrltest.Start("test", &mindctrltest.Options{TokenKey: issuerPublic}); err == nil {
+		server.Close()
+		t.Errorf("fake server started with a token key but without signing keys")
+	}
+}
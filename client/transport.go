@@ -1,74 +1,402 @@
 package mindctrl
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/kmchan2018/mindctrl/client/codec"
+	"github.com/kmchan2018/mindctrl/client/protocol"
 	"net/rpc"
+	"reflect"
+	"sync"
 )
 
+// Error reported by [NewTransport] in strict mode to indicate that
+// the server speaks a protocol version other than the one of this
+// package. See the 'StrictProtocol' option.
+//
+var ErrIncompatibleProtocol = errors.New("incompatible protocol version")
+
 // Options contains additional data for the transport.
 //
 type Options = codec.Options
 
+// ServerStatus contains the status of the server as observed by the
+// transport, including the time when the last heartbeat is received.
+//
+type ServerStatus = codec.Status
+
 // Callback is function that is invoked after an async call has
 // finished.
 //
 type Callback = func(method string, args interface{}, reply interface{}, err error)
 
-// Transport handles communication with the server. Note that the
-// transport implementation is NOT thread-safe and therefore
-// should not be used in multiple goroutines.
+// Transport handles communication with the server. The transport
+// is safe for concurrent use by multiple goroutines; operations
+// can be executed or started from any goroutine.
+//
+// Completed asynchronous calls are routed by a background goroutine
+// owned by the transport, which invokes the callbacks one at a time
+// in the order the calls are completed. Callbacks should therefore
+// avoid blocking for a long time, or they will delay the callbacks
+// of other calls. The goroutine only runs while there are async
+// calls outstanding.
+//
+// Every call goes through the interceptors registered when the
+// transport is created; see [Interceptor] for details.
 //
 type Transport struct {
-	client  *rpc.Client
-	channel chan *rpc.Call
-	pending map[*rpc.Call]Callback
+	codec        transportCodec
+	client       *rpc.Client
+	invoker      Invoker
+	interceptors []Interceptor
+	intercepted  bool
+	capabilities *protocol.Capabilities
+	channel      chan completion
+	mutex        sync.Mutex
+	cond         *sync.Cond
+	pending      int
+	completed    uint64
+	dispatching  bool
+	invoking     bool
+}
+
+// TransportCodec is the codec used by the transport, which is either
+// [codec.Codec] talking to the server or [codec.ReplayCodec] serving
+// responses from a cassette.
+//
+type transportCodec interface {
+	rpc.ClientCodec
+	Status() codec.Status
+	Done() <-chan struct{}
+	Err() error
+}
+
+// Completion is a finished asynchronous call waiting to be routed
+// to its callback by the dispatcher goroutine.
+//
+type completion struct {
+	call     *rpc.Call
+	callback Callback
 }
 
-// Create a new transport with the given options.
+// Create a new transport with the given options. The url points to
+// the intermediate MQTT broker, and can use ws or wss scheme for
+// MQTT over websocket, mqtt or tcp scheme for plain MQTT over TCP,
+// and mqtts or ssl scheme for MQTT over TLS.
 //
-func NewTransport(url string, client string, server string, options *Options) (*Transport, error) {
-	if c, err := codec.NewCodec(url, client, server, options); err != nil {
+// If a cassette to record to is given in the options, every call
+// made over the transport is recorded together with its response.
+// If a cassette to replay from is given instead, the transport does
+// not connect to the broker at all; the url, client and server are
+// ignored and the calls are answered from the cassette.
+//
+// The interceptors, if any, wrap every call made over the transport
+// in the given order; the first one is the outermost.
+//
+// If the 'StrictProtocol' option is set, the function also checks
+// the protocol version of the server and fails with the error
+// [ErrIncompatibleProtocol] if the server speaks another version.
+//
+func NewTransport(url string, client string, server string, options *Options, interceptors ...Interceptor) (*Transport, error) {
+	return NewTransportContext(context.Background(), url, client, server, options, interceptors...)
+}
+
+// Create a new transport with the given options. The given context
+// controls the connection phase; if it is cancelled or its deadline
+// passes before the server is found alive, the function will give
+// up and return the context error.
+//
+func NewTransportContext(ctx context.Context, url string, client string, server string, options *Options, interceptors ...Interceptor) (*Transport, error) {
+	if options != nil && options.ReplayFile != "" {
+		if c, err := codec.NewReplayCodec(options.ReplayFile, options); err != nil {
+			return nil, err
+		} else {
+			return newTransport(c, interceptors), nil
+		}
+	} else if c, err := codec.NewCodecContext(ctx, url, client, server, options); err != nil {
+		return nil, err
+	} else if transport := newTransport(c, interceptors); options == nil || options.StrictProtocol == false {
+		return transport, nil
+	} else if err := transport.negotiate(ctx); err != nil {
+		transport.Close()
 		return nil, err
 	} else {
-		return &Transport{
-			client:  rpc.NewClientWithCodec(c),
-			channel: make(chan *rpc.Call, 100),
-			pending: make(map[*rpc.Call]Callback),
-		}, nil
+		return transport, nil
+	}
+}
+
+// Check that the server speaks the protocol version of this package.
+// Servers that predate info.get_capabilities report the method as
+// unknown, and are considered incompatible as well. Failures of the
+// call itself, like timeouts, are returned as is. The capabilities of
+// a compatible server are kept for batches.
+//
+func (transport *Transport) negotiate(ctx context.Context) error {
+	op := GetCapabilities()
+
+	if capabilities, err := op.ExecuteContext(ctx, transport); op.doGetError() != nil {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("%w: %s", ErrIncompatibleProtocol, err)
+	} else if capabilities.ProtocolVersion != protocol.ProtocolVersion {
+		return fmt.Errorf("%w: server speaks version %d instead of %d", ErrIncompatibleProtocol, capabilities.ProtocolVersion, protocol.ProtocolVersion)
+	} else {
+		transport.mutex.Lock()
+		transport.capabilities = capabilities
+		transport.mutex.Unlock()
+		return nil
 	}
 }
 
-// Call a remote method synchronously.
+// Create a new transport over the given codec.
 //
-func (transport *Transport) call(method string, args interface{}, reply interface{}) error {
-	return transport.client.Call(method, args, reply)
+func newTransport(c transportCodec, interceptors []Interceptor) *Transport {
+	transport := &Transport{
+		codec:        c,
+		client:       rpc.NewClientWithCodec(c),
+		interceptors: interceptors,
+		intercepted:  len(interceptors) > 0,
+		channel:      make(chan completion, 100),
+	}
+
+	transport.invoker = chainInterceptors(interceptors, transport.invoke)
+	transport.cond = sync.NewCond(&transport.mutex)
+	return transport
 }
 
-// Call a remote method asynchronously.
+// Return the status of the server, including whether the server is
+// considered alive and the time when its last heartbeat is received.
 //
-func (transport *Transport) start(method string, args interface{}, reply interface{}, callback Callback) {
-	call := transport.client.Go(method, args, reply, transport.channel)
-	transport.pending[call] = callback
+func (transport *Transport) ServerStatus() ServerStatus {
+	return transport.codec.Status()
 }
 
-// Watch for a single completed asynchronous call and invoke the
-// corresponding callback function. Return true if there are still
-// outstanding async calls to watch, and false otherwise.
+// Close the transport and the connection to the intermediate MQTT
+// broker. Calls in flight fail with the error [codec.ErrClosed],
+// and so do calls made afterwards. Callbacks of async calls in
+// flight are still invoked with the error.
 //
-func (transport *Transport) Dispatch() bool {
-	for {
-		if remainder := len(transport.pending); remainder > 0 {
-			call := <-transport.channel
-
-			if callback, found := transport.pending[call]; found == false {
-				continue
-			} else {
-				callback(call.ServiceMethod, call.Args, call.Reply, call.Error)
-				delete(transport.pending, call)
-				return remainder > 1
+// Closing the transport more than once returns [codec.ErrClosed].
+//
+func (transport *Transport) Close() error {
+	if err := transport.client.Close(); err == rpc.ErrShutdown {
+		return codec.ErrClosed
+	} else {
+		return err
+	}
+}
+
+// Return a channel that is closed when the transport can no longer
+// be used, either because it is closed or because the server or
+// the broker connection is lost and cannot be recovered.
+//
+func (transport *Transport) Done() <-chan struct{} {
+	return transport.codec.Done()
+}
+
+// Return the error that made the transport unusable, or nil if the
+// transport is still usable. The error is [codec.ErrClosed] if the
+// transport is closed.
+//
+func (transport *Transport) Err() error {
+	return transport.codec.Err()
+}
+
+// Translate the error of a finished call. Errors reported by the
+// codec are restored, and the generic shutdown error from net/rpc
+// is replaced by the error that made the transport unusable.
+//
+func (transport *Transport) translate(err error) error {
+	if err != rpc.ErrShutdown {
+		return codec.RestoreError(err)
+	} else if cause := transport.codec.Err(); cause != nil {
+		return cause
+	} else {
+		return err
+	}
+}
+
+// Call a remote method synchronously through the interceptors. If
+// the context is cancelled or its deadline passes before the call
+// is finished, the call is abandoned and the context error is
+// returned.
+//
+func (transport *Transport) call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	return transport.invoker(ctx, method, args, reply)
+}
+
+// Call a remote method synchronously without the interceptors. It
+// is the innermost invoker of the interceptor chain.
+//
+func (transport *Transport) invoke(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	if ctx.Done() == nil {
+		return transport.translate(transport.client.Call(method, args, reply))
+	} else if err := ctx.Err(); err != nil {
+		return err
+	} else {
+		shadow := newShadowReply(reply)
+		call, abandon := transport.goAbandonable(method, args, shadow)
+
+		select {
+		case <-call.Done:
+			reflect.ValueOf(reply).Elem().Set(shadow.Elem())
+			return transport.translate(call.Error)
+		case <-ctx.Done():
+			abandon()
+			return ctx.Err()
+		}
+	}
+}
+
+// Start a call that the caller may abandon, decoding the response into
+// the given shadow reply. The returned function abandons the call, so
+// that neither the codec nor net/rpc keeps it when the server never
+// answers. It does nothing for the replay codec, which answers every
+// call at once.
+//
+func (transport *Transport) goAbandonable(method string, args interface{}, shadow reflect.Value) (*rpc.Call, func()) {
+	if c, ok := transport.codec.(*codec.Codec); ok {
+		input := &codec.Abandonable{Input: args}
+		call := transport.client.Go(method, input, shadow.Interface(), make(chan *rpc.Call, 1))
+		return call, func() { c.Abandon(input) }
+	} else {
+		call := transport.client.Go(method, args, shadow.Interface(), make(chan *rpc.Call, 1))
+		return call, func() {}
+	}
+}
+
+// Call a remote method asynchronously. The callback will be invoked
+// by the dispatcher goroutine after the call is finished. If the
+// context is cancelled or its deadline passes before the call is
+// finished, the call is abandoned and the callback is invoked with
+// the context error.
+//
+// If the transport has any interceptor, the call goes through the
+// interceptor chain in a goroutine of its own.
+//
+func (transport *Transport) start(ctx context.Context, method string, args interface{}, reply interface{}, callback Callback) {
+	transport.mutex.Lock()
+	transport.pending++
+
+	if transport.dispatching == false {
+		transport.dispatching = true
+		go transport.dispatch()
+	}
+
+	transport.mutex.Unlock()
+
+	if transport.intercepted {
+		go func() {
+			err := transport.invoker(ctx, method, args, reply)
+			call := &rpc.Call{ServiceMethod: method, Args: args, Reply: reply, Error: err}
+			transport.channel <- completion{call: call, callback: callback}
+		}()
+	} else if ctx.Done() == nil {
+		call := transport.client.Go(method, args, reply, make(chan *rpc.Call, 1))
+
+		go func() {
+			<-call.Done
+			call.Error = transport.translate(call.Error)
+			transport.channel <- completion{call: call, callback: callback}
+		}()
+	} else {
+		shadow := newShadowReply(reply)
+		proxy := &rpc.Call{ServiceMethod: method, Args: args, Reply: reply}
+		call, abandon := transport.goAbandonable(method, args, shadow)
+
+		go func() {
+			select {
+			case <-call.Done:
+				reflect.ValueOf(reply).Elem().Set(shadow.Elem())
+				proxy.Error = transport.translate(call.Error)
+			case <-ctx.Done():
+				abandon()
+				proxy.Error = ctx.Err()
 			}
-		} else {
-			return false
+
+			transport.channel <- completion{call: proxy, callback: callback}
+		}()
+	}
+}
+
+// Route completed asynchronous calls to their callbacks. The function
+// runs in its own goroutine, which is started when an async call is
+// made and no goroutine is running, and exits as soon as there is
+// no outstanding async call. Hence, no goroutine is left behind
+// after the transport is closed.
+//
+func (transport *Transport) dispatch() {
+	for completed := range transport.channel {
+		call := completed.call
+
+		transport.mutex.Lock()
+		transport.invoking = true
+		transport.mutex.Unlock()
+
+		completed.callback(call.ServiceMethod, call.Args, call.Reply, call.Error)
+
+		transport.mutex.Lock()
+		transport.invoking = false
+		transport.pending--
+		transport.completed++
+		transport.cond.Broadcast()
+
+		if transport.pending == 0 {
+			transport.dispatching = false
+			transport.mutex.Unlock()
+			return
+		}
+
+		transport.mutex.Unlock()
+	}
+}
+
+// Wait for a single asynchronous call to complete. Return true if
+// there are still outstanding async calls to watch, and false
+// otherwise.
+//
+// Callbacks are invoked by the dispatcher goroutine of the transport
+// and therefore pumping this function is no longer required. The
+// function is kept for compatibility with code that loops over it
+// to wait for all outstanding calls to finish.
+//
+// A callback calling the function would wait for the dispatcher
+// goroutine running the callback itself. To avoid waiting forever,
+// the function returns false immediately while a callback is being
+// invoked, so that loops over the function in callbacks end at once.
+// The transport cannot tell callbacks from other goroutines, so the
+// function may also return early when called by another goroutine
+// while a callback is running. Code that must wait for every call
+// should wait for their futures with [WaitAll] instead.
+//
+func (transport *Transport) Dispatch() bool {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	if transport.pending == 0 {
+		return false
+	} else if transport.invoking {
+		return false
+	} else {
+		target := transport.completed + 1
+
+		for transport.completed < target {
+			transport.cond.Wait()
 		}
+
+		return transport.pending > 0
 	}
 }
+
+// Create a shadow copy of the given reply. Calls that can be abandoned
+// decode their response into the shadow copy instead of the original
+// reply, so that a late response cannot modify the reply after the
+// caller has moved on.
+//
+func newShadowReply(reply interface{}) reflect.Value {
+	original := reflect.ValueOf(reply).Elem()
+	shadow := reflect.New(original.Type())
+	shadow.Elem().Set(original)
+	return shadow
+}
@@ -0,0 +1,226 @@
+package mindctrl_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kmchan2018/mindctrl/client"
+	"github.com/kmchan2018/mindctrl/client/broker"
+	"github.com/kmchan2018/mindctrl/client/mindctrltest"
+	"github.com/kmchan2018/mindctrl/client/protocol"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Start a fake server for the test, and connect a new transport to it
+// with the given options and interceptors. Both are closed when the
+// test finishes.
+//
+func startTransport(t *testing.T, serverOptions *mindctrltest.Options, options *mindctrl.Options, interceptors ...mindctrl.Interceptor) (*mindctrltest.Server, *mindctrl.Transport) {
+	t.Helper()
+
+	server, err := mindctrltest.Start("test", serverOptions)
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+	transport, err := server.NewTransport(options, interceptors...)
+
+	if err != nil {
+		t.Fatalf("cannot create transport: %v", err)
+	}
+
+	t.Cleanup(func() { transport.Close() })
+	return server, transport
+}
+
+// Start a broker accepting websocket connections on a random port of
+// the loopback interface, and return it together with its url. The
+// broker is closed when the test finishes unless it is closed by the
+// test itself.
+//
+func startBroker(t *testing.T, options *broker.Options) (*broker.Broker, string) {
+	t.Helper()
+
+	b := broker.NewBroker(options)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+
+	go b.ServeWebsocket(listener)
+	t.Cleanup(func() { b.Close() })
+	return b, fmt.Sprintf("ws://%s", listener.Addr())
+}
+
+// Run the given function and fail the test if it does not return
+// within the given duration.
+//
+func within(t *testing.T, duration time.Duration, function func()) {
+	t.Helper()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		function()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(duration):
+		t.Fatalf("not finished within %v", duration)
+	}
+}
+
+func TestCallbacksAreDispatched(t *testing.T) {
+	_, transport := startTransport(t, nil, nil)
+	finished := make(chan error, 10)
+
+	for i := 0; i < 10; i++ {
+		mindctrl.Ping().Start(transport, func(op *mindctrl.PingOperation) {
+			finished <- op.Result()
+		})
+	}
+
+	for i := 0; i < 10; i++ {
+		select {
+		case err := <-finished:
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("callbacks not invoked without Dispatch")
+		}
+	}
+}
+
+func TestDispatchWaitsForOutstandingCalls(t *testing.T) {
+	server, transport := startTransport(t, nil, nil)
+	release := make(chan struct{})
+	count := int32(0)
+
+	// The server holds the call until Dispatch is waiting, so that no
+	// callback is running when Dispatch is called.
+
+	server.Handle(protocol.PingMethod, func(input json.RawMessage) interface{} {
+		<-release
+		return protocol.GenericOutput{Success: true}
+	})
+
+	mindctrl.Ping().Start(transport, func(op *mindctrl.PingOperation) {
+		atomic.AddInt32(&count, 1)
+	})
+
+	time.AfterFunc(100*time.Millisecond, func() { close(release) })
+
+	within(t, 10*time.Second, func() {
+		for transport.Dispatch() {
+		}
+	})
+
+	if count := atomic.LoadInt32(&count); count != 1 {
+		t.Errorf("%d callbacks invoked after Dispatch returns false, expected 1", count)
+	}
+
+	if transport.Dispatch() {
+		t.Errorf("Dispatch returns true without outstanding calls")
+	}
+}
+
+func TestDispatchFromCallback(t *testing.T) {
+	_, transport := startTransport(t, nil, nil)
+	results := make(chan bool, 2)
+
+	for i := 0; i < 2; i++ {
+		mindctrl.Ping().Start(transport, func(op *mindctrl.PingOperation) {
+			results <- transport.Dispatch()
+		})
+	}
+
+	within(t, 10*time.Second, func() {
+		for i := 0; i < 2; i++ {
+			if <-results {
+				t.Errorf("Dispatch returns true in callback")
+			}
+		}
+	})
+}
+
+func TestInterceptedCallbackError(t *testing.T) {
+	expected := errors.New("intercepted")
+
+	interceptor := func(ctx context.Context, method string, input interface{}, output interface{}, invoke mindctrl.Invoker) error {
+		if err := invoke(ctx, method, input, output); err != nil {
+			return err
+		} else {
+			return expected
+		}
+	}
+
+	_, transport := startTransport(t, nil, nil, interceptor)
+	finished := make(chan error, 1)
+
+	mindctrl.Ping().Start(transport, func(op *mindctrl.PingOperation) {
+		finished <- op.Result()
+	})
+
+	within(t, 10*time.Second, func() {
+		if err := <-finished; err != expected {
+			t.Errorf("callback receives %v, expected the error of the interceptor", err)
+		}
+	})
+}
+
+func TestStrictProtocol(t *testing.T) {
+	server, err := mindctrltest.Start("test", nil)
+
+	if err != nil {
+		t.Fatalf("cannot start fake server: %v", err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	if transport, err := server.NewTransport(&mindctrl.Options{StrictProtocol: true}); err != nil {
+		t.Errorf("transport refused by a server speaking the same version: %v", err)
+	} else {
+		transport.Close()
+	}
+
+	// Servers speaking another version, and servers that predate
+	// info.get_capabilities, are refused.
+
+	server.Handle(protocol.GetCapabilitiesMethod, func(input json.RawMessage) interface{} {
+		capabilities := protocol.Capabilities{ProtocolVersion: protocol.ProtocolVersion + 1}
+		return protocol.GetCapabilitiesOutput{GenericOutput: protocol.GenericOutput{Success: true}, Result: capabilities}
+	})
+
+	if transport, err := server.NewTransport(&mindctrl.Options{StrictProtocol: true}); err == nil {
+		transport.Close()
+		t.Errorf("transport created with a server speaking another version")
+	} else if errors.Is(err, mindctrl.ErrIncompatibleProtocol) == false {
+		t.Errorf("transport fails with %v, expected %v", err, mindctrl.ErrIncompatibleProtocol)
+	}
+
+	server.Handle(protocol.GetCapabilitiesMethod, nil)
+
+	if transport, err := server.NewTransport(&mindctrl.Options{StrictProtocol: true}); err == nil {
+		transport.Close()
+		t.Errorf("transport created with a server lacking %s", protocol.GetCapabilitiesMethod)
+	} else if errors.Is(err, mindctrl.ErrIncompatibleProtocol) == false {
+		t.Errorf("transport fails with %v, expected %v", err, mindctrl.ErrIncompatibleProtocol)
+	}
+
+	// Without the option, the version is not checked.
+
+	if transport, err := server.NewTransport(nil); err != nil {
+		t.Errorf("transport refused without strict protocol: %v", err)
+	} else {
+		transport.Close()
+	}
+}
@@ -1,7 +1,7 @@
 package mindctrl
 
 import (
-	"errors"
+	"context"
 	"github.com/kmchan2018/mindctrl/client/protocol"
 )
 
@@ -19,25 +19,45 @@ func FindWindows() *FindWindowsOperation {
 	return op
 }
 
-func (op *FindWindowsOperation) Start(transport *Transport, callback func(op *FindWindowsOperation)) {
-	op.doStart(transport, protocol.FindWindowsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FindWindowsOperation) Start(transport Executor, callback func(op *FindWindowsOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *FindWindowsOperation) StartContext(ctx context.Context, transport Executor, callback func(op *FindWindowsOperation)) {
+	op.doStart(ctx, transport, protocol.FindWindowsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *FindWindowsOperation) StartChannel(transport *Transport, channel chan *FindWindowsOperation) {
-	op.doStart(transport, protocol.FindWindowsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FindWindowsOperation) StartChannel(transport Executor, channel chan *FindWindowsOperation) {
+	op.doStart(context.Background(), transport, protocol.FindWindowsMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *FindWindowsOperation) Execute(transport *Transport) ([]protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.FindWindowsMethod, &op.input, &op.output); err != nil {
+func (op *FindWindowsOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *FindWindowsOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.FindWindowsMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *FindWindowsOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.FindWindowsMethod, &op.input, &op.output)
+}
+
+func (op *FindWindowsOperation) Execute(transport Executor) ([]protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *FindWindowsOperation) ExecuteContext(ctx context.Context, transport Executor) ([]protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.FindWindowsMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return op.output.Result, nil
 	}
@@ -46,8 +66,10 @@ func (op *FindWindowsOperation) Execute(transport *Transport) ([]protocol.Window
 func (op *FindWindowsOperation) Result() ([]protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return op.output.Result, nil
 	}
@@ -78,25 +100,45 @@ func (op *GetWindowOperation) SetWindowId(windowId int) *GetWindowOperation {
 	return op
 }
 
-func (op *GetWindowOperation) Start(transport *Transport, callback func(op *GetWindowOperation)) {
-	op.doStart(transport, protocol.GetWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetWindowOperation) Start(transport Executor, callback func(op *GetWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *GetWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *GetWindowOperation)) {
+	op.doStart(ctx, transport, protocol.GetWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *GetWindowOperation) StartChannel(transport *Transport, channel chan *GetWindowOperation) {
-	op.doStart(transport, protocol.GetWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetWindowOperation) StartChannel(transport Executor, channel chan *GetWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.GetWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *GetWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.GetWindowMethod, &op.input, &op.output); err != nil {
+func (op *GetWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *GetWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.GetWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *GetWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.GetWindowMethod, &op.input, &op.output)
+}
+
+func (op *GetWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *GetWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.GetWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -105,8 +147,10 @@ func (op *GetWindowOperation) Execute(transport *Transport) (*protocol.Window, e
 func (op *GetWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -126,25 +170,45 @@ func GetCurrentWindow() *GetCurrentWindowOperation {
 	return op
 }
 
-func (op *GetCurrentWindowOperation) Start(transport *Transport, callback func(op *GetCurrentWindowOperation)) {
-	op.doStart(transport, protocol.GetCurrentWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetCurrentWindowOperation) Start(transport Executor, callback func(op *GetCurrentWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *GetCurrentWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *GetCurrentWindowOperation)) {
+	op.doStart(ctx, transport, protocol.GetCurrentWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *GetCurrentWindowOperation) StartChannel(transport *Transport, channel chan *GetCurrentWindowOperation) {
-	op.doStart(transport, protocol.GetCurrentWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *GetCurrentWindowOperation) StartChannel(transport Executor, channel chan *GetCurrentWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.GetCurrentWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *GetCurrentWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.GetCurrentWindowMethod, &op.input, &op.output); err != nil {
+func (op *GetCurrentWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *GetCurrentWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.GetCurrentWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *GetCurrentWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.GetCurrentWindowMethod, &op.input, &op.output)
+}
+
+func (op *GetCurrentWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *GetCurrentWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.GetCurrentWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -153,8 +217,10 @@ func (op *GetCurrentWindowOperation) Execute(transport *Transport) (*protocol.Wi
 func (op *GetCurrentWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -174,25 +240,45 @@ func CreateWindow() *CreateWindowOperation {
 	return op
 }
 
-func (op *CreateWindowOperation) Start(transport *Transport, callback func(op *CreateWindowOperation)) {
-	op.doStart(transport, protocol.CreateWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *CreateWindowOperation) Start(transport Executor, callback func(op *CreateWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *CreateWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *CreateWindowOperation)) {
+	op.doStart(ctx, transport, protocol.CreateWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *CreateWindowOperation) StartChannel(transport *Transport, channel chan *CreateWindowOperation) {
-	op.doStart(transport, protocol.CreateWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *CreateWindowOperation) StartChannel(transport Executor, channel chan *CreateWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.CreateWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *CreateWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.CreateWindowMethod, &op.input, &op.output); err != nil {
+func (op *CreateWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *CreateWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.CreateWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *CreateWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.CreateWindowMethod, &op.input, &op.output)
+}
+
+func (op *CreateWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *CreateWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.CreateWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -201,8 +287,10 @@ func (op *CreateWindowOperation) Execute(transport *Transport) (*protocol.Window
 func (op *CreateWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -255,25 +343,45 @@ func (op *MoveWindowOperation) SetTop(top int) *MoveWindowOperation {
 	return op
 }
 
-func (op *MoveWindowOperation) Start(transport *Transport, callback func(op *MoveWindowOperation)) {
-	op.doStart(transport, protocol.MoveWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MoveWindowOperation) Start(transport Executor, callback func(op *MoveWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *MoveWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *MoveWindowOperation)) {
+	op.doStart(ctx, transport, protocol.MoveWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *MoveWindowOperation) StartChannel(transport *Transport, channel chan *MoveWindowOperation) {
-	op.doStart(transport, protocol.MoveWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MoveWindowOperation) StartChannel(transport Executor, channel chan *MoveWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.MoveWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *MoveWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.MoveWindowMethod, &op.input, &op.output); err != nil {
+func (op *MoveWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *MoveWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.MoveWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *MoveWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.MoveWindowMethod, &op.input, &op.output)
+}
+
+func (op *MoveWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *MoveWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.MoveWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -282,8 +390,10 @@ func (op *MoveWindowOperation) Execute(transport *Transport) (*protocol.Window,
 func (op *MoveWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -336,25 +446,45 @@ func (op *ResizeWindowOperation) SetHeight(height int) *ResizeWindowOperation {
 	return op
 }
 
-func (op *ResizeWindowOperation) Start(transport *Transport, callback func(op *ResizeWindowOperation)) {
-	op.doStart(transport, protocol.ResizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *ResizeWindowOperation) Start(transport Executor, callback func(op *ResizeWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *ResizeWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *ResizeWindowOperation)) {
+	op.doStart(ctx, transport, protocol.ResizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *ResizeWindowOperation) StartChannel(transport *Transport, channel chan *ResizeWindowOperation) {
-	op.doStart(transport, protocol.ResizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *ResizeWindowOperation) StartChannel(transport Executor, channel chan *ResizeWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.ResizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *ResizeWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.ResizeWindowMethod, &op.input, &op.output); err != nil {
+func (op *ResizeWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *ResizeWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.ResizeWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *ResizeWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.ResizeWindowMethod, &op.input, &op.output)
+}
+
+func (op *ResizeWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *ResizeWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.ResizeWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -363,8 +493,10 @@ func (op *ResizeWindowOperation) Execute(transport *Transport) (*protocol.Window
 func (op *ResizeWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -395,25 +527,45 @@ func (op *MinimizeWindowOperation) SetWindowId(windowId int) *MinimizeWindowOper
 	return op
 }
 
-func (op *MinimizeWindowOperation) Start(transport *Transport, callback func(op *MinimizeWindowOperation)) {
-	op.doStart(transport, protocol.MinimizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MinimizeWindowOperation) Start(transport Executor, callback func(op *MinimizeWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *MinimizeWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *MinimizeWindowOperation)) {
+	op.doStart(ctx, transport, protocol.MinimizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *MinimizeWindowOperation) StartChannel(transport *Transport, channel chan *MinimizeWindowOperation) {
-	op.doStart(transport, protocol.MinimizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MinimizeWindowOperation) StartChannel(transport Executor, channel chan *MinimizeWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.MinimizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *MinimizeWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.MinimizeWindowMethod, &op.input, &op.output); err != nil {
+func (op *MinimizeWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *MinimizeWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.MinimizeWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *MinimizeWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.MinimizeWindowMethod, &op.input, &op.output)
+}
+
+func (op *MinimizeWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *MinimizeWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.MinimizeWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -422,8 +574,10 @@ func (op *MinimizeWindowOperation) Execute(transport *Transport) (*protocol.Wind
 func (op *MinimizeWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -454,25 +608,45 @@ func (op *MaximizeWindowOperation) SetWindowId(windowId int) *MaximizeWindowOper
 	return op
 }
 
-func (op *MaximizeWindowOperation) Start(transport *Transport, callback func(op *MaximizeWindowOperation)) {
-	op.doStart(transport, protocol.MaximizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MaximizeWindowOperation) Start(transport Executor, callback func(op *MaximizeWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *MaximizeWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *MaximizeWindowOperation)) {
+	op.doStart(ctx, transport, protocol.MaximizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *MaximizeWindowOperation) StartChannel(transport *Transport, channel chan *MaximizeWindowOperation) {
-	op.doStart(transport, protocol.MaximizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *MaximizeWindowOperation) StartChannel(transport Executor, channel chan *MaximizeWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.MaximizeWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *MaximizeWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.MaximizeWindowMethod, &op.input, &op.output); err != nil {
+func (op *MaximizeWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *MaximizeWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.MaximizeWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *MaximizeWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.MaximizeWindowMethod, &op.input, &op.output)
+}
+
+func (op *MaximizeWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *MaximizeWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.MaximizeWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -481,8 +655,10 @@ func (op *MaximizeWindowOperation) Execute(transport *Transport) (*protocol.Wind
 func (op *MaximizeWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -513,25 +689,45 @@ func (op *FullscreenWindowOperation) SetWindowId(windowId int) *FullscreenWindow
 	return op
 }
 
-func (op *FullscreenWindowOperation) Start(transport *Transport, callback func(op *FullscreenWindowOperation)) {
-	op.doStart(transport, protocol.FullscreenWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FullscreenWindowOperation) Start(transport Executor, callback func(op *FullscreenWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *FullscreenWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *FullscreenWindowOperation)) {
+	op.doStart(ctx, transport, protocol.FullscreenWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *FullscreenWindowOperation) StartChannel(transport *Transport, channel chan *FullscreenWindowOperation) {
-	op.doStart(transport, protocol.FullscreenWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FullscreenWindowOperation) StartChannel(transport Executor, channel chan *FullscreenWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.FullscreenWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *FullscreenWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.FullscreenWindowMethod, &op.input, &op.output); err != nil {
+func (op *FullscreenWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *FullscreenWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.FullscreenWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *FullscreenWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.FullscreenWindowMethod, &op.input, &op.output)
+}
+
+func (op *FullscreenWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *FullscreenWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.FullscreenWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -540,8 +736,10 @@ func (op *FullscreenWindowOperation) Execute(transport *Transport) (*protocol.Wi
 func (op *FullscreenWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -572,25 +770,45 @@ func (op *RestoreWindowOperation) SetWindowId(windowId int) *RestoreWindowOperat
 	return op
 }
 
-func (op *RestoreWindowOperation) Start(transport *Transport, callback func(op *RestoreWindowOperation)) {
-	op.doStart(transport, protocol.RestoreWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *RestoreWindowOperation) Start(transport Executor, callback func(op *RestoreWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *RestoreWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *RestoreWindowOperation)) {
+	op.doStart(ctx, transport, protocol.RestoreWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *RestoreWindowOperation) StartChannel(transport *Transport, channel chan *RestoreWindowOperation) {
-	op.doStart(transport, protocol.RestoreWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *RestoreWindowOperation) StartChannel(transport Executor, channel chan *RestoreWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.RestoreWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *RestoreWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.RestoreWindowMethod, &op.input, &op.output); err != nil {
+func (op *RestoreWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *RestoreWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.RestoreWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *RestoreWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.RestoreWindowMethod, &op.input, &op.output)
+}
+
+func (op *RestoreWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *RestoreWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.RestoreWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -599,8 +817,10 @@ func (op *RestoreWindowOperation) Execute(transport *Transport) (*protocol.Windo
 func (op *RestoreWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -631,25 +851,45 @@ func (op *FocusWindowOperation) SetWindowId(windowId int) *FocusWindowOperation
 	return op
 }
 
-func (op *FocusWindowOperation) Start(transport *Transport, callback func(op *FocusWindowOperation)) {
-	op.doStart(transport, protocol.FocusWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FocusWindowOperation) Start(transport Executor, callback func(op *FocusWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *FocusWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *FocusWindowOperation)) {
+	op.doStart(ctx, transport, protocol.FocusWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *FocusWindowOperation) StartChannel(transport *Transport, channel chan *FocusWindowOperation) {
-	op.doStart(transport, protocol.FocusWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *FocusWindowOperation) StartChannel(transport Executor, channel chan *FocusWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.FocusWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *FocusWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.FocusWindowMethod, &op.input, &op.output); err != nil {
+func (op *FocusWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *FocusWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.FocusWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *FocusWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.FocusWindowMethod, &op.input, &op.output)
+}
+
+func (op *FocusWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *FocusWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.FocusWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -658,8 +898,10 @@ func (op *FocusWindowOperation) Execute(transport *Transport) (*protocol.Window,
 func (op *FocusWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -690,25 +932,45 @@ func (op *UnfocusWindowOperation) SetWindowId(windowId int) *UnfocusWindowOperat
 	return op
 }
 
-func (op *UnfocusWindowOperation) Start(transport *Transport, callback func(op *UnfocusWindowOperation)) {
-	op.doStart(transport, protocol.UnfocusWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *UnfocusWindowOperation) Start(transport Executor, callback func(op *UnfocusWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *UnfocusWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *UnfocusWindowOperation)) {
+	op.doStart(ctx, transport, protocol.UnfocusWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *UnfocusWindowOperation) StartChannel(transport *Transport, channel chan *UnfocusWindowOperation) {
-	op.doStart(transport, protocol.UnfocusWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *UnfocusWindowOperation) StartChannel(transport Executor, channel chan *UnfocusWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.UnfocusWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *UnfocusWindowOperation) Execute(transport *Transport) (*protocol.Window, error) {
-	if err := op.doExecute(transport, protocol.UnfocusWindowMethod, &op.input, &op.output); err != nil {
+func (op *UnfocusWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *UnfocusWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.UnfocusWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *UnfocusWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.UnfocusWindowMethod, &op.input, &op.output)
+}
+
+func (op *UnfocusWindowOperation) Execute(transport Executor) (*protocol.Window, error) {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *UnfocusWindowOperation) ExecuteContext(ctx context.Context, transport Executor) (*protocol.Window, error) {
+	if err := op.doExecute(ctx, transport, protocol.UnfocusWindowMethod, &op.input, &op.output); err != nil {
 		return nil, err
 	} else if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -717,8 +979,10 @@ func (op *UnfocusWindowOperation) Execute(transport *Transport) (*protocol.Windo
 func (op *UnfocusWindowOperation) Result() (*protocol.Window, error) {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return nil, errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return nil, err
+	} else if op.output.Success == false {
+		return nil, op.doGetOutputError(op.output.Generic())
 	} else {
 		return &op.output.Result, nil
 	}
@@ -749,25 +1013,45 @@ func (op *RemoveWindowOperation) SetWindowId(windowId int) *RemoveWindowOperatio
 	return op
 }
 
-func (op *RemoveWindowOperation) Start(transport *Transport, callback func(op *RemoveWindowOperation)) {
-	op.doStart(transport, protocol.RemoveWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *RemoveWindowOperation) Start(transport Executor, callback func(op *RemoveWindowOperation)) {
+	op.StartContext(context.Background(), transport, callback)
+}
+
+func (op *RemoveWindowOperation) StartContext(ctx context.Context, transport Executor, callback func(op *RemoveWindowOperation)) {
+	op.doStart(ctx, transport, protocol.RemoveWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		callback(op)
 	})
 }
 
-func (op *RemoveWindowOperation) StartChannel(transport *Transport, channel chan *RemoveWindowOperation) {
-	op.doStart(transport, protocol.RemoveWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
+func (op *RemoveWindowOperation) StartChannel(transport Executor, channel chan *RemoveWindowOperation) {
+	op.doStart(context.Background(), transport, protocol.RemoveWindowMethod, &op.input, &op.output, func(m string, a, r interface{}, err error) {
 		op.doFinish(err)
 		channel <- op
 	})
 }
 
-func (op *RemoveWindowOperation) Execute(transport *Transport) error {
-	if err := op.doExecute(transport, protocol.RemoveWindowMethod, &op.input, &op.output); err != nil {
+func (op *RemoveWindowOperation) Go(transport Executor) *Future {
+	return op.GoContext(context.Background(), transport)
+}
+
+func (op *RemoveWindowOperation) GoContext(ctx context.Context, transport Executor) *Future {
+	return op.doGo(ctx, transport, protocol.RemoveWindowMethod, &op.input, &op.output, op.output.Generic())
+}
+
+func (op *RemoveWindowOperation) enqueue(batch *Batch) {
+	batch.enqueue(&op.GenericOperation, protocol.RemoveWindowMethod, &op.input, &op.output)
+}
+
+func (op *RemoveWindowOperation) Execute(transport Executor) error {
+	return op.ExecuteContext(context.Background(), transport)
+}
+
+func (op *RemoveWindowOperation) ExecuteContext(ctx context.Context, transport Executor) error {
+	if err := op.doExecute(ctx, transport, protocol.RemoveWindowMethod, &op.input, &op.output); err != nil {
 		return err
 	} else if op.output.Success == false {
-		return errors.New(op.output.Message)
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
@@ -776,8 +1060,10 @@ func (op *RemoveWindowOperation) Execute(transport *Transport) error {
 func (op *RemoveWindowOperation) Result() error {
 	op.doEnsureFinished()
 
-	if op.output.Success == false {
-		return errors.New(op.output.Message)
+	if err := op.doGetError(); err != nil {
+		return err
+	} else if op.output.Success == false {
+		return op.doGetOutputError(op.output.Generic())
 	} else {
 		return nil
 	}
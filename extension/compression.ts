@@ -0,0 +1,73 @@
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Encodings for compressed params and results. The gzip encoding refers
+// to the gzip format (RFC 1952) and the deflate encoding to the zlib
+// format (RFC 1950), which match the formats produced by the Compression
+// Streams API as well as the Go client.
+//
+
+export type Encoding = 'gzip' | 'deflate';
+
+export function isEncoding(input: any): input is Encoding {
+	return (input === 'gzip' || input === 'deflate');
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Minimum size of results to be compressed. Smaller results are sent as
+// plain JSON since compression would not save much.
+//
+
+export const COMPRESSION_THRESHOLD = 16 * 1024;
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Pick the first supported encoding from the accept field of a request,
+// which lists the encodings accepted by the client separated by commas.
+// Return undefined if none is supported.
+//
+
+export function pickEncoding(accept: any): Encoding | undefined {
+	if (typeof accept === 'string') {
+		for (const candidate of accept.split(',')) {
+			const encoding = candidate.trim();
+
+			if (isEncoding(encoding)) {
+				return encoding;
+			}
+		}
+	}
+
+	return undefined;
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Compress the given text with the given encoding and return the result
+// in base64.
+//
+
+export async function compress(text: string, encoding: Encoding): Promise<string> {
+	const stream = new Blob([ text ]).stream().pipeThrough(new CompressionStream(encoding));
+	const buffer = await new Response(stream).arrayBuffer();
+	return Buffer.from(buffer).toString('base64');
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Decompress the given base64 data with the given encoding and return
+// the original text.
+//
+
+export async function decompress(data: string, encoding: Encoding): Promise<string> {
+	const stream = new Blob([ Buffer.from(data, 'base64') ]).stream().pipeThrough(new DecompressionStream(encoding));
+	return await new Response(stream).text();
+}
+
+
@@ -13,6 +13,12 @@ export interface Config {
 	name: string;
 	username: string;
 	password: string;
+	signingSecret: string;
+	signingKey: string;
+	clientKeys: string;
+	tokenKey: string;
+	urlPatterns: string;
+	downloadDirectories: string;
 }
 
 
@@ -80,6 +86,12 @@ export async function load(): Promise<Config|undefined> {
 		name: null,
 		username: "",
 		password: "",
+		signingSecret: "",
+		signingKey: "",
+		clientKeys: "",
+		tokenKey: "",
+		urlPatterns: "",
+		downloadDirectories: "",
 	});
 
 	if (data.version === 5) {
@@ -87,7 +99,13 @@ export async function load(): Promise<Config|undefined> {
 		const name = data.name as string;
 		const username = data.username as string;
 		const password = data.password as string;
-		return { url, name, username, password };
+		const signingSecret = data.signingSecret as string;
+		const signingKey = data.signingKey as string;
+		const clientKeys = data.clientKeys as string;
+		const tokenKey = data.tokenKey as string;
+		const urlPatterns = data.urlPatterns as string;
+		const downloadDirectories = data.downloadDirectories as string;
+		return { url, name, username, password, signingSecret, signingKey, clientKeys, tokenKey, urlPatterns, downloadDirectories };
 	} else {
 		return undefined;
 	}
@@ -106,6 +124,12 @@ export async function save(config: Config) {
 		name: config.name,
 		username: config.username,
 		password: config.password,
+		signingSecret: config.signingSecret,
+		signingKey: config.signingKey,
+		clientKeys: config.clientKeys,
+		tokenKey: config.tokenKey,
+		urlPatterns: config.urlPatterns,
+		downloadDirectories: config.downloadDirectories,
 	});
 }
 
@@ -3,6 +3,7 @@
 import * as WebExtension from 'webextension-polyfill';
 
 import * as Context from './context';
+import * as Policy from './policy';
 import * as Rpc from './rpc';
 import * as Util from './util';
 import * as Validator from './validator';
@@ -27,6 +28,9 @@ export function registerAllMethods() {
 // into the document via content script, and then calling the injected
 // GraphQL engine to execute the query and return the result.
 //
+// The method fails with a policy error if the page in the tab is not
+// permitted by the sandbox policy.
+//
 
 interface QueryInput {
 	tabId: number;
@@ -66,7 +70,7 @@ export function registerQueryMethod() {
 			}
 		},
 
-		async function (input: QueryInput): Promise<QueryResult|Rpc.ExecutionError|Rpc.InternalError> {
+		async function (input: QueryInput): Promise<QueryResult|Rpc.ExecutionError|Rpc.InternalError|Rpc.PolicyError> {
 			try {
 				// Note that args has to be JSON serializable. In Chrome, the value 'undefined'
 				// is not JSON serializable and will cause the scripting.executeScript call to
@@ -75,6 +79,11 @@ export function registerQueryMethod() {
 
 				const tabId = await Context.ensureNotConsoleTab(input.tabId);
 				const target = { tabId };
+				const tab = await WebExtension.tabs.get(tabId);
+
+				if (Policy.isUrlPermitted(await Policy.load(), tab.url || '') === false) {
+					return Rpc.createPolicyError(`page in tab ${tabId} not permitted by policy`);
+				}
 
 				const injections = await WebExtension.scripting.executeScript({
 					target: { tabId },
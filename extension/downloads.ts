@@ -5,6 +5,7 @@ import * as WebExtension from 'webextension-polyfill';
 import * as Browser from './browser';
 import * as Logger from './logger';
 import * as Pattern from './pattern';
+import * as Policy from './policy';
 import * as Rpc from './rpc';
 import * as Util from './util';
 import * as Validator from './validator';
@@ -152,6 +153,9 @@ export function registerGetMethod() {
 // the download reaches a stable state. If the option is true, the
 // method will return early, right after the download has started.
 //
+// The method fails with a policy error if the filename is not under
+// any directory permitted by the sandbox policy.
+//
 // The operation is a simple wrapper over the downloads.create Web
 // Extension API. Details on the API can be found in:
 //
@@ -189,10 +193,15 @@ export function registerCreateMethod() {
 			}
 		},
 
-		async function (input: CreateInput): Promise<CreateResult|Rpc.ExecutionError> {
+		async function (input: CreateInput): Promise<CreateResult|Rpc.ExecutionError|Rpc.PolicyError> {
 			try {
 				const url = input.url;
 				const filename = input.filename;
+
+				if (Policy.isFilenamePermitted(await Policy.load(), filename) === false) {
+					return Rpc.createPolicyError(`filename ${filename} not permitted by policy`);
+				}
+
 				const headers = [] as Array<WebExtension.Downloads.DownloadOptionsTypeHeadersItemType>;
 				const conflictAction = 'uniquify' as WebExtension.Downloads.FilenameConflictAction;
 				const options = { url, filename, conflictAction, headers } as WebExtension.Downloads.DownloadOptionsType;
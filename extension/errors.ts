@@ -15,6 +15,8 @@ export type DispatchError = GenericError<'dispatch'>
 export type ValidationError = GenericError<'validation'>
 export type InternalError = GenericError<'internal'>
 export type ExecutionError = GenericError<'execution'>
+export type ForbiddenError = GenericError<'forbidden'>
+export type PolicyError = GenericError<'policy'>
 
 
 //////////////////////////////////////////////////////////////////////////
@@ -44,6 +46,14 @@ export function createExecutionError(input: any): ExecutionError {
 	}
 }
 
+export function createForbiddenError(message: string): ForbiddenError {
+	return { success: false, category: 'forbidden' as const, message };
+}
+
+export function createPolicyError(message: string): PolicyError {
+	return { success: false, category: 'policy' as const, message };
+}
+
 
 //////////////////////////////////////////////////////////////////////////
 //
@@ -90,6 +100,26 @@ export function isExecutionError(input: any): input is ExecutionError {
 	}
 }
 
+export function isForbiddenError(input: any): input is ForbiddenError {
+	if (isBaseError(input) === false) {
+		return false;
+	} else if (input.category !== 'forbidden') {
+		return false;
+	} else {
+		return true;
+	}
+}
+
+export function isPolicyError(input: any): input is PolicyError {
+	if (isBaseError(input) === false) {
+		return false;
+	} else if (input.category !== 'policy') {
+		return false;
+	} else {
+		return true;
+	}
+}
+
 
 //////////////////////////////////////////////////////////////////////////
 //
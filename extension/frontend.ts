@@ -162,6 +162,10 @@ try {
 		updatePermittedActions(true, false);
 	});
 
+	Server.onRejected.addListener(function() {
+		Logger.write('Mindctrl rejected a request that is unsigned, badly signed or stale');
+	});
+
 	Server.onDisconnected.addListener(function() {
 		Logger.write('Mindctrl is disconnected from the remote MQTT server');
 		updateStatus('Idle');
@@ -3,6 +3,8 @@
 import * as WebExtension from 'webextension-polyfill';
 
 import * as Browser from './browser';
+import * as Compression from './compression';
+import * as Policy from './policy';
 import * as Rpc from './rpc';
 
 
@@ -14,6 +16,8 @@ import * as Rpc from './rpc';
 export function registerAllMethods() {
 	registerGetBrowserMethod();
 	registerGetPlatformMethod();
+	registerGetCapabilitiesMethod();
+	registerGetPolicyMethod();
 }
 
 
@@ -99,3 +103,82 @@ export function registerGetPlatformMethod() {
 }
 
 
+//////////////////////////////////////////////////////////////////////////
+//
+// Register info.get_capabilities RPC method.
+//
+// The method reports the version of the extension, the version of the
+// RPC protocol, the names of all registered RPC methods, the encodings
+// supported for compressed params and whether batch messages are
+// accepted, so that the caller can check whether the extension is
+// compatible with it and supports the methods it needs.
+//
+
+interface GetCapabilitiesInput {
+	// empty
+}
+
+interface GetCapabilitiesResult {
+	success: true;
+	result: {
+		extensionVersion: string;
+		protocolVersion: number;
+		methods: string[];
+		encodings: Compression.Encoding[];
+		batch: boolean;
+	};
+}
+
+export function registerGetCapabilitiesMethod() {
+	Rpc.register<GetCapabilitiesInput,GetCapabilitiesResult>(
+		'info.get_capabilities',
+
+		function (input: Rpc.Input): input is GetCapabilitiesInput {
+			return true;
+		},
+
+		async function (input: GetCapabilitiesInput): Promise<GetCapabilitiesResult> {
+			const extensionVersion = WebExtension.runtime.getManifest().version;
+			const protocolVersion = Rpc.PROTOCOL_VERSION;
+			const methods = Rpc.listMethods();
+			const encodings = [ 'gzip', 'deflate' ] as Compression.Encoding[];
+			const batch = true;
+			return { success: true, result: { extensionVersion, protocolVersion, methods, encodings, batch } };
+		}
+	);
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Register info.get_policy RPC method.
+//
+// The method reports the sandbox policy of the extension, that is the
+// match patterns of the pages clients may touch and the directories
+// clients may download files to. Empty lists mean no restriction.
+//
+
+interface GetPolicyInput {
+	// empty
+}
+
+interface GetPolicyResult {
+	success: true;
+	result: Policy.Policy;
+}
+
+export function registerGetPolicyMethod() {
+	Rpc.register<GetPolicyInput,GetPolicyResult>(
+		'info.get_policy',
+
+		function (input: Rpc.Input): input is GetPolicyInput {
+			return true;
+		},
+
+		async function (input: GetPolicyInput): Promise<GetPolicyResult> {
+			return { success: true, result: await Policy.load() };
+		}
+	);
+}
+
+
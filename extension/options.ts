@@ -19,6 +19,12 @@ import * as Util from './util';
 	const nameElement = document.querySelector<HTMLInputElement>('#options input[name="name"]')!;
 	const usernameElement = document.querySelector<HTMLInputElement>('#options input[name="username"]')!;
 	const passwordElement = document.querySelector<HTMLInputElement>('#options input[name="password"]')!;
+	const signingSecretElement = document.querySelector<HTMLInputElement>('#options input[name="signingSecret"]')!;
+	const signingKeyElement = document.querySelector<HTMLInputElement>('#options input[name="signingKey"]')!;
+	const clientKeysElement = document.querySelector<HTMLInputElement>('#options input[name="clientKeys"]')!;
+	const tokenKeyElement = document.querySelector<HTMLInputElement>('#options input[name="tokenKey"]')!;
+	const urlPatternsElement = document.querySelector<HTMLInputElement>('#options input[name="urlPatterns"]')!;
+	const downloadDirectoriesElement = document.querySelector<HTMLInputElement>('#options input[name="downloadDirectories"]')!;
 	const reloadElement = document.querySelector<HTMLButtonElement>('#options button[name="reload"]')!;
 
 	urlElement.addEventListener('change', function(ev: Event) {
@@ -45,12 +51,24 @@ import * as Util from './util';
 				nameElement.setAttribute('value', config.name);
 				usernameElement.setAttribute('value', config.username || '');
 				passwordElement.setAttribute('value', config.password || '');
+				signingSecretElement.setAttribute('value', config.signingSecret || '');
+				signingKeyElement.setAttribute('value', config.signingKey || '');
+				clientKeysElement.setAttribute('value', config.clientKeys || '');
+				tokenKeyElement.setAttribute('value', config.tokenKey || '');
+				urlPatternsElement.setAttribute('value', config.urlPatterns || '');
+				downloadDirectoriesElement.setAttribute('value', config.downloadDirectories || '');
 				formElement.reset();
 			} else {
 				urlElement.setAttribute('value', '');
 				nameElement.setAttribute('value', '');
 				usernameElement.setAttribute('value', '');
 				passwordElement.setAttribute('value', '');
+				signingSecretElement.setAttribute('value', '');
+				signingKeyElement.setAttribute('value', '');
+				clientKeysElement.setAttribute('value', '');
+				tokenKeyElement.setAttribute('value', '');
+				urlPatternsElement.setAttribute('value', '');
+				downloadDirectoriesElement.setAttribute('value', '');
 				formElement.reset();
 			}
 		});
@@ -64,12 +82,24 @@ import * as Util from './util';
 		const name = nameElement.value;
 		const username = usernameElement.value;
 		const password = passwordElement.value;
-
-		Config.save({ url, name, username, password }).then(function() {
+		const signingSecret = signingSecretElement.value;
+		const signingKey = signingKeyElement.value;
+		const clientKeys = clientKeysElement.value;
+		const tokenKey = tokenKeyElement.value;
+		const urlPatterns = urlPatternsElement.value;
+		const downloadDirectories = downloadDirectoriesElement.value;
+
+		Config.save({ url, name, username, password, signingSecret, signingKey, clientKeys, tokenKey, urlPatterns, downloadDirectories }).then(function() {
 			urlElement.setAttribute('value', url);
 			nameElement.setAttribute('value', name);
 			usernameElement.setAttribute('value', username);
 			passwordElement.setAttribute('value', password);
+			signingSecretElement.setAttribute('value', signingSecret);
+			signingKeyElement.setAttribute('value', signingKey);
+			clientKeysElement.setAttribute('value', clientKeys);
+			tokenKeyElement.setAttribute('value', tokenKey);
+			urlPatternsElement.setAttribute('value', urlPatterns);
+			downloadDirectoriesElement.setAttribute('value', downloadDirectories);
 		});
 	});
 
@@ -86,12 +116,24 @@ import * as Util from './util';
 			nameElement.setAttribute('value', config.name);
 			usernameElement.setAttribute('value', config.username || '');
 			passwordElement.setAttribute('value', config.password || '');
+			signingSecretElement.setAttribute('value', config.signingSecret || '');
+			signingKeyElement.setAttribute('value', config.signingKey || '');
+			clientKeysElement.setAttribute('value', config.clientKeys || '');
+			tokenKeyElement.setAttribute('value', config.tokenKey || '');
+			urlPatternsElement.setAttribute('value', config.urlPatterns || '');
+			downloadDirectoriesElement.setAttribute('value', config.downloadDirectories || '');
 			formElement.reset();
 		} else {
 			urlElement.setAttribute('value', '');
 			nameElement.setAttribute('value', '');
 			usernameElement.setAttribute('value', '');
 			passwordElement.setAttribute('value', '');
+			signingSecretElement.setAttribute('value', '');
+			signingKeyElement.setAttribute('value', '');
+			clientKeysElement.setAttribute('value', '');
+			tokenKeyElement.setAttribute('value', '');
+			urlPatternsElement.setAttribute('value', '');
+			downloadDirectoriesElement.setAttribute('value', '');
 			formElement.reset();
 		}
 	});
@@ -0,0 +1,104 @@
+
+
+import * as Config from './config';
+import * as Pattern from './pattern';
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Sandbox policy restricting what clients may do with the browser. The
+// urlPatterns field lists the match patterns of the pages the tabs.load,
+// tabs.create, tabs.reload, windows.create and documents.query methods
+// may touch, and the field downloadDirectories lists the directories,
+// relative to the download folder, the downloads.create method may save
+// files to. Each list puts no restriction when it is empty.
+//
+// Pages may redirect to URLs not permitted after the requested URL is
+// checked. The tabs methods check the final URL once the page is loaded,
+// but windows.create and the tabs methods with the noWait option return
+// before that and cannot.
+//
+
+export interface Policy {
+	urlPatterns: string[];
+	downloadDirectories: string[];
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Load the policy from the extension config. The match patterns are
+// separated by whitespaces or commas, and the directories by commas
+// since they may contain spaces.
+//
+
+export async function load(): Promise<Policy> {
+	const config = await Config.load();
+
+	if (config) {
+		const urlPatterns = config.urlPatterns.split(/[\s,]+/).filter((pattern) => pattern !== '');
+		const downloadDirectories = config.downloadDirectories.split(',').map(normalizePath).filter((directory) => directory !== '');
+		return { urlPatterns, downloadDirectories };
+	} else {
+		return { urlPatterns: [], downloadDirectories: [] };
+	}
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Check if the policy permits the given URL. Blank pages are always
+// permitted since they contain nothing, and invalid patterns never
+// match anything.
+//
+
+export function isUrlPermitted(policy: Policy, url: string): boolean {
+	if (policy.urlPatterns.length === 0 || url === 'about:blank') {
+		return true;
+	}
+
+	for (const pattern of policy.urlPatterns) {
+		if (Pattern.validateMatchPattern(pattern) && new RegExp(Pattern.convertMatchPattern(pattern)).test(url)) {
+			return true;
+		}
+	}
+
+	return false;
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Check if the policy permits the given download filename. Filenames
+// escaping the download folder with '..' are never permitted.
+//
+
+export function isFilenamePermitted(policy: Policy, filename: string): boolean {
+	const segments = filename.split(/[\/\\]+/);
+	const normalized = normalizePath(filename);
+
+	if (segments.includes('..')) {
+		return false;
+	} else if (policy.downloadDirectories.length === 0) {
+		return true;
+	}
+
+	for (const directory of policy.downloadDirectories) {
+		if (normalized.startsWith(directory + '/')) {
+			return true;
+		}
+	}
+
+	return false;
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Normalize the given relative path by unifying the separators and
+// removing empty and '.' segments.
+//
+
+function normalizePath(path: string): string {
+	return path.trim().split(/[\/\\]+/).filter((segment) => segment !== '' && segment !== '.').join('/');
+}
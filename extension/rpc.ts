@@ -14,16 +14,31 @@ export type {
 	ValidationError,
 	InternalError,
 	ExecutionError,
+	ForbiddenError,
+	PolicyError,
 } from './errors';
 
 export {
 	createDispatchError, isDispatchError,
 	createValidationError, isValidationError,
 	createInternalError, isInternalError,
-	createExecutionError, isExecutionError
+	createExecutionError, isExecutionError,
+	createForbiddenError, isForbiddenError,
+	createPolicyError, isPolicyError
 } from './errors';
 
 
+//////////////////////////////////////////////////////////////////////////
+//
+// Version of the RPC protocol. The version is bumped whenever a change
+// to the methods or the packets breaks the compatibility between the
+// clients and the extension. New methods do not count, since clients
+// can find them in the method list reported by info.get_capabilities.
+//
+
+export const PROTOCOL_VERSION = 1;
+
+
 //////////////////////////////////////////////////////////////////////////
 //
 // Definitions of generic RPC input, output and results.
@@ -43,7 +58,9 @@ export type Output =
 	Errors.DispatchError |
 	Errors.ValidationError |
 	Errors.InternalError |
-	Errors.ExecutionError
+	Errors.ExecutionError |
+	Errors.ForbiddenError |
+	Errors.PolicyError
 
 
 //////////////////////////////////////////////////////////////////////////
@@ -88,18 +105,67 @@ export function register<RpcInput extends Input, RpcOutput extends Output>(metho
 }
 
 
+//////////////////////////////////////////////////////////////////////////
+//
+// List the names of the registered RPC methods in alphabetical order.
+//
+
+export function listMethods(): string[] {
+	return Object.keys(registry).sort();
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Methods every client may call regardless of its capability token.
+//
+
+const UNRESTRICTED_METHODS = [ 'ping', 'info.get_capabilities' ];
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Check if the given method matches any of the given patterns. A pattern
+// ending with '.*' matches every method with the given prefix, and '*'
+// matches every method.
+//
+
+export function isPermitted(method: string, patterns: string[]): boolean {
+	if (UNRESTRICTED_METHODS.includes(method)) {
+		return true;
+	}
+
+	for (const pattern of patterns) {
+		if (pattern === '*' || pattern === method) {
+			return true;
+		} else if (pattern.endsWith('.*') && method.startsWith(pattern.slice(0, -1))) {
+			return true;
+		}
+	}
+
+	return false;
+}
+
+
 //////////////////////////////////////////////////////////////////////////
 //
 // Dispatch the incoming request to the appropriate method for execution.
+// When the patterns of the permitted methods are given, as taken from
+// the capability token of the client, calls to other methods fail with
+// a forbidden error.
 //
 
-export async function dispatch(method: string, input: Input): Promise<Output> {
+export async function dispatch(method: string, input: Input, permitted?: string[]): Promise<Output> {
 	const unknownMethod = async function(input: Input): Promise<Output> {
 		return Errors.createDispatchError(`unknown method ${method}`);
 	};
 
+	const forbiddenMethod = async function(input: Input): Promise<Output> {
+		return Errors.createForbiddenError(`method ${method} not permitted by capability token`);
+	};
+
 	onRequestChannel.emit(method, input);
-	const callable = registry[method] || unknownMethod;
+	const callable = (permitted !== undefined && isPermitted(method, permitted) === false ? forbiddenMethod : registry[method] || unknownMethod);
 	const output = await callable(input);
 	onResponseChannel.emit(method, input, output);
 	return output;
@@ -2,8 +2,11 @@
 
 import * as Mqtt from 'mqtt';
 
+import * as Compression from './compression';
 import * as Config from './config';
 import * as Rpc from './rpc';
+import * as Signature from './signature';
+import * as Tokens from './tokens';
 import * as Util from './util';
 
 
@@ -21,12 +24,17 @@ interface StartingState {
 	type: 'starting';
 	config: Config.Config;
 	client: Mqtt.Client;
+	keys: Signature.Keys|undefined;
+	tokenKey: CryptoKey|undefined;
+	protocolVersion: 4|5;
 }
 
 interface ServingState {
 	type: 'serving';
 	config: Config.Config;
 	client: Mqtt.Client;
+	keys: Signature.Keys|undefined;
+	tokenKey: CryptoKey|undefined;
 	timer: ReturnType<typeof setInterval>;
 }
 
@@ -58,6 +66,9 @@ export interface Request {
 	params: Rpc.Input;
 	client: string;
 	server: string;
+	accept?: string;
+	chunkSize?: number;
+	token?: string;
 }
 
 export interface Response {
@@ -70,18 +81,75 @@ export interface Response {
 }
 
 
+//////////////////////////////////////////////////////////////////////////
+//
+// Requests and responses may carry compressed params and results, which
+// are strings of compressed JSON in base64 together with the encoding.
+// The params are decompressed before the request is processed, and the
+// results are compressed only for clients that accept an encoding.
+//
+
+interface EncodedRequest {
+	type: 'request';
+	id: string;
+	method: string;
+	params: string;
+	client: string;
+	server: string;
+	encoding: Compression.Encoding;
+	accept?: string;
+	chunkSize?: number;
+	token?: string;
+}
+
+interface EncodedResponse {
+	type: 'response';
+	id: string;
+	method: string;
+	result: string;
+	client: string;
+	server: string;
+	encoding: Compression.Encoding;
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Responses larger than the chunk size given by the client are split
+// into chunks, each carrying a piece of the serialized response in
+// base64. The client reassembles the pieces in order of their index.
+//
+
+interface Chunk {
+	type: 'chunk';
+	id: string;
+	method: string;
+	client: string;
+	server: string;
+	index: number;
+	count: number;
+	data: string;
+}
+
+const MINIMUM_CHUNK_SIZE = 4096;
+
+// Room for the fixed header, the packet identifier and the property
+// length of a PUBLISH packet, together with the length prefixes of
+// the topic and the correlation data.
+const PUBLISH_OVERHEAD = 16;
+
+
 //////////////////////////////////////////////////////////////////////////
 //
 // Type guard for request types. Note that type guard is not needed for
 // response because they are generated here and no validation is needed.
 //
 
-function isRequest(input: any): input is Request {
+function isRequest(input: any): input is Request|EncodedRequest {
 	if (typeof input !== 'object') return false;
 	if (typeof input['type'] !== 'string') return false;
 	if (typeof input['id'] !== 'string') return false;
 	if (typeof input['method'] !== 'string') return false;
-	if (typeof input['params'] !== 'object') return false;
 	if (typeof input['client'] !== 'string') return false;
 	if (typeof input['server'] !== 'string') return false;
 
@@ -91,6 +159,21 @@ function isRequest(input: any): input is Request {
 	if (input['client'] === '') return false;
 	if (input['server'] === '') return false;
 
+	if (input['encoding'] === undefined) {
+		if (typeof input['params'] !== 'object') return false;
+	} else {
+		if (typeof input['params'] !== 'string') return false;
+		if (Compression.isEncoding(input['encoding']) === false) return false;
+	}
+
+	if (input['chunkSize'] !== undefined) {
+		if (typeof input['chunkSize'] !== 'number') return false;
+	}
+
+	if (input['token'] !== undefined) {
+		if (typeof input['token'] !== 'string') return false;
+	}
+
 	return true;
 }
 
@@ -118,6 +201,7 @@ const onUnconfiguredChannel = Util.createEventChannel<[]>();
 const onUnreachableChannel = Util.createEventChannel<[]>();
 const onDisconnectedChannel = Util.createEventChannel<[]>();
 const onGarbageChannel = Util.createEventChannel<[any]>();
+const onRejectedChannel = Util.createEventChannel<[any]>();
 
 
 //////////////////////////////////////////////////////////////////////////
@@ -125,7 +209,8 @@ const onGarbageChannel = Util.createEventChannel<[any]>();
 // Setup the server and update the server state after connection to the
 // server. It involves:
 //
-// 1. Subscribing to the server topic for incoming requests
+// 1. Subscribing to the server topic for incoming requests; QoS 2 is
+//    requested so that clients can choose their own QoS level
 // 2. Publishing an alive message to the status topic
 // 3. Starting a timer for periodic alive message
 // 4. Transiting the server state to serving
@@ -135,28 +220,46 @@ function whenConnect() {
 	if (state.type === 'starting') {
 		const client = state.client;
 		const config = state.config;
+		const keys = state.keys;
+		const tokenKey = state.tokenKey;
 		const name = state.config.name;
 
-		client.subscribe(`mindctrl/servers/${name}`);
-		client.publish(`mindctrl/statuses/${name}`, 'alive', { retain: true });
+		client.subscribe(`mindctrl/servers/${name}`, { qos: 2 });
+		publishStatus(client, name, 'alive');
 
 		const timer = setInterval(function() {
-			client.publish(`mindctrl/statuses/${name}`, 'alive', { retain: true });
+			publishStatus(client, name, 'alive');
 		}, 60000);
 
-		state = { type: 'serving', config, client, timer };
+		state = { type: 'serving', config, client, keys, tokenKey, timer };
 		onServingChannel.emit();
 	}
 }
 
 
+//////////////////////////////////////////////////////////////////////////
+//
+// Publish a retained status message to the status topic. The time of
+// the message is attached as the "timestamp" user property, so that
+// clients discovering the servers can tell when the last heartbeat is
+// published.
+//
+
+function publishStatus(client: Mqtt.Client, name: string, status: 'alive'|'dead') {
+	const userProperties = { timestamp: new Date().toISOString() };
+	client.publish(`mindctrl/statuses/${name}`, status, { retain: true, properties: { userProperties } });
+}
+
+
 //////////////////////////////////////////////////////////////////////////
 //
 // Clean up the server after being disconnected from the server. This
 // function may be called in multiple situations.
 //
 // In the first case, this function is called during starting state. It
-// means that the MQTT server cannot be connected. This function will
+// means that the MQTT server cannot be connected. If the connection is
+// made with MQTT v5, this function will retry with MQTT 3.1.1 in case
+// the server does not support MQTT v5. Otherwise, this function will
 // report the failure and transit the server state back to idle.
 //
 // In the second case, this function is called during stopping state,
@@ -170,7 +273,9 @@ function whenConnect() {
 //
 
 function whenDisconnect() {
-	if (state.type === 'starting') {
+	if (state.type === 'starting' && state.protocolVersion === 5) {
+		connect(state.config, state.keys, state.tokenKey, 4);
+	} else if (state.type === 'starting') {
 		state = { type: 'idle' };
 		onUnreachableChannel.emit();
 	} else if (state.type === 'stopping') {
@@ -186,30 +291,48 @@ function whenDisconnect() {
 
 //////////////////////////////////////////////////////////////////////////
 //
-// Process incoming messages from the clients.
+// Process incoming messages from the clients. Responses are published
+// with the same QoS level as the requests, so that clients using QoS
+// 1 or 2 with persistent sessions can receive responses published
+// while they are briefly disconnected.
+//
+// A message carries either a single request or a batch of requests
+// as an array. Requests in a batch are executed concurrently, and
+// each of them is replied with its own response, so that a large
+// batch does not produce a response too large for the client.
+//
+// When signing is configured, the message must be signed by a client
+// with a valid and fresh signature; other messages are rejected. The
+// responses are signed in turn.
 //
 
-async function whenMessage(topic: string, payload: any) {
+async function whenMessage(topic: string, payload: any, packet: Mqtt.IPublishPacket) {
 	if (state.type === 'serving') {
 		const mqtt = state.client;
+		const keys = state.keys;
+		const tokenKey = state.tokenKey;
 
-	  try {
-			const message = payload.toString();
-			const request = JSON.parse(message);
-
-			if (isRequest(request)) {
-				onRequestChannel.emit(request);
+		try {
+			const opened = (keys !== undefined ? await Signature.open(payload.toString(), keys) : { payload: payload.toString(), signer: '' });
 
-				const id = request.id;
-				const method = request.method;
-				const params = request.params;
-				const client = request.client;
-				const server = request.server;
-				const result = await Rpc.dispatch(method, params);
-				const response = { type: 'response', id, method, result, client, server } as Response;
+			if (opened === null) {
+				onRejectedChannel.emit(payload);
+				return;
+			}
 
-				mqtt.publish(`mindctrl/clients/${client}`, JSON.stringify(response));
-				onResponseChannel.emit(response, request);
+			const request = JSON.parse(opened.payload);
+			const signer = opened.signer;
+
+			if (Array.isArray(request)) {
+				await Promise.all(request.map(async function(entry: any) {
+					if (isRequest(entry)) {
+						await serve(mqtt, keys, tokenKey, signer, entry, packet, true);
+					} else {
+						onGarbageChannel.emit(entry);
+					}
+				}));
+			} else if (isRequest(request)) {
+				await serve(mqtt, keys, tokenKey, signer, request, packet, false);
 			} else {
 				onGarbageChannel.emit(payload);
 			}
@@ -220,6 +343,195 @@ async function whenMessage(topic: string, payload: any) {
 }
 
 
+//////////////////////////////////////////////////////////////////////////
+//
+// Execute a single request and publish the response.
+//
+// Responses are published to the topic given in the MQTT v5 response
+// topic property of the request, together with the correlation data
+// of the request. Batched requests share the message and hence the
+// properties, so the request id is used as the correlation data of
+// their responses instead. When the request does not carry the
+// property, the response is published to the client topic as derived
+// from the request packet.
+//
+// When a token key is configured, the request must carry a capability
+// token issued with the key and bound to the public key of the signer,
+// and only the methods permitted by the token are executed; other
+// methods are refused with a forbidden error. The server refuses to
+// start with a token key but without Ed25519 signing, since a token
+// could otherwise be replayed by anyone who sees it.
+//
+
+async function serve(mqtt: Mqtt.Client, keys: Signature.Keys|undefined, tokenKey: CryptoKey|undefined, signer: string, received: Request|EncodedRequest, packet: Mqtt.IPublishPacket, batched: boolean) {
+	const decoded = await decodeRequest(received);
+	const request = (decoded !== null ? decoded : { ...received, params: {} } as Request);
+	onRequestChannel.emit(request);
+
+	const id = request.id;
+	const method = request.method;
+	const params = request.params;
+	const client = request.client;
+	const server = request.server;
+	const permission = (tokenKey !== undefined ? await Tokens.check(request.token, client, signer, tokenKey) : undefined);
+	const result = await execute(method, params, decoded !== null, permission);
+	const response = { type: 'response', id, method, result, client, server } as Response;
+	const message = await encodeResponse(response, request.accept);
+	const properties = packet.properties;
+	const responseTopic = (properties && properties.responseTopic ? properties.responseTopic : undefined);
+	const correlationData = (properties && properties.responseTopic ? (batched ? Buffer.from(id) : properties.correlationData) : undefined);
+	const topic = (responseTopic !== undefined ? responseTopic : `mindctrl/clients/${client}`);
+	const measure = async (unsigned: string) => getPublishSize(topic, correlationData, keys !== undefined ? await Signature.sign(unsigned, keys) : unsigned);
+	const messages = await splitResponse(response, message, request.chunkSize, measure);
+
+	for (const unsigned of messages) {
+		const message = (keys !== undefined ? await Signature.sign(unsigned, keys) : unsigned);
+
+		if (responseTopic !== undefined) {
+			const options = { qos: packet.qos, properties: { correlationData } };
+			mqtt.publish(topic, message, options);
+		} else {
+			mqtt.publish(topic, message, { qos: packet.qos });
+		}
+	}
+
+	onResponseChannel.emit(response, request);
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Decompress the params of the given request if needed. Return null if
+// the params cannot be decompressed, in which case the request fails
+// with a validation error.
+//
+
+async function decodeRequest(received: Request|EncodedRequest): Promise<Request|null> {
+	if ('encoding' in received && received.encoding !== undefined) {
+		const { encoding, params, ...rest } = received;
+
+		try {
+			const decoded = JSON.parse(await Compression.decompress(params, encoding));
+			return (typeof decoded === 'object' && decoded !== null ? { ...rest, params: decoded } : null);
+		} catch (err) {
+			return null;
+		}
+	} else {
+		return <Request>received;
+	}
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Serialize the given response, compressing the result if it is large
+// and the client accepts any supported encoding.
+//
+
+async function encodeResponse(response: Response, accept: string|undefined): Promise<string> {
+	const message = JSON.stringify(response);
+	const encoding = Compression.pickEncoding(accept);
+
+	if (encoding !== undefined && message.length >= Compression.COMPRESSION_THRESHOLD) {
+		const result = await Compression.compress(JSON.stringify(response.result), encoding);
+		const { result: _, ...rest } = response;
+		return JSON.stringify({ ...rest, result, encoding } as EncodedResponse);
+	} else {
+		return message;
+	}
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Split the given serialized response into chunks whose messages fit
+// into the chunk size given by the client. The size of a message is
+// found by the given function, which accounts for the signature and
+// the MQTT headers and properties the message is published with.
+// Return the message as it is when it fits, or when the client gives
+// no chunk size or one too small to be honoured.
+//
+// The room left for the data is measured with an empty chunk, whose
+// index and count are as wide as the count of chunks. The count grows
+// with the room taken by its own digits, and it is refined until it
+// no longer grows. Note that the data in base64 is never escaped in
+// the message, so every byte of it takes exactly one byte of room.
+//
+
+async function splitResponse(response: Response, message: string, chunkSize: number|undefined, measure: (message: string) => Promise<number>): Promise<string[]> {
+	const bytes = Buffer.from(message);
+
+	if (chunkSize === undefined || chunkSize < MINIMUM_CHUNK_SIZE || await measure(message) <= chunkSize) {
+		return [message];
+	}
+
+	const { id, method, client, server } = response;
+	let count = 1;
+	let piece = 0;
+
+	while (true) {
+		const empty = JSON.stringify({ type: 'chunk', id, method, client, server, index: count, count, data: '' } as Chunk);
+		piece = Math.floor((chunkSize - await measure(empty)) / 4) * 3;
+
+		if (piece <= 0) {
+			return [message];
+		}
+
+		const needed = Math.ceil(bytes.length / piece);
+
+		if (needed <= count) {
+			count = needed;
+			break;
+		} else {
+			count = needed;
+		}
+	}
+
+	const chunks: string[] = [];
+
+	for (let index = 0; index < count; index++) {
+		const data = bytes.subarray(index * piece, (index + 1) * piece).toString('base64');
+		chunks.push(JSON.stringify({ type: 'chunk', id, method, client, server, index, count, data } as Chunk));
+	}
+
+	return chunks;
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Return the size of the PUBLISH packet that carries the given message
+// to the given topic with the given correlation data.
+//
+
+function getPublishSize(topic: string, correlationData: Buffer|undefined, message: string): number {
+	const extra = (correlationData !== undefined ? correlationData.length : 0);
+	return PUBLISH_OVERHEAD + Buffer.byteLength(topic) + extra + Buffer.byteLength(message);
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Execute the given method with the given params after checking the
+// validity of the request and the permission of the client. Methods
+// open to every client are executed even without a valid token.
+//
+
+async function execute(method: string, params: Rpc.Input, valid: boolean, permission: Tokens.Permission|undefined): Promise<Rpc.Output> {
+	if (valid === false) {
+		return Rpc.createValidationError(`invalid input for method ${method}`);
+	} else if (permission === undefined) {
+		return await Rpc.dispatch(method, params);
+	} else if (permission.permitted) {
+		return await Rpc.dispatch(method, params, permission.methods);
+	} else if (Rpc.isPermitted(method, [])) {
+		return await Rpc.dispatch(method, params, []);
+	} else {
+		return Rpc.createForbiddenError(permission.reason);
+	}
+}
+
+
 //////////////////////////////////////////////////////////////////////////
 //
 // Start the server.
@@ -227,35 +539,68 @@ async function whenMessage(topic: string, payload: any) {
 
 export async function start() {
 	const config = await Config.load();
+	let keys: Signature.Keys|undefined = undefined;
+	let tokenKey: CryptoKey|undefined = undefined;
+
+	try {
+		keys = (config ? await Signature.loadKeys(config) : undefined);
+		tokenKey = (config ? await Tokens.loadTokenKey(config) : undefined);
+	} catch (err) {
+		onUnconfiguredChannel.emit();
+		return;
+	}
+
+	if (tokenKey !== undefined && (keys === undefined || keys.algorithm !== 'ed25519')) {
+		onUnconfiguredChannel.emit();
+		return;
+	}
 
 	if (config) {
-		const client = Mqtt.connect(config.url, {
-			username: (config.username != '' ? config.username : undefined),
-			password: (config.password != '' ? config.password : undefined),
-			keepalive: 1800,
-			connectTimeout: 10 * 1000,
-			reconnectPeriod: 0,
-			clean: true,
-			will: {
-				topic: `mindctrl/statuses/${config.name}`,
-				payload: 'dead',
-				retain: true,
-				qos: 0,
-			},
-		});
-
-		state = { type: 'starting', config, client };
+		connect(config, keys, tokenKey, 5);
 		onStartingChannel.emit();
-
-		client.once('connect', whenConnect);
-		client.once('close', whenDisconnect);
-		client.on('message', whenMessage);
 	} else {
 		onUnconfiguredChannel.emit();
 	}
 }
 
 
+//////////////////////////////////////////////////////////////////////////
+//
+// Connect to the MQTT server with the given protocol version, and move
+// the server state to starting.
+//
+// MQTT v5 is preferred, since it allows responses to be routed by the
+// response topic and correlation data of the requests. With MQTT 3.1.1
+// the requests carry no such properties, so responses are published
+// to the client topic derived from the routing fields of the requests
+// instead, and status messages carry no timestamp.
+//
+
+function connect(config: Config.Config, keys: Signature.Keys|undefined, tokenKey: CryptoKey|undefined, protocolVersion: 4|5) {
+	const client = Mqtt.connect(config.url, {
+		username: (config.username != '' ? config.username : undefined),
+		password: (config.password != '' ? config.password : undefined),
+		keepalive: 1800,
+		protocolVersion: protocolVersion,
+		connectTimeout: 10 * 1000,
+		reconnectPeriod: 0,
+		clean: true,
+		will: {
+			topic: `mindctrl/statuses/${config.name}`,
+			payload: 'dead',
+			retain: true,
+			qos: 0,
+		},
+	});
+
+	state = { type: 'starting', config, client, keys, tokenKey, protocolVersion };
+
+	client.once('connect', whenConnect);
+	client.once('close', whenDisconnect);
+	client.on('message', whenMessage);
+}
+
+
 //////////////////////////////////////////////////////////////////////////
 //
 // Stop the server.
@@ -268,7 +613,7 @@ export function stop() {
 		const name = state.config.name;
 
 		clearInterval(state.timer);
-		state.client.publish(`mindctrl/statuses/${name}`, 'dead', { retain: true });
+		publishStatus(state.client, name, 'dead');
 		state.client.end(false, undefined);
 
 		state = { type: 'stopping', config, client };
@@ -292,5 +637,6 @@ export const onUnconfigured = onUnconfiguredChannel.observer;
 export const onUnreachable = onUnreachableChannel.observer;
 export const onDisconnected = onDisconnectedChannel.observer;
 export const onGarbage = onGarbageChannel.observer;
+export const onRejected = onRejectedChannel.observer;
 
 
@@ -0,0 +1,190 @@
+
+
+import * as Config from './config';
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Algorithms for signed messages. The HMAC algorithm uses a secret
+// shared with the clients, while the Ed25519 algorithm uses the private
+// key of the extension to sign responses and the public keys of the
+// clients to verify requests.
+//
+
+export type Algorithm = 'hmac-sha256' | 'ed25519';
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Signed messages wrap requests, batches, responses and chunks as the
+// payload string, so that the signature covers the exact text of the
+// message. The signature is calculated over the algorithm, timestamp,
+// nonce and payload separated by newlines, and encoded in base64.
+//
+
+interface Signed {
+	type: 'signed';
+	algorithm: Algorithm;
+	timestamp: number;
+	nonce: string;
+	payload: string;
+	signature: string;
+}
+
+function isSigned(input: any): input is Signed {
+	if (typeof input !== 'object' || input === null) return false;
+	if (input['type'] !== 'signed') return false;
+	if (input['algorithm'] !== 'hmac-sha256' && input['algorithm'] !== 'ed25519') return false;
+	if (typeof input['timestamp'] !== 'number') return false;
+	if (typeof input['nonce'] !== 'string') return false;
+	if (typeof input['payload'] !== 'string') return false;
+	if (typeof input['signature'] !== 'string') return false;
+	return true;
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Keys for signing responses and verifying requests, together with the
+// nonces of recent requests for replay protection. Requests signed more
+// than the window away from the current time are rejected, and so are
+// requests whose nonce is seen before. The client keys contain the
+// public keys of the clients in base64, in the same order as the
+// verifying keys; the only client key is empty for the HMAC algorithm.
+//
+
+export interface Keys {
+	algorithm: Algorithm;
+	signingKey: CryptoKey;
+	verifyingKeys: CryptoKey[];
+	clientKeys: string[];
+	seen: Map<string, number>;
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Message opened from a signed message, together with the public key
+// in base64 of the client who signed it. The signer is empty for the
+// HMAC algorithm, since every client shares the same secret.
+//
+
+export interface Opened {
+	payload: string;
+	signer: string;
+}
+
+const SIGNATURE_WINDOW = 5 * 60 * 1000;
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Import the keys from the given config. The Ed25519 private key is
+// given as PKCS #8 in base64, and the public keys of the clients as raw
+// keys in base64 separated by whitespaces or commas. Return undefined
+// if signing is not configured. Throw if any key cannot be imported.
+//
+
+export async function loadKeys(config: Config.Config): Promise<Keys|undefined> {
+	const seen = new Map<string, number>();
+
+	if (config.signingKey !== '') {
+		const algorithm = 'ed25519';
+		const signingKey = await crypto.subtle.importKey('pkcs8', Buffer.from(config.signingKey, 'base64'), { name: 'Ed25519' }, false, [ 'sign' ]);
+		const clientKeys = config.clientKeys.split(/[\s,]+/).filter((key) => key !== '');
+		const verifyingKeys = await Promise.all(clientKeys.map(function(key) {
+			return crypto.subtle.importKey('raw', Buffer.from(key, 'base64'), { name: 'Ed25519' }, false, [ 'verify' ]);
+		}));
+
+		return { algorithm, signingKey, verifyingKeys, clientKeys, seen };
+	} else if (config.signingSecret !== '') {
+		const algorithm = 'hmac-sha256';
+		const signingKey = await crypto.subtle.importKey('raw', Buffer.from(config.signingSecret), { name: 'HMAC', hash: 'SHA-256' }, false, [ 'sign', 'verify' ]);
+		return { algorithm, signingKey, verifyingKeys: [ signingKey ], clientKeys: [ '' ], seen };
+	} else {
+		return undefined;
+	}
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Sign the given message with the given keys.
+//
+
+export async function sign(message: string, keys: Keys): Promise<string> {
+	const algorithm = keys.algorithm;
+	const timestamp = Date.now();
+	const nonce = Buffer.from(crypto.getRandomValues(new Uint8Array(16))).toString('hex');
+	const payload = message;
+	const input = getSigningInput(algorithm, timestamp, nonce, payload);
+	const signature = Buffer.from(await crypto.subtle.sign(getParams(algorithm), keys.signingKey, input)).toString('base64');
+	return JSON.stringify({ type: 'signed', algorithm, timestamp, nonce, payload, signature } as Signed);
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Verify the given signed message with the given keys and return the
+// message wrapped inside together with its signer. Return null if the
+// message is not signed, or is badly signed, stale or replayed.
+//
+
+export async function open(message: string, keys: Keys): Promise<Opened|null> {
+	let signed: any;
+
+	try {
+		signed = JSON.parse(message);
+	} catch (err) {
+		return null;
+	}
+
+	if (isSigned(signed) === false || signed.algorithm !== keys.algorithm) {
+		return null;
+	}
+
+	const now = Date.now();
+	const input = getSigningInput(signed.algorithm, signed.timestamp, signed.nonce, signed.payload);
+	const signature = Buffer.from(signed.signature, 'base64');
+	let verified = false;
+	let signer = '';
+
+	for (const [ index, key ] of keys.verifyingKeys.entries()) {
+		if (await crypto.subtle.verify(getParams(signed.algorithm), key, signature, input)) {
+			verified = true;
+			signer = keys.clientKeys[index];
+			break;
+		}
+	}
+
+	for (const [ nonce, expiry ] of keys.seen) {
+		if (expiry < now) {
+			keys.seen.delete(nonce);
+		}
+	}
+
+	if (verified === false) {
+		return null;
+	} else if (Math.abs(now - signed.timestamp) > SIGNATURE_WINDOW) {
+		return null;
+	} else if (keys.seen.has(signed.nonce)) {
+		return null;
+	} else {
+		keys.seen.set(signed.nonce, signed.timestamp + SIGNATURE_WINDOW);
+		return { payload: signed.payload, signer };
+	}
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Helper functions.
+//
+
+function getSigningInput(algorithm: Algorithm, timestamp: number, nonce: string, payload: string): Uint8Array {
+	return new TextEncoder().encode(`${algorithm}\n${timestamp}\n${nonce}\n${payload}`);
+}
+
+function getParams(algorithm: Algorithm): AlgorithmIdentifier {
+	return (algorithm === 'ed25519' ? { name: 'Ed25519' } : { name: 'HMAC' });
+}
@@ -5,6 +5,7 @@ import * as WebExtension from 'webextension-polyfill';
 import * as Browser from './browser';
 import * as Context from './context';
 import * as Logger from './logger';
+import * as Policy from './policy';
 import * as Rpc from './rpc';
 import * as Util from './util';
 import * as Validator from './validator';
@@ -238,6 +239,12 @@ export function registerGetCurrentMethod() {
 // the tab is opened and fully loaded. If the option is true, the method
 // will return early, not waiting the tab to be fully loaded.
 //
+// The method fails with a policy error if the URL is not permitted by
+// the sandbox policy. The final URL of the loaded page is checked too,
+// since the page may redirect elsewhere; the tab is then left on a
+// blank page. With the noWait option, the final URL is not known when
+// the method returns, and only the requested URL is checked.
+//
 // The method is a simple wrapper over the tabs.create Web Extension
 // API. Details on the API can be found in:
 //
@@ -275,17 +282,21 @@ export function registerCreateMethod() {
 			}
 		},
 
-		async function (input: CreateInput): Promise<CreateResult|Rpc.ExecutionError> {
+		async function (input: CreateInput): Promise<CreateResult|Rpc.ExecutionError|Rpc.PolicyError> {
 			try {
 				const url = input.url || 'about:blank';
 				const active = input.active || false;
 				const noWait = input.noWait || false;
 
+				if (Policy.isUrlPermitted(await Policy.load(), url) === false) {
+					return Rpc.createPolicyError(`url ${url} not permitted by policy`);
+				}
+
 				const windowId = input.windowId || await Context.getLastFocusedWindow();
 				const result = await WebExtension.tabs.create({ url, active, windowId });
 				const tabId = result.id!;
 
-				return await new Promise<CreateResult|Rpc.ExecutionError>((resolve, reject) => {
+				const outcome = await new Promise<CreateResult|Rpc.ExecutionError>((resolve, reject) => {
 					function handleRetrieved(tab: WebExtension.Tabs.Tab) {
 						if (tab.id === tabId && tab.status === 'complete') {
 							WebExtension.tabs.onUpdated.removeListener(handleUpdated);
@@ -327,6 +338,8 @@ export function registerCreateMethod() {
 						WebExtension.tabs.get(tabId).then(handleRetrieved, handleFailed);
 					}
 				});
+
+				return (noWait ? outcome : await checkLoadedTab(outcome));
 			} catch (error) {
 				return Rpc.createExecutionError(error);
 			}
@@ -341,6 +354,11 @@ export function registerCreateMethod() {
 //
 // The method loads a new page in the tab identified by the given tab ID,
 // and reports updated information on the loaded tab back to the caller.
+// The method fails with a policy error if the URL is not permitted by
+// the sandbox policy. The final URL of the loaded page is checked too,
+// since the page may redirect elsewhere; the tab is then left on a
+// blank page. With the noWait option, the final URL is not known when
+// the method returns, and only the requested URL is checked.
 //
 // The replace option determines how the new page will be inserted into
 // the history stack. If the option is true, the new page will replace
@@ -389,12 +407,16 @@ export function registerLoadMethod() {
 			}
 		},
 
-		async function (input: LoadInput): Promise<LoadResult|Rpc.ExecutionError> {
+		async function (input: LoadInput): Promise<LoadResult|Rpc.ExecutionError|Rpc.PolicyError> {
 			try {
 				const url = input.url;
 				const updates = { url } as WebExtension.Tabs.UpdateUpdatePropertiesType;
 				const noWait = input.noWait || false;
 
+				if (Policy.isUrlPermitted(await Policy.load(), url) === false) {
+					return Rpc.createPolicyError(`url ${url} not permitted by policy`);
+				}
+
 				// Only Firefox supports the loadReplace flag to control if the current
 				// page should be replaced in the history stack. Other browser does not.
 				// Therefore, we check for need to be selective here.
@@ -412,7 +434,7 @@ export function registerLoadMethod() {
 
 				await Util.waitDuration(1000);
 
-				return await new Promise<LoadResult|Rpc.ExecutionError>((resolve, reject) => {
+				const outcome = await new Promise<LoadResult|Rpc.ExecutionError>((resolve, reject) => {
 					function handleRetrieved(tab: WebExtension.Tabs.Tab) {
 						if (tab.id === tabId && tab.status === 'complete') {
 							WebExtension.tabs.onUpdated.removeListener(handleUpdated);
@@ -454,6 +476,8 @@ export function registerLoadMethod() {
 						WebExtension.tabs.get(tabId).then(handleRetrieved, handleFailed);
 					}
 				});
+
+				return (noWait ? outcome : await checkLoadedTab(outcome));
 			} catch (error) {
 				return Rpc.createExecutionError(error);
 			}
@@ -478,6 +502,10 @@ export function registerLoadMethod() {
 // the tab is fully loaded. If the option is true, the method will
 // return early, not waiting the tab to be fully loaded.
 //
+// The method fails with a policy error if the page in the tab is not
+// permitted by the sandbox policy. Like tabs.load, the final URL of
+// the reloaded page is checked too unless the noWait option is set.
+//
 // The method is a simple wrapper over the tabs.update Web Extension API.
 // Details on the API can be found in:
 //
@@ -512,11 +540,16 @@ export function registerReloadMethod() {
 			}
 		},
 
-		async function (input: ReloadInput): Promise<ReloadResult|Rpc.ExecutionError> {
+		async function (input: ReloadInput): Promise<ReloadResult|Rpc.ExecutionError|Rpc.PolicyError> {
 			try {
 				const bypassCache = input.bypassCache || false;
 				const noWait = input.noWait || false;
 				const tabId = await Context.ensureNotConsoleTab(input.tabId);
+				const tab = await WebExtension.tabs.get(tabId);
+
+				if (Policy.isUrlPermitted(await Policy.load(), tab.url || '') === false) {
+					return Rpc.createPolicyError(`page in tab ${tabId} not permitted by policy`);
+				}
 
 				await WebExtension.tabs.reload(tabId, { bypassCache });
 
@@ -526,7 +559,7 @@ export function registerReloadMethod() {
 
 				await Util.waitDuration(1000);
 
-				return await new Promise<ReloadResult|Rpc.ExecutionError>((resolve, reject) => {
+				const outcome = await new Promise<ReloadResult|Rpc.ExecutionError>((resolve, reject) => {
 					function handleRetrieved(tab: WebExtension.Tabs.Tab) {
 						if (tab.id == tabId && (noWait || tab.status === 'complete')) {
 							WebExtension.tabs.onUpdated.removeListener(handleUpdated);
@@ -567,6 +600,8 @@ export function registerReloadMethod() {
 						WebExtension.tabs.get(tabId).then(handleRetrieved, handleFailed);
 					}
 				});
+
+				return (noWait ? outcome : await checkLoadedTab(outcome));
 			} catch (error) {
 				return Rpc.createExecutionError(error);
 			}
@@ -1038,3 +1073,21 @@ export function registerRemoveMethod() {
 }
 
 
+//////////////////////////////////////////////////////////////////////////
+//
+// Check the final URL of a tab loaded by tabs.create, tabs.load or
+// tabs.reload against the sandbox policy, since the page may redirect
+// to a URL not permitted. If the URL is not permitted, the tab is left
+// on a blank page and a policy error is returned instead.
+//
+
+async function checkLoadedTab<T extends { success: true, result: WebExtension.Tabs.Tab }>(outcome: T|Rpc.ExecutionError): Promise<T|Rpc.ExecutionError|Rpc.PolicyError> {
+	if (Rpc.isExecutionError(outcome)) {
+		return outcome;
+	} else if (Policy.isUrlPermitted(await Policy.load(), outcome.result.url || '')) {
+		return outcome;
+	} else {
+		await WebExtension.tabs.update(outcome.result.id!, { url: 'about:blank' });
+		return Rpc.createPolicyError(`page in tab ${outcome.result.id} redirected to url ${outcome.result.url} not permitted by policy`);
+	}
+}
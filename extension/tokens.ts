@@ -0,0 +1,119 @@
+
+
+import * as Config from './config';
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Capability tokens grant clients the right to call some methods. The
+// token consists of the claims in JSON and the Ed25519 signature of the
+// issuer over the former, both encoded in unpadded base64url and joined
+// with a dot. The claims list the patterns of the permitted methods,
+// the public key of the client the token is bound to, and optionally
+// the client name the token is restricted to and the time of expiry in
+// seconds.
+//
+// The client name is chosen by the caller, so the binding to the key
+// is what keeps a token from being replayed by others: the request
+// must be signed by the private key of the bound public key.
+//
+
+interface Claims {
+	alg: 'ed25519';
+	client?: string;
+	key?: string;
+	methods: string[];
+	iat: number;
+	exp?: number;
+}
+
+function isClaims(input: any): input is Claims {
+	if (typeof input !== 'object' || input === null) return false;
+	if (input['alg'] !== 'ed25519') return false;
+	if (input['client'] !== undefined && typeof input['client'] !== 'string') return false;
+	if (input['key'] !== undefined && typeof input['key'] !== 'string') return false;
+	if (Array.isArray(input['methods']) === false) return false;
+	if (input['methods'].every((method: any) => typeof method === 'string') === false) return false;
+	if (input['exp'] !== undefined && typeof input['exp'] !== 'number') return false;
+	return true;
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Outcome of checking the token of a request: either the patterns of the
+// methods the client may call, or the reason the token is refused.
+//
+
+export type Permission =
+	{ permitted: true, methods: string[] } |
+	{ permitted: false, reason: string }
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Import the public key of the token issuer from the given config, which
+// is given as a raw key in base64. Return undefined if tokens are not
+// required. Throw if the key cannot be imported.
+//
+
+export async function loadTokenKey(config: Config.Config): Promise<CryptoKey|undefined> {
+	if (config.tokenKey !== '') {
+		return await crypto.subtle.importKey('raw', Buffer.from(config.tokenKey, 'base64'), { name: 'Ed25519' }, false, [ 'verify' ]);
+	} else {
+		return undefined;
+	}
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Check the given token of a request from the given client with the
+// public key of the issuer. The signer is the public key in base64 of
+// the client who signed the request, which must match the key the
+// token is bound to.
+//
+
+export async function check(token: any, client: string, signer: string, key: CryptoKey): Promise<Permission> {
+	if (typeof token !== 'string' || token === '') {
+		return { permitted: false, reason: 'capability token required' };
+	}
+
+	const parts = token.split('.');
+
+	if (parts.length !== 2) {
+		return { permitted: false, reason: 'malformed token' };
+	} else if (await crypto.subtle.verify({ name: 'Ed25519' }, key, decode(parts[1]), new TextEncoder().encode(parts[0])) === false) {
+		return { permitted: false, reason: 'invalid token signature' };
+	}
+
+	let claims: any;
+
+	try {
+		claims = JSON.parse(decode(parts[0]).toString());
+	} catch (err) {
+		return { permitted: false, reason: 'malformed token' };
+	}
+
+	if (isClaims(claims) === false) {
+		return { permitted: false, reason: 'malformed token' };
+	} else if (claims.exp !== undefined && claims.exp > 0 && Date.now() / 1000 > claims.exp) {
+		return { permitted: false, reason: 'capability token expired' };
+	} else if (claims.key === undefined || signer === '' || Buffer.from(claims.key, 'base64').equals(Buffer.from(signer, 'base64')) === false) {
+		return { permitted: false, reason: 'capability token not bound to the signing key' };
+	} else if (claims.client !== undefined && claims.client !== '' && claims.client !== client) {
+		return { permitted: false, reason: `capability token not issued to client ${client}` };
+	} else {
+		return { permitted: true, methods: claims.methods };
+	}
+}
+
+
+//////////////////////////////////////////////////////////////////////////
+//
+// Decode the given unpadded base64url data.
+//
+
+function decode(data: string): Buffer {
+	return Buffer.from(data.replace(/-/g, '+').replace(/_/g, '/'), 'base64');
+}
@@ -5,6 +5,7 @@ import * as WebExtension from 'webextension-polyfill';
 import * as Browser from './browser';
 import * as Context from './context';
 import * as Logger from './logger';
+import * as Policy from './policy';
 import * as Rpc from './rpc';
 import * as Util from './util';
 import * as Validator from './validator';
@@ -202,6 +203,11 @@ export function registerGetCurrentMethod() {
 // If they are not specified, the window will be sized at the browsers'\
 // discretion.
 //
+// The method fails with a policy error if the URL is not permitted by
+// the sandbox policy. The method returns without waiting for the page
+// to load, so a page redirecting to a URL not permitted is not caught;
+// policies relying on URL patterns should not trust redirects.
+//
 // The method is a simple wrapper over the windowss.create Web Extension
 // API. Details on the API can be found in:
 //
@@ -246,10 +252,15 @@ export function registerCreateMethod() {
 			}
 		},
 
-		async function (input: CreateInput): Promise<CreateResult|Rpc.ExecutionError> {
+		async function (input: CreateInput): Promise<CreateResult|Rpc.ExecutionError|Rpc.PolicyError> {
 			try {
 				const url = input.url || 'about:blank';
 				const state = input.state || 'normal';
+
+				if (Policy.isUrlPermitted(await Policy.load(), url) === false) {
+					return Rpc.createPolicyError(`url ${url} not permitted by policy`);
+				}
+
 				const focused = (state === 'normal' ? input.focused || false : false);
 				const top = (state === 'normal' ? input.top || undefined : undefined);
 				const left = (state === 'normal' ? input.left || undefined : undefined);